@@ -1,5 +1,6 @@
 echo "Testing rate limiter..."
-echo "Должно занять ~2 секунды для 200 запросов при лимите 100 RPS"
+echo "Login ограничен 20 запросами подряд на IP и 5 на username:"
+echo "из 200 запросов большинство должно получить ResourceExhausted"
 echo ""
 
 time (
@@ -7,7 +8,7 @@ time (
     grpcurl -plaintext \
       -d '{"username": "denis", "password": "12345678"}' \
       localhost:50051 \
-      api.Auth/Login > /dev/null 2>&1 &
+      api.Auth/Login 2>&1 | grep -o "ResourceExhausted" &
   done
   wait
-)
\ No newline at end of file
+) | sort | uniq -c
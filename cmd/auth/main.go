@@ -2,19 +2,29 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"go.uber.org/fx"
-	"go.uber.org/ratelimit"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 
 	"auth-micro/client"
 	"auth-micro/internal/auth/app"
 	"auth-micro/internal/auth/config"
+	"auth-micro/internal/auth/handler"
 	"auth-micro/internal/auth/middleware"
+	"auth-micro/internal/auth/outbox"
+	"auth-micro/internal/auth/publisher"
+	"auth-micro/internal/auth/repository"
+	"auth-micro/internal/auth/utils"
 	pb "auth-micro/pkg/auth_v1"
 )
 
@@ -25,33 +35,58 @@ func main() {
 			config.Load,   // Загрузка конфигурации
 			client.NewDB,  // БД с lifecycle
 			newGRPCServer, // gRPC сервер
-			newRateLimiter,
+			newHTTPServer, // HTTP сервер (JWKS)
+			middleware.NewRateLimiter,
+			middleware.NewMethodPermissions,
+			newUnaryInterceptor,
 		),
 
 		// Модулиk
 		app.Module,
 
-		// Lifecycle для gRPC сервера
+		// Lifecycle для gRPC и HTTP серверов
 		fx.Invoke(registerGRPCServer),
+		fx.Invoke(registerHTTPServer),
+		fx.Invoke(registerGatewayServer),
+
+		// Перечитывание ключей подписи по SIGHUP
+		fx.Invoke(watchKeyReload),
+
+		// Очистка denylist от истёкших токенов
+		fx.Invoke(purgeRevokedTokens),
+
+		// Очистка истёкших токенов, старых счётчиков входов и журнала событий
+		fx.Invoke(purgeStaleRecords),
+
+		// Доставка событий из outbox другим сервисам
+		fx.Invoke(runOutboxRelay),
 	).Run()
 }
 
-func newRateLimiter(cfg *config.Config) ratelimit.Limiter {
-	return ratelimit.New(cfg.RateLimit.RequestsPerSecond)
+func newUnaryInterceptor(
+	rl *middleware.RateLimiter,
+	perms middleware.MethodPermissions,
+	jwtManager *utils.JWTManager,
+	denylist repository.TokenDenylist,
+) grpc.UnaryServerInterceptor {
+	return middleware.ChainUnary(
+		middleware.ClientInfoInterceptor(),
+		middleware.RateLimitInterceptor(rl),
+		middleware.AuthInterceptor(jwtManager, denylist),
+		middleware.UserRateLimitInterceptor(rl),
+		middleware.PermissionInterceptor(perms),
+	)
 }
 
-func newGRPCServer(rl ratelimit.Limiter) *grpc.Server {
-	return grpc.NewServer(
-		grpc.ChainUnaryInterceptor(
-			middleware.RateLimitInterceptor(rl),
-		),
-	)
+func newGRPCServer(interceptor grpc.UnaryServerInterceptor) *grpc.Server {
+	return grpc.NewServer(grpc.UnaryInterceptor(interceptor))
 }
 
 func registerGRPCServer(
 	lc fx.Lifecycle,
 	grpcServer *grpc.Server,
 	handler pb.AuthServer,
+	adminHandler pb.AdminServer,
 	cfg *config.Config,
 ) {
 	lc.Append(fx.Hook{
@@ -62,6 +97,7 @@ func registerGRPCServer(
 			}
 
 			pb.RegisterAuthServer(grpcServer, handler)
+			pb.RegisterAdminServer(grpcServer, adminHandler)
 			reflection.Register(grpcServer)
 
 			go func() {
@@ -81,3 +117,191 @@ func registerGRPCServer(
 		},
 	})
 }
+
+func newHTTPServer(cfg *config.Config, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%s", cfg.Server.HTTPPort),
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+func registerHTTPServer(lc fx.Lifecycle, httpServer *http.Server) {
+	appendHTTPServer(lc, httpServer, "HTTP server")
+}
+
+// registerGatewayServer запускает HTTP/JSON-шлюз к gRPC API на отдельном порту
+func registerGatewayServer(lc fx.Lifecycle, cfg *config.Config, gateway *handler.Gateway) {
+	appendHTTPServer(lc, &http.Server{
+		Addr:              fmt.Sprintf(":%s", cfg.Server.GatewayPort),
+		Handler:           gateway,
+		ReadHeaderTimeout: 10 * time.Second,
+	}, "HTTP gateway")
+}
+
+func appendHTTPServer(lc fx.Lifecycle, httpServer *http.Server, name string) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			lis, err := net.Listen("tcp", httpServer.Addr)
+			if err != nil {
+				return fmt.Errorf("failed to listen: %w", err)
+			}
+
+			go func() {
+				log.Printf("%s listening on %s", name, httpServer.Addr)
+				if err := httpServer.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					log.Fatalf("Failed to serve %s: %v", name, err)
+				}
+			}()
+
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			log.Printf("Stopping %s...", name)
+			return httpServer.Shutdown(ctx)
+		},
+	})
+}
+
+// watchKeyReload перечитывает ключи подписи JWT при получении SIGHUP
+func watchKeyReload(lc fx.Lifecycle, jwtManager *utils.JWTManager) {
+	sighup := make(chan os.Signal, 1)
+
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			signal.Notify(sighup, syscall.SIGHUP)
+			go func() {
+				for range sighup {
+					kid, err := jwtManager.ReloadKeys()
+					if errors.Is(err, utils.ErrSigningKeyUnchanged) {
+						log.Printf("JWT keys reloaded, signing key unchanged (kid %s)", kid)
+						continue
+					}
+					if err != nil {
+						log.Printf("Failed to reload JWT keys: %v", err)
+						continue
+					}
+					log.Printf("JWT keys reloaded, current kid %s", kid)
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			signal.Stop(sighup)
+			close(sighup)
+			return nil
+		},
+	})
+}
+
+// purgeRevokedTokens периодически удаляет из denylist токены, срок которых истёк
+func purgeRevokedTokens(lc fx.Lifecycle, denylist repository.TokenDenylist, cfg *config.Config) {
+	interval := cfg.Revocation.PurgeInterval
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+
+	runPeriodically(lc, interval, func(ctx context.Context) {
+		purged, err := denylist.PurgeExpired(ctx)
+		if err != nil {
+			log.Printf("Failed to purge revoked tokens: %v", err)
+			return
+		}
+		if purged > 0 {
+			log.Printf("Purged %d expired revoked tokens", purged)
+		}
+	})
+}
+
+func purgeStaleRecords(
+	lc fx.Lifecycle,
+	users repository.UserRepository,
+	oneTimeTokens repository.OneTimeTokenRepository,
+	attempts repository.LoginAttemptRepository,
+	cfg *config.Config,
+) {
+	interval := cfg.Retention.PurgeInterval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	// Счётчик без ошибок дольше reset_after всё равно начался бы заново
+	attemptsTTL := cfg.Lockout.ResetAfter
+	if cfg.Lockout.Window > attemptsTTL {
+		attemptsTTL = cfg.Lockout.Window
+	}
+
+	type purge struct {
+		name string
+		run  func(ctx context.Context) (int64, error)
+	}
+	purges := []purge{
+		{"expired refresh tokens", users.DeleteExpiredRefreshTokens},
+		{"expired one-time tokens", oneTimeTokens.DeleteExpired},
+		{"stale login attempts", func(ctx context.Context) (int64, error) {
+			return attempts.DeleteStale(ctx, attemptsTTL)
+		}},
+	}
+
+	runPeriodically(lc, interval, func(ctx context.Context) {
+		for _, p := range purges {
+			purged, err := p.run(ctx)
+			if err != nil {
+				log.Printf("Failed to purge %s: %v", p.name, err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("Purged %d %s", purged, p.name)
+			}
+		}
+	})
+}
+
+// runPeriodically вызывает job раз в interval, пока приложение запущено
+func runPeriodically(lc fx.Lifecycle, interval time.Duration, job func(ctx context.Context)) {
+	stop := make(chan struct{})
+
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			go func() {
+				ticker := time.NewTicker(interval)
+				defer ticker.Stop()
+				for {
+					select {
+					case <-ticker.C:
+						job(context.Background())
+					case <-stop:
+						return
+					}
+				}
+			}()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			close(stop)
+			return nil
+		},
+	})
+}
+
+func runOutboxRelay(lc fx.Lifecycle, relay *outbox.Relay, pub publisher.Publisher) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	lc.Append(fx.Hook{
+		OnStart: func(context.Context) error {
+			go func() {
+				defer close(done)
+				relay.Run(ctx)
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+			case <-stopCtx.Done():
+			}
+			return pub.Close()
+		},
+	})
+}
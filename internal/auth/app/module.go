@@ -1,9 +1,20 @@
 package app
 
 import (
+    "fmt"
+
+    "github.com/go-webauthn/webauthn/webauthn"
     "go.uber.org/fx"
 
+    "auth-micro/client"
+    "auth-micro/internal/auth/config"
+    "auth-micro/internal/auth/federation"
     "auth-micro/internal/auth/handler"
+    "auth-micro/internal/auth/mailer"
+    "auth-micro/internal/auth/outbox"
+    "auth-micro/internal/auth/publisher"
+    "auth-micro/internal/auth/repository"
+    repoMemory "auth-micro/internal/auth/repository/memory"
     repoPostgres "auth-micro/internal/auth/repository/postgres"
     serviceAuth "auth-micro/internal/auth/service"
     "auth-micro/internal/auth/utils"
@@ -11,7 +22,62 @@ import (
 
 var Module = fx.Module("app",
     fx.Provide(repoPostgres.NewUserRepo),
+    fx.Provide(newTokenDenylist),
+    fx.Provide(repoPostgres.NewLoginAttemptRepo),
+    fx.Provide(repoPostgres.NewRoleRepo),
+    fx.Provide(repoPostgres.NewAuthEventRepo),
+    fx.Provide(repoPostgres.NewOutboxRepo),
+    fx.Provide(publisher.New),
+    fx.Provide(outbox.NewRelay),
+    fx.Provide(newEventSink),
+    fx.Provide(repoPostgres.NewOneTimeTokenRepo),
+    fx.Provide(repoPostgres.NewMFARepo),
+    fx.Provide(utils.NewSecretBox),
+    fx.Provide(repoPostgres.NewWebAuthnRepo),
+    fx.Provide(newWebAuthn),
+    fx.Provide(repoPostgres.NewOAuthRepo),
+    fx.Provide(repoPostgres.NewFederationRepo),
+    fx.Provide(federation.New),
+    fx.Provide(mailer.New),
     fx.Provide(utils.NewJWTManager),
     fx.Provide(serviceAuth.NewUserService),
     fx.Provide(handler.NewGRPCHandler),
-)
\ No newline at end of file
+    fx.Provide(handler.NewAdminGRPCHandler),
+    fx.Provide(handler.NewHTTPHandler),
+    fx.Provide(handler.NewGateway),
+)
+
+// newTokenDenylist выбирает хранилище отозванных токенов по конфигурации
+func newTokenDenylist(cfg *config.Config, db *client.DB) (repository.TokenDenylist, error) {
+    switch cfg.Revocation.Store {
+    case "", "postgres":
+        return repoPostgres.NewTokenDenylist(db), nil
+    case "memory":
+        return repoMemory.NewTokenDenylist(), nil
+    default:
+        return nil, fmt.Errorf("unknown revocation store %q", cfg.Revocation.Store)
+    }
+}
+
+// newEventSink — куда пишутся события безопасности. Пока только журнал в БД
+func newEventSink(events repository.AuthEventRepository) repository.EventSink {
+    return events
+}
+
+// newWebAuthn настраивает проверку ключей доступа (passkeys)
+func newWebAuthn(cfg *config.Config) (*webauthn.WebAuthn, error) {
+    timeout := webauthn.TimeoutConfig{
+        Enforce:    true,
+        Timeout:    cfg.WebAuthn.Timeout,
+        TimeoutUVD: cfg.WebAuthn.Timeout,
+    }
+    return webauthn.New(&webauthn.Config{
+        RPID:          cfg.WebAuthn.RPID,
+        RPDisplayName: cfg.WebAuthn.RPDisplayName,
+        RPOrigins:     cfg.WebAuthn.RPOrigins,
+        Timeouts: webauthn.TimeoutsConfig{
+            Login:        timeout,
+            Registration: timeout,
+        },
+    })
+}
@@ -0,0 +1,23 @@
+package clientinfo
+
+import "context"
+
+// Info — сведения о клиенте, с которого пришёл запрос
+type Info struct {
+	IP          string
+	UserAgent   string
+	DeviceLabel string // задаётся клиентом в metadata x-device-label
+}
+
+type ctxKey struct{}
+
+// WithInfo кладёт сведения о клиенте в контекст запроса
+func WithInfo(ctx context.Context, info Info) context.Context {
+	return context.WithValue(ctx, ctxKey{}, info)
+}
+
+// FromContext возвращает сведения о клиенте; если их нет — пустую структуру
+func FromContext(ctx context.Context) Info {
+	info, _ := ctx.Value(ctxKey{}).(Info)
+	return info
+}
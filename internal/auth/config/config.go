@@ -2,21 +2,61 @@ package config
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Server    ServerConfig
-	Database  DatabaseConfig
-	JWT       JWTConfig
-	RateLimit RateLimitConfig
+	Server     ServerConfig
+	Database   DatabaseConfig
+	JWT        JWTConfig
+	RateLimit  RateLimitConfig
+	Revocation RevocationConfig
+	Retention  RetentionConfig
+	Lockout    LockoutConfig
+	Mail       MailConfig
+	// EmailVerification — подтверждение email после регистрации
+	EmailVerification EmailVerificationConfig
+	PasswordReset     PasswordResetConfig
+	MFA               MFAConfig
+	WebAuthn          WebAuthnConfig
+	RBAC              RBACConfig
+	Outbox            OutboxConfig
+	OAuth             OAuthConfig
+	// Federation — вход через внешних провайдеров (OpenID Connect, GitHub)
+	Federation FederationConfig
 }
 
 type ServerConfig struct {
 	GRPCPort string
-	Host     string
+	HTTPPort string
+	// GatewayPort — HTTP/JSON-шлюз к gRPC API
+	GatewayPort string
+	Host        string
+	// CORSOrigins — origin браузерных клиентов, которым доступен шлюз
+	CORSOrigins []string
+	// SessionCookie — refresh-токен в куке для браузерных клиентов шлюза
+	SessionCookie SessionCookieConfig
+}
+
+type SessionCookieConfig struct {
+	// Name — HttpOnly-кука с refresh-токеном
+	Name string
+	// Path — браузер отправляет refresh-куку только на этот путь
+	Path   string
+	Domain string
+	Secure bool
+	// SameSite — strict (по умолчанию), lax или none
+	SameSite string
+	// CSRFCookie и CSRFHeader — double-submit токен: значение куки
+	// должно совпадать с заголовком в изменяющих запросах
+	CSRFCookie string
+	CSRFHeader string
+	// FederationCookie — HttpOnly-кука с секретом браузера, начавшего вход
+	// через внешнего провайдера
+	FederationCookie string
 }
 
 type DatabaseConfig struct {
@@ -31,13 +71,180 @@ type DatabaseConfig struct {
 }
 
 type JWTConfig struct {
-	SecretKey            string
+	// Текущий ключ подписи
+	JWTKeyConfig
+	// PreviousKeys — выведенные из оборота ключи, которые ещё принимаются
+	// при проверке в течение RefreshTokenDuration после RetiredAt
+	PreviousKeys         []JWTKeyConfig
 	AccessTokenDuration  time.Duration
 	RefreshTokenDuration time.Duration
 }
 
+type JWTKeyConfig struct {
+	// Algorithm — HS256 (по умолчанию), RS256, ES256 или EdDSA
+	Algorithm string
+	// SecretKey используется только для HS256
+	SecretKey string
+	// PrivateKeyPath — PEM-файл с приватным ключом для асимметричных алгоритмов
+	PrivateKeyPath string
+	// KeyID — kid в заголовке токенов; по умолчанию вычисляется из ключа
+	KeyID string
+	// RetiredAt — когда ключ перестал подписывать; обязателен для PreviousKeys
+	RetiredAt time.Time
+}
+
 type RateLimitConfig struct {
+	// RequestsPerSecond и Burst — лимит по умолчанию на один IP и на одного пользователя
 	RequestsPerSecond int
+	Burst             int
+	// Methods — лимиты отдельных методов, ключ — имя метода (Login, Register, ...)
+	Methods map[string]MethodRateLimit
+}
+
+// MethodRateLimit — лимиты метода по каждому ключу; нулевой лимит заменяется
+// лимитом по умолчанию, а для PerUsername означает «не ограничивать»
+type MethodRateLimit struct {
+	PerIP       RateLimit
+	PerUser     RateLimit
+	PerUsername RateLimit // по username из запроса (Login, Register)
+}
+
+// RateLimit — параметры token bucket
+type RateLimit struct {
+	RequestsPerSecond float64
+	Burst             int
+}
+
+// LockoutConfig — временная блокировка входа после серии неудачных попыток.
+// Длительность блокировки растёт экспоненциально: BaseDuration * 2^(n-1), но не больше MaxDuration
+type LockoutConfig struct {
+	AccountThreshold int           // неудачных попыток подряд на один username до блокировки
+	IPThreshold      int           // то же для одного IP
+	Window           time.Duration // попытки старше окна не считаются
+	BaseDuration     time.Duration
+	MaxDuration      time.Duration
+	ResetAfter       time.Duration // через сколько без ошибок длительность возвращается к BaseDuration
+}
+
+type RevocationConfig struct {
+	// Store — где хранить отозванные jti: postgres (по умолчанию) или memory
+	Store string
+	// PurgeInterval — как часто удалять записи об истёкших токенах
+	PurgeInterval time.Duration
+}
+
+// RetentionConfig — очистка служебных таблиц. Истёкшие refresh- и одноразовые
+// токены и счётчики входов старше lockout.reset_after удаляются всегда.
+// Журнал auth_events только дополняется и здесь не очищается
+type RetentionConfig struct {
+	// PurgeInterval — как часто удалять устаревшие записи
+	PurgeInterval time.Duration
+}
+
+type MailConfig struct {
+	// Driver — smtp, log (по умолчанию, письма пишутся в лог) или file
+	Driver string
+	From   string
+	// SMTP-сервер; пустой Username — без авторизации
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	// SMTPTimeout — предел на подключение к SMTP-серверу и отправку письма
+	SMTPTimeout time.Duration
+	// FileDir — каталог, куда драйвер file складывает письма
+	FileDir string
+}
+
+type EmailVerificationConfig struct {
+	// Required — не пускать в Login пользователей с неподтверждённым email
+	Required bool
+	TokenTTL time.Duration
+	// URL — адрес страницы подтверждения, токен дописывается параметром token
+	URL string
+}
+
+type PasswordResetConfig struct {
+	TokenTTL time.Duration
+	// URL — адрес страницы ввода нового пароля, токен дописывается параметром token
+	URL string
+}
+
+type MFAConfig struct {
+	// Issuer — название сервиса в приложении-аутентификаторе
+	Issuer string
+	// EncryptionKey — ключ шифрования TOTP-секретов в БД; значения по умолчанию нет
+	EncryptionKey string
+	// ChallengeTTL — сколько действует mfa-токен, выданный Login
+	ChallengeTTL time.Duration
+}
+
+type WebAuthnConfig struct {
+	// RPID — домен сайта, к которому привязываются ключи доступа
+	RPID          string
+	RPDisplayName string
+	// RPOrigins — разрешённые origin веб- и мобильных клиентов
+	RPOrigins []string
+	// Timeout — сколько ждать ответа аутентификатора между Begin* и Finish*
+	Timeout time.Duration
+}
+
+type RBACConfig struct {
+	// MethodPermissions — разрешения для вызова метода, ключ — имя метода
+	// в нижнем регистре. Заменяют опцию required_permissions из auth.proto
+	MethodPermissions map[string][]string
+}
+
+type OutboxConfig struct {
+	// Publisher — куда доставлять события: stdout (по умолчанию), file, kafka или nats
+	Publisher string
+	// Topic — топик Kafka или subject NATS
+	Topic string
+	// FilePath — файл, в который publisher file дописывает события
+	FilePath     string
+	KafkaBrokers []string
+	// NATSURL — сервер NATS; для subject Topic должен быть настроен поток JetStream
+	NATSURL string
+	// PollInterval — как часто релей проверяет новые события
+	PollInterval time.Duration
+	BatchSize    int
+	// Lease — на сколько релей забирает события; если он упал, их доставит другой экземпляр
+	Lease time.Duration
+	// Retention — сколько хранить доставленные события
+	Retention time.Duration
+}
+
+type OAuthConfig struct {
+	// Issuer — внешний адрес HTTP-сервера: iss в ID-токенах и база адресов
+	// в /.well-known/openid-configuration
+	Issuer string
+	// ConsentURL — страница входа и согласия; /oauth/authorize перенаправляет
+	// на неё браузер с параметрами исходного запроса
+	ConsentURL string
+	// CodeTTL — сколько действует код авторизации
+	CodeTTL time.Duration
+}
+
+type FederationConfig struct {
+	// RedirectURL — страница клиента, куда провайдер возвращает браузер
+	// с code и state; клиент передаёт их в CompleteFederatedLogin
+	RedirectURL string
+	// StateTTL — сколько ждать возврата браузера от провайдера
+	StateTTL time.Duration
+	// Providers — провайдеры по имени, которое передаёт клиент (google, ...)
+	Providers map[string]IdentityProviderConfig
+}
+
+type IdentityProviderConfig struct {
+	// Type — oidc (по умолчанию) или github
+	Type string
+	// Issuer — адрес провайдера; эндпоинты и ключи берутся из его discovery.
+	// Для github — адрес GitHub Enterprise Server, пусто для github.com
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// Scopes — по умолчанию openid, email и profile (для github — read:user и user:email)
+	Scopes []string
 }
 
 // Load загружает конфигурацию из файла и переменных окружения
@@ -56,7 +263,13 @@ func Load() (*Config, error) {
 
 	// Привязка переменных окружения к ключам конфига
 	v.BindEnv("server.grpc_port", "GRPC_PORT")
+	v.BindEnv("server.http_port", "HTTP_PORT")
+	v.BindEnv("server.gateway_port", "GATEWAY_PORT")
+	v.BindEnv("server.cors_origins", "CORS_ORIGINS")
 	v.BindEnv("server.host", "SERVER_HOST")
+	v.BindEnv("server.session_cookie.domain", "COOKIE_DOMAIN")
+	v.BindEnv("server.session_cookie.secure", "COOKIE_SECURE")
+	v.BindEnv("server.session_cookie.same_site", "COOKIE_SAMESITE")
 
 	v.BindEnv("database.host", "PG_HOST")
 	v.BindEnv("database.port", "PG_PORT")
@@ -65,11 +278,55 @@ func Load() (*Config, error) {
 	v.BindEnv("database.dbname", "PG_DATABASE_NAME")
 	v.BindEnv("database.sslmode", "PG_SSL_MODE")
 
+	v.BindEnv("jwt.algorithm", "JWT_ALGORITHM")
 	v.BindEnv("jwt.secret_key", "SECRET_KEY")
+	v.BindEnv("jwt.private_key_path", "JWT_PRIVATE_KEY_PATH")
+	v.BindEnv("jwt.key_id", "JWT_KEY_ID")
+
+	v.BindEnv("revocation.store", "REVOCATION_STORE")
+
+	v.BindEnv("mail.driver", "MAIL_DRIVER")
+	v.BindEnv("mail.from", "MAIL_FROM")
+	v.BindEnv("mail.smtp_host", "SMTP_HOST")
+	v.BindEnv("mail.smtp_port", "SMTP_PORT")
+	v.BindEnv("mail.smtp_username", "SMTP_USERNAME")
+	v.BindEnv("mail.smtp_password", "SMTP_PASSWORD")
+	v.BindEnv("mail.file_dir", "MAIL_FILE_DIR")
+
+	v.BindEnv("email_verification.required", "EMAIL_VERIFICATION_REQUIRED")
+	v.BindEnv("email_verification.url", "EMAIL_VERIFICATION_URL")
+	v.BindEnv("password_reset.url", "PASSWORD_RESET_URL")
+
+	v.BindEnv("mfa.issuer", "MFA_ISSUER")
+	v.BindEnv("mfa.encryption_key", "MFA_ENCRYPTION_KEY")
+
+	v.BindEnv("webauthn.rp_id", "WEBAUTHN_RP_ID")
+	v.BindEnv("webauthn.rp_display_name", "WEBAUTHN_RP_DISPLAY_NAME")
+	v.BindEnv("webauthn.rp_origins", "WEBAUTHN_RP_ORIGINS")
+
+	v.BindEnv("outbox.publisher", "OUTBOX_PUBLISHER")
+	v.BindEnv("outbox.topic", "OUTBOX_TOPIC")
+	v.BindEnv("outbox.file_path", "OUTBOX_FILE_PATH")
+	v.BindEnv("outbox.kafka_brokers", "KAFKA_BROKERS")
+	v.BindEnv("outbox.nats_url", "NATS_URL")
+
+	v.BindEnv("oauth.issuer", "OAUTH_ISSUER")
+	v.BindEnv("oauth.consent_url", "OAUTH_CONSENT_URL")
+
+	v.BindEnv("federation.redirect_url", "FEDERATION_REDIRECT_URL")
 
 	// Значения по умолчанию
 	v.SetDefault("server.grpc_port", "50051")
+	v.SetDefault("server.http_port", "8080")
+	v.SetDefault("server.gateway_port", "8081")
 	v.SetDefault("server.host", "localhost")
+	v.SetDefault("server.session_cookie.name", "refresh_token")
+	v.SetDefault("server.session_cookie.path", "/v1/token")
+	v.SetDefault("server.session_cookie.secure", true)
+	v.SetDefault("server.session_cookie.same_site", "strict")
+	v.SetDefault("server.session_cookie.csrf_cookie", "csrf_token")
+	v.SetDefault("server.session_cookie.csrf_header", "X-CSRF-Token")
+	v.SetDefault("server.session_cookie.federation_cookie", "federated_login")
 
 	v.SetDefault("database.host", "localhost")
 	v.SetDefault("database.port", "54322")
@@ -80,11 +337,75 @@ func Load() (*Config, error) {
 	v.SetDefault("database.max_conns", 25)
 	v.SetDefault("database.min_conns", 5)
 
-	v.SetDefault("jwt.secret_key", "s12dasd1a3s1d6as5d1a3s1d6as5d")
+	v.SetDefault("jwt.algorithm", "HS256")
 	v.SetDefault("jwt.access_token_duration", "15m")
 	v.SetDefault("jwt.refresh_token_duration", "168h") // 7 дней
 
 	v.SetDefault("rate_limit.requests_per_second", 100)
+	v.SetDefault("rate_limit.burst", 200)
+	// Подбор паролей: не больше 5 попыток подряд на один аккаунт, дальше раз в 10 секунд
+	v.SetDefault("rate_limit.methods.login.per_ip.requests_per_second", 5)
+	v.SetDefault("rate_limit.methods.login.per_ip.burst", 20)
+	v.SetDefault("rate_limit.methods.login.per_username.requests_per_second", 0.1)
+	v.SetDefault("rate_limit.methods.login.per_username.burst", 5)
+	v.SetDefault("rate_limit.methods.register.per_ip.requests_per_second", 0.2)
+	v.SetDefault("rate_limit.methods.register.per_ip.burst", 5)
+	v.SetDefault("rate_limit.methods.requestpasswordreset.per_ip.requests_per_second", 0.2)
+	v.SetDefault("rate_limit.methods.requestpasswordreset.per_ip.burst", 5)
+	v.SetDefault("rate_limit.methods.resendverificationemail.per_ip.requests_per_second", 0.2)
+	v.SetDefault("rate_limit.methods.resendverificationemail.per_ip.burst", 5)
+	// Подбор кодов второго фактора
+	v.SetDefault("rate_limit.methods.verifymfa.per_ip.requests_per_second", 1)
+	v.SetDefault("rate_limit.methods.verifymfa.per_ip.burst", 10)
+
+	v.SetDefault("lockout.account_threshold", 5)
+	v.SetDefault("lockout.ip_threshold", 50)
+	v.SetDefault("lockout.window", "15m")
+	v.SetDefault("lockout.base_duration", "1m")
+	v.SetDefault("lockout.max_duration", "1h")
+	v.SetDefault("lockout.reset_after", "24h")
+
+	v.SetDefault("revocation.store", "postgres")
+	v.SetDefault("revocation.purge_interval", "10m")
+
+	v.SetDefault("retention.purge_interval", "1h")
+
+	v.SetDefault("mail.driver", "log")
+	v.SetDefault("mail.from", "no-reply@localhost")
+	v.SetDefault("mail.smtp_port", "587")
+	v.SetDefault("mail.smtp_timeout", "30s")
+	v.SetDefault("mail.file_dir", "./mail")
+
+	v.SetDefault("email_verification.required", false)
+	v.SetDefault("email_verification.token_ttl", "24h")
+	v.SetDefault("email_verification.url", "http://localhost:8080/verify-email")
+
+	v.SetDefault("password_reset.token_ttl", "30m")
+	v.SetDefault("password_reset.url", "http://localhost:8080/reset-password")
+
+	v.SetDefault("mfa.issuer", "auth-micro")
+	v.SetDefault("mfa.challenge_ttl", "5m")
+
+	v.SetDefault("webauthn.rp_id", "localhost")
+	v.SetDefault("webauthn.rp_display_name", "auth-micro")
+	v.SetDefault("webauthn.rp_origins", "http://localhost:8080")
+	v.SetDefault("webauthn.timeout", "5m")
+
+	v.SetDefault("outbox.publisher", "stdout")
+	v.SetDefault("outbox.topic", "auth.user-events")
+	v.SetDefault("outbox.file_path", "./outbox.jsonl")
+	v.SetDefault("outbox.nats_url", "nats://localhost:4222")
+	v.SetDefault("outbox.poll_interval", "1s")
+	v.SetDefault("outbox.batch_size", 100)
+	v.SetDefault("outbox.lease", "30s")
+	v.SetDefault("outbox.retention", "168h")
+
+	v.SetDefault("oauth.issuer", "http://localhost:8080")
+	v.SetDefault("oauth.consent_url", "http://localhost:8080/consent")
+	v.SetDefault("oauth.code_ttl", "1m")
+
+	v.SetDefault("federation.redirect_url", "http://localhost:8080/login/callback")
+	v.SetDefault("federation.state_ttl", "10m")
 
 	// Попытка прочитать файл конфигурации (не критично если нет)
 	if err := v.ReadInConfig(); err != nil {
@@ -104,10 +425,99 @@ func Load() (*Config, error) {
 		refreshDuration = 7 * 24 * time.Hour
 	}
 
+	// Предыдущие ключи задаются только в файле конфигурации (jwt.previous_keys)
+	var previousKeys []struct {
+		Algorithm      string `mapstructure:"algorithm"`
+		SecretKey      string `mapstructure:"secret_key"`
+		PrivateKeyPath string `mapstructure:"private_key_path"`
+		KeyID          string `mapstructure:"key_id"`
+		RetiredAt      string `mapstructure:"retired_at"`
+	}
+	if err := v.UnmarshalKey("jwt.previous_keys", &previousKeys); err != nil {
+		return nil, fmt.Errorf("error reading jwt.previous_keys: %w", err)
+	}
+
+	prevKeys := make([]JWTKeyConfig, 0, len(previousKeys))
+	for _, k := range previousKeys {
+		key := JWTKeyConfig{
+			Algorithm:      k.Algorithm,
+			SecretKey:      k.SecretKey,
+			PrivateKeyPath: k.PrivateKeyPath,
+			KeyID:          k.KeyID,
+		}
+		// Без retired_at срок ключа нельзя отсчитать одинаково после рестарта
+		if k.RetiredAt == "" {
+			return nil, fmt.Errorf("retired_at is required for jwt key %q", k.KeyID)
+		}
+		if key.RetiredAt, err = time.Parse(time.RFC3339, k.RetiredAt); err != nil {
+			return nil, fmt.Errorf("invalid retired_at for jwt key %q: %w", k.KeyID, err)
+		}
+		prevKeys = append(prevKeys, key)
+	}
+
+	// Провайдеры задаются только в файле конфигурации: federation.providers.<имя>
+	var identityProviders map[string]struct {
+		Type         string   `mapstructure:"type"`
+		Issuer       string   `mapstructure:"issuer"`
+		ClientID     string   `mapstructure:"client_id"`
+		ClientSecret string   `mapstructure:"client_secret"`
+		Scopes       []string `mapstructure:"scopes"`
+	}
+	if err := v.UnmarshalKey("federation.providers", &identityProviders); err != nil {
+		return nil, fmt.Errorf("error reading federation.providers: %w", err)
+	}
+	providers := make(map[string]IdentityProviderConfig, len(identityProviders))
+	for name, p := range identityProviders {
+		providers[strings.ToLower(name)] = IdentityProviderConfig(p)
+	}
+
+	var methodLimits map[string]struct {
+		PerIP       rateLimitValues `mapstructure:"per_ip"`
+		PerUser     rateLimitValues `mapstructure:"per_user"`
+		PerUsername rateLimitValues `mapstructure:"per_username"`
+	}
+	if err := v.UnmarshalKey("rate_limit.methods", &methodLimits); err != nil {
+		return nil, fmt.Errorf("error reading rate_limit.methods: %w", err)
+	}
+
+	// viper приводит ключи к нижнему регистру, имена методов сравниваются без учёта регистра
+	methods := make(map[string]MethodRateLimit, len(methodLimits))
+	for name, m := range methodLimits {
+		methods[strings.ToLower(name)] = MethodRateLimit{
+			PerIP:       RateLimit(m.PerIP),
+			PerUser:     RateLimit(m.PerUser),
+			PerUsername: RateLimit(m.PerUsername),
+		}
+	}
+
+	// Только из файла конфигурации: rbac.method_permissions.<метод>: [разрешения]
+	methodPermissions := make(map[string][]string)
+	for method := range v.GetStringMap("rbac.method_permissions") {
+		methodPermissions[strings.ToLower(method)] = v.GetStringSlice("rbac.method_permissions." + method)
+	}
+
+	// В переменных окружения списки перечисляются через запятую
+	rpOrigins := splitList(v.GetStringSlice("webauthn.rp_origins"))
+	kafkaBrokers := splitList(v.GetStringSlice("outbox.kafka_brokers"))
+
 	cfg := &Config{
 		Server: ServerConfig{
-			GRPCPort: v.GetString("server.grpc_port"),
-			Host:     v.GetString("server.host"),
+			GRPCPort:    v.GetString("server.grpc_port"),
+			HTTPPort:    v.GetString("server.http_port"),
+			GatewayPort: v.GetString("server.gateway_port"),
+			Host:        v.GetString("server.host"),
+			CORSOrigins: splitList(v.GetStringSlice("server.cors_origins")),
+			SessionCookie: SessionCookieConfig{
+				Name:       v.GetString("server.session_cookie.name"),
+				Path:       v.GetString("server.session_cookie.path"),
+				Domain:     v.GetString("server.session_cookie.domain"),
+				Secure:     v.GetBool("server.session_cookie.secure"),
+				SameSite:   v.GetString("server.session_cookie.same_site"),
+				CSRFCookie: v.GetString("server.session_cookie.csrf_cookie"),
+				CSRFHeader: v.GetString("server.session_cookie.csrf_header"),
+
+				FederationCookie: v.GetString("server.session_cookie.federation_cookie"),
+			},
 		},
 		Database: DatabaseConfig{
 			Host:     v.GetString("database.host"),
@@ -120,18 +530,114 @@ func Load() (*Config, error) {
 			MinConns: v.GetInt32("database.min_conns"),
 		},
 		JWT: JWTConfig{
-			SecretKey:            v.GetString("jwt.secret_key"),
+			JWTKeyConfig: JWTKeyConfig{
+				Algorithm:      v.GetString("jwt.algorithm"),
+				SecretKey:      v.GetString("jwt.secret_key"),
+				PrivateKeyPath: v.GetString("jwt.private_key_path"),
+				KeyID:          v.GetString("jwt.key_id"),
+			},
+			PreviousKeys:         prevKeys,
 			AccessTokenDuration:  accessDuration,
 			RefreshTokenDuration: refreshDuration,
 		},
-        RateLimit: RateLimitConfig{
-            RequestsPerSecond: v.GetInt("rate_limit.requests_per_second"),
-        },
+		RateLimit: RateLimitConfig{
+			RequestsPerSecond: v.GetInt("rate_limit.requests_per_second"),
+			Burst:             v.GetInt("rate_limit.burst"),
+			Methods:           methods,
+		},
+		Lockout: LockoutConfig{
+			AccountThreshold: v.GetInt("lockout.account_threshold"),
+			IPThreshold:      v.GetInt("lockout.ip_threshold"),
+			Window:           v.GetDuration("lockout.window"),
+			BaseDuration:     v.GetDuration("lockout.base_duration"),
+			MaxDuration:      v.GetDuration("lockout.max_duration"),
+			ResetAfter:       v.GetDuration("lockout.reset_after"),
+		},
+		Revocation: RevocationConfig{
+			Store:         v.GetString("revocation.store"),
+			PurgeInterval: v.GetDuration("revocation.purge_interval"),
+		},
+		Retention: RetentionConfig{
+			PurgeInterval: v.GetDuration("retention.purge_interval"),
+		},
+		Mail: MailConfig{
+			Driver:       v.GetString("mail.driver"),
+			From:         v.GetString("mail.from"),
+			SMTPHost:     v.GetString("mail.smtp_host"),
+			SMTPPort:     v.GetString("mail.smtp_port"),
+			SMTPUsername: v.GetString("mail.smtp_username"),
+			SMTPPassword: v.GetString("mail.smtp_password"),
+			SMTPTimeout:  v.GetDuration("mail.smtp_timeout"),
+			FileDir:      v.GetString("mail.file_dir"),
+		},
+		EmailVerification: EmailVerificationConfig{
+			Required: v.GetBool("email_verification.required"),
+			TokenTTL: v.GetDuration("email_verification.token_ttl"),
+			URL:      v.GetString("email_verification.url"),
+		},
+		PasswordReset: PasswordResetConfig{
+			TokenTTL: v.GetDuration("password_reset.token_ttl"),
+			URL:      v.GetString("password_reset.url"),
+		},
+		MFA: MFAConfig{
+			Issuer:        v.GetString("mfa.issuer"),
+			EncryptionKey: v.GetString("mfa.encryption_key"),
+			ChallengeTTL:  v.GetDuration("mfa.challenge_ttl"),
+		},
+		WebAuthn: WebAuthnConfig{
+			RPID:          v.GetString("webauthn.rp_id"),
+			RPDisplayName: v.GetString("webauthn.rp_display_name"),
+			RPOrigins:     rpOrigins,
+			Timeout:       v.GetDuration("webauthn.timeout"),
+		},
+		RBAC: RBACConfig{
+			MethodPermissions: methodPermissions,
+		},
+		Outbox: OutboxConfig{
+			Publisher:    v.GetString("outbox.publisher"),
+			Topic:        v.GetString("outbox.topic"),
+			FilePath:     v.GetString("outbox.file_path"),
+			KafkaBrokers: kafkaBrokers,
+			NATSURL:      v.GetString("outbox.nats_url"),
+			PollInterval: v.GetDuration("outbox.poll_interval"),
+			BatchSize:    v.GetInt("outbox.batch_size"),
+			Lease:        v.GetDuration("outbox.lease"),
+			Retention:    v.GetDuration("outbox.retention"),
+		},
+		OAuth: OAuthConfig{
+			// Адреса эндпоинтов строятся приписыванием пути
+			Issuer:     strings.TrimSuffix(v.GetString("oauth.issuer"), "/"),
+			ConsentURL: v.GetString("oauth.consent_url"),
+			CodeTTL:    v.GetDuration("oauth.code_ttl"),
+		},
+		Federation: FederationConfig{
+			RedirectURL: v.GetString("federation.redirect_url"),
+			StateTTL:    v.GetDuration("federation.state_ttl"),
+			Providers:   providers,
+		},
 	}
 
 	return cfg, nil
 }
 
+type rateLimitValues struct {
+	RequestsPerSecond float64 `mapstructure:"requests_per_second"`
+	Burst             int     `mapstructure:"burst"`
+}
+
+// splitList разбивает значения по запятым и убирает пустые
+func splitList(values []string) []string {
+	var out []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				out = append(out, item)
+			}
+		}
+	}
+	return out
+}
+
 // GetDSN возвращает строку подключения к БД
 func (c *Config) GetDSN() string {
 	return fmt.Sprintf(
@@ -0,0 +1,67 @@
+package entity
+
+import "time"
+
+// Типы событий безопасности
+const (
+	EventRegister            = "register"
+	EventLogin               = "login"
+	EventTokenRefresh        = "token_refresh"
+	EventLogout              = "logout"
+	EventSessionRevoked      = "session_revoked"
+	EventSessionsRevoked     = "sessions_revoked"
+	EventAccountUnlocked     = "account_unlocked"
+	EventSigningKeyRotated   = "signing_key_rotated"
+	EventRoleCreated         = "role_created"
+	EventPasswordChanged     = "password_changed"
+	EventEmailVerified       = "email_verified"
+	EventPasswordReset       = "password_reset"
+	EventMFAEnabled          = "mfa_enabled"
+	EventMFADisabled         = "mfa_disabled"
+	EventRecoveryCodeUsed    = "recovery_code_used"
+	EventPasskeyRegistered   = "passkey_registered"
+	EventPasskeyLogin        = "passkey_login"
+	EventRoleAssigned        = "role_assigned"
+	EventRoleRevoked         = "role_revoked"
+	EventUserDisabled        = "user_disabled"
+	EventUserEnabled         = "user_enabled"
+	EventUserDeleted         = "user_deleted"
+	EventForcedLogout        = "forced_logout"
+	EventForcedPasswordReset = "forced_password_reset"
+	EventOAuthConsent        = "oauth_consent"
+	EventOAuthToken          = "oauth_token"
+	EventOAuthClientCreated  = "oauth_client_created"
+	EventOAuthClientDeleted  = "oauth_client_deleted"
+	EventFederatedLogin      = "federated_login"
+	EventIdentityLinked      = "identity_linked"
+)
+
+// Результат события
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// AuthEvent — запись журнала событий безопасности
+type AuthEvent struct {
+	ID        string
+	UserID    string
+	ActorID   string // кто выполнил действие; пусто, если запрос без access-токена
+	Type      string
+	Outcome   string
+	ClientIP  string
+	UserAgent string
+	CreatedAt time.Time
+}
+
+// AuthEventFilter — условия и страница для чтения журнала. Записи идут от
+// новых к старым; Before* — последняя запись прошлой страницы
+type AuthEventFilter struct {
+	UserID          string
+	Type            string
+	Since           time.Time
+	Until           time.Time
+	BeforeCreatedAt time.Time
+	BeforeID        string
+	Limit           int
+}
@@ -0,0 +1,23 @@
+package entity
+
+import "time"
+
+// FederatedIdentity — учётная запись внешнего провайдера, привязанная к пользователю
+type FederatedIdentity struct {
+	Provider  string
+	Subject   string // sub из ID-токена провайдера
+	UserID    string
+	Email     string // email у провайдера на момент привязки
+	CreatedAt time.Time
+}
+
+// FederatedLoginState — вход через провайдера между Begin и Complete
+type FederatedLoginState struct {
+	State        string // в БД хранится только SHA-256
+	Provider     string
+	Nonce        string
+	CodeVerifier string // PKCE
+	BindingHash  string // SHA-256 секрета браузера, начавшего вход
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
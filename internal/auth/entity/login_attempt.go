@@ -0,0 +1,11 @@
+package entity
+
+import "time"
+
+// LoginAttempts — неудачные попытки входа по одному ключу (аккаунт или IP)
+type LoginAttempts struct {
+	Key         string
+	Failures    int // подряд в текущем окне
+	Lockouts    int // сколько раз ключ уже блокировался — от этого растёт длительность
+	LockedUntil time.Time
+}
@@ -0,0 +1,16 @@
+package entity
+
+import "time"
+
+// TOTPFactor — подключённый TOTP пользователя
+type TOTPFactor struct {
+	UserID          string
+	SecretEncrypted []byte
+	ConfirmedAt     time.Time // нулевое значение — подключение не подтверждено
+	LastUsedStep    int64
+	CreatedAt       time.Time
+}
+
+func (f *TOTPFactor) Confirmed() bool {
+	return !f.ConfirmedAt.IsZero()
+}
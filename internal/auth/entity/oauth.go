@@ -0,0 +1,71 @@
+package entity
+
+import (
+	"slices"
+	"time"
+)
+
+// Поддерживаемые grant type OAuth 2.0
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+)
+
+// Scope OpenID Connect. openid включает выдачу ID-токена, profile и email
+// открывают соответствующие claims в ID-токене и /userinfo
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// OAuthClient — приложение, которое получает токены через OAuth 2.0
+type OAuthClient struct {
+	ID   string
+	Name string
+	// SecretHash — bcrypt от секрета; пусто у публичного клиента
+	SecretHash   string
+	RedirectURIs []string
+	// Scopes — scope, которые клиент может запросить
+	Scopes     []string
+	GrantTypes []string
+	// FirstParty — собственное приложение, согласие пользователя не спрашивается
+	FirstParty bool
+	// PostLogoutRedirectURIs — куда можно вернуть браузер после выхода
+	PostLogoutRedirectURIs []string
+	CreatedAt              time.Time
+}
+
+// Public — клиент не может хранить секрет (SPA, мобильное приложение)
+func (c *OAuthClient) Public() bool {
+	return c.SecretHash == ""
+}
+
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	return slices.Contains(c.GrantTypes, grantType)
+}
+
+func (c *OAuthClient) AllowsRedirectURI(uri string) bool {
+	return slices.Contains(c.RedirectURIs, uri)
+}
+
+func (c *OAuthClient) AllowsPostLogoutRedirectURI(uri string) bool {
+	return slices.Contains(c.PostLogoutRedirectURIs, uri)
+}
+
+// OAuthAuthorizationCode — одноразовый код, который клиент обменивает на токены
+type OAuthAuthorizationCode struct {
+	Code     string // в БД хранится только SHA-256
+	ClientID string
+	UserID   string
+	// RedirectURI — как передан в запросе авторизации; пусто, если не передавался
+	RedirectURI         string
+	Scopes              []string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	// Nonce — из запроса OpenID Connect, возвращается в ID-токене
+	Nonce     string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// Назначения одноразовых токенов
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+)
+
+// OneTimeToken — токен из письма, который можно использовать один раз
+type OneTimeToken struct {
+	ID        string
+	UserID    string
+	Purpose   string
+	Token     string // в БД хранится только SHA-256 от токена
+	Email     string // адрес, на который отправлен токен
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
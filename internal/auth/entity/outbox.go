@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+// Типы событий жизненного цикла пользователя для других сервисов
+const (
+	OutboxUserRegistered   = "user.registered"
+	OutboxUserEmailChanged = "user.email_changed"
+	OutboxUserDisabled     = "user.disabled"
+	OutboxUserEnabled      = "user.enabled"
+	OutboxUserDeleted      = "user.deleted"
+)
+
+// OutboxEvent — событие, ожидающее доставки. Payload — api.UserEvent в protobuf
+type OutboxEvent struct {
+	ID        string
+	UserID    string
+	Type      string
+	Payload   []byte
+	Attempts  int
+	CreatedAt time.Time
+}
@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+// Role — именованный набор разрешений
+type Role struct {
+	Name        string
+	Description string
+	Permissions []string
+	CreatedAt   time.Time
+}
+
+// Разрешения, которые проверяет сам сервис; список в таблице permissions может быть шире
+const (
+	PermissionUsersRead        = "users:read"
+	PermissionUsersWrite       = "users:write"
+	PermissionUsersDelete      = "users:delete"
+	PermissionSessionsManage   = "sessions:manage"
+	PermissionKeysRotate       = "keys:rotate"
+	PermissionAccountsUnlock   = "accounts:unlock"
+	PermissionRolesManage      = "roles:manage"
+	PermissionAuditRead        = "audit:read"
+	PermissionClientsManage    = "clients:manage"
+	PermissionTokensIntrospect = "tokens:introspect"
+)
@@ -3,23 +3,92 @@ package entity
 import "time"
 
 type User struct {
-	ID        string
-	Username  string
-	Name      string
-	Email     string
-	Age       int32
-	Bio       string
-	Password  string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID       string
+	Username string
+	Name     string
+	Email    string
+	Age      int32
+	Bio      string
+	Password string
+	Roles    []string
+	// EmailVerifiedAt — момент подтверждения email; нулевое значение — не подтверждён
+	EmailVerifiedAt time.Time
+	// DisabledAt — когда администратор отключил пользователя; нулевое значение — активен
+	DisabledAt time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
 }
 
+func (u *User) EmailVerified() bool {
+	return !u.EmailVerifiedAt.IsZero()
+}
+
+func (u *User) Disabled() bool {
+	return !u.DisabledAt.IsZero()
+}
+
+// Встроенные роли; RoleUser назначается при регистрации
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// UserFilter — условия и страница для списка пользователей. Страницы
+// упорядочены по (CreatedAt, ID); After* — последний пользователь прошлой страницы
+type UserFilter struct {
+	UsernamePrefix string
+	EmailPrefix    string
+	CreatedAfter   time.Time
+	CreatedBefore  time.Time
+	AfterCreatedAt time.Time
+	AfterID        string
+	Limit          int
+}
+
+// UserProfileUpdate — частичное обновление профиля: nil-поля не меняются
+type UserProfileUpdate struct {
+	Name  *string
+	Email *string
+	Age   *int32
+	Bio   *string
+}
 
 type RefreshToken struct {
-    ID        string
-    UserID    string
-    Token     string
-    ExpiresAt time.Time
-    CreatedAt time.Time
-    Revoked   bool
-}
\ No newline at end of file
+	ID          string
+	UserID      string
+	Token       string // в БД хранится только SHA-256 от токена
+	FamilyID    string // общий для всех токенов, полученных ротацией от одного логина
+	ParentID    string // токен, в обмен на который выдан этот; пусто для первого в семействе
+	ClientIP    string
+	UserAgent   string
+	DeviceLabel string
+	// ClientID и Scopes — для токенов, выданных OAuth-клиенту; пусто при обычном входе
+	ClientID   string
+	Scopes     []string
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	Revoked    bool
+}
+
+// Session — активный вход пользователя, т.е. семейство refresh-токенов.
+// ID сессии совпадает с FamilyID
+type Session struct {
+	ID          string
+	UserID      string
+	ClientIP    string
+	UserAgent   string
+	DeviceLabel string
+	CreatedAt   time.Time // время логина
+	LastUsedAt  time.Time
+	ExpiresAt   time.Time
+	Current     bool // сессия, из которой выполнен запрос
+}
+
+// AccessToken — поля access-токена, по которым проверяется его отзыв
+type AccessToken struct {
+	ID        string // jti
+	SessionID string // sid, совпадает с ID сессии
+	UserID    string
+	IssuedAt  time.Time // нулевое — iat нет
+}
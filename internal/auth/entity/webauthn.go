@@ -0,0 +1,35 @@
+package entity
+
+import "time"
+
+// Назначения церемоний WebAuthn
+const (
+	WebAuthnRegistration = "registration"
+	WebAuthnLogin        = "login"
+)
+
+// WebAuthnCredential — ключ доступа (passkey) пользователя
+type WebAuthnCredential struct {
+	ID              string
+	UserID          string
+	CredentialID    []byte
+	PublicKey       []byte
+	AttestationType string
+	Transports      []string
+	AAGUID          []byte
+	SignCount       uint32
+	BackupEligible  bool
+	BackupState     bool
+	Name            string
+	CreatedAt       time.Time
+	LastUsedAt      time.Time
+}
+
+// WebAuthnSession — состояние церемонии между Begin* и Finish*
+type WebAuthnSession struct {
+	ID        string
+	UserID    string // пусто для входа
+	Purpose   string
+	Data      []byte
+	ExpiresAt time.Time
+}
@@ -0,0 +1,123 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/oauth2"
+
+	"auth-micro/internal/auth/config"
+)
+
+type githubProvider struct {
+	oauth2 *oauth2.Config
+	apiURL string
+	client *http.Client
+}
+
+// NewGitHubProvider — вход через GitHub. GitHub не выдаёт ID-токены, поэтому
+// пользователь и его email берутся из REST API по access-токену, а nonce не
+// используется: ответ провайдера связывают с входом state и PKCE
+func NewGitHubProvider(cfg config.IdentityProviderConfig, redirectURL string) IdentityProvider {
+	webURL, apiURL := "https://github.com", "https://api.github.com"
+	if cfg.Issuer != "" {
+		// GitHub Enterprise Server
+		webURL = strings.TrimSuffix(cfg.Issuer, "/")
+		apiURL = webURL + "/api/v3"
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"read:user", "user:email"}
+	}
+	return &githubProvider{
+		oauth2: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:   webURL + "/login/oauth/authorize",
+				TokenURL:  webURL + "/login/oauth/access_token",
+				AuthStyle: oauth2.AuthStyleInParams,
+			},
+			RedirectURL: redirectURL,
+			Scopes:      cfg.Scopes,
+		},
+		apiURL: apiURL,
+		client: &http.Client{Timeout: httpTimeout},
+	}
+}
+
+func (p *githubProvider) AuthCodeURL(_ context.Context, state, _, codeChallenge string) (string, error) {
+	return p.oauth2.AuthCodeURL(state,
+		oauth2.SetAuthURLParam("code_challenge", codeChallenge),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	), nil
+}
+
+func (p *githubProvider) Exchange(ctx context.Context, code, codeVerifier, _ string) (*Identity, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := p.oauth2.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("code exchange failed: %w", err)
+	}
+	client := p.oauth2.Client(ctx, token)
+
+	var user struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+		Name  string `json:"name"`
+	}
+	if err := p.get(ctx, client, "/user", &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, fmt.Errorf("github returned no user id")
+	}
+
+	// email в /user — публичный адрес профиля, его подтверждение там не видно
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.get(ctx, client, "/user/emails", &emails); err != nil {
+		return nil, err
+	}
+
+	identity := &Identity{
+		Subject:  strconv.FormatInt(user.ID, 10),
+		Name:     user.Name,
+		Username: user.Login,
+	}
+	for _, e := range emails {
+		if e.Primary {
+			identity.Email, identity.EmailVerified = e.Email, e.Verified
+			break
+		}
+	}
+	return identity, nil
+}
+
+func (p *githubProvider) get(ctx context.Context, client *http.Client, path string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("github request %s failed: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("github request %s failed: %s", path, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid github response for %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package federation_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/oauth2"
+
+	"auth-micro/internal/auth/config"
+	"auth-micro/internal/auth/federation"
+)
+
+func TestGitHubProviderExchange(t *testing.T) {
+	ctx := context.Background()
+	gh := newTestGitHub(t, []map[string]any{
+		{"email": "octocat@users.noreply.example", "primary": false, "verified": true},
+		{"email": "octocat@example.com", "primary": true, "verified": true},
+	})
+	provider := gh.provider(t)
+
+	verifier := oauth2.GenerateVerifier()
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", oauth2.S256ChallengeFromVerifier(verifier))
+	if err != nil {
+		t.Fatalf("auth code url: %v", err)
+	}
+	code, state := gh.login(t, authURL)
+	if state != "state-1" {
+		t.Fatalf("state = %q, want state-1", state)
+	}
+
+	identity, err := provider.Exchange(ctx, code, verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	want := &federation.Identity{
+		Subject:       "583231",
+		Email:         "octocat@example.com",
+		EmailVerified: true,
+		Name:          "The Octocat",
+		Username:      "octocat",
+	}
+	if !reflect.DeepEqual(identity, want) {
+		t.Fatalf("identity = %+v, want %+v", identity, want)
+	}
+
+	if _, err := provider.Exchange(ctx, code, verifier, "nonce-1"); err == nil {
+		t.Fatal("exchange accepted a used code")
+	}
+}
+
+func TestGitHubProviderUnverifiedPrimaryEmail(t *testing.T) {
+	ctx := context.Background()
+	gh := newTestGitHub(t, []map[string]any{
+		{"email": "octocat@example.com", "primary": true, "verified": false},
+		{"email": "octocat@verified.example", "primary": false, "verified": true},
+	})
+	provider := gh.provider(t)
+
+	verifier := oauth2.GenerateVerifier()
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "", oauth2.S256ChallengeFromVerifier(verifier))
+	if err != nil {
+		t.Fatalf("auth code url: %v", err)
+	}
+	code, _ := gh.login(t, authURL)
+
+	identity, err := provider.Exchange(ctx, code, verifier, "")
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if identity.Email != "octocat@example.com" || identity.EmailVerified {
+		t.Fatalf("identity = %+v, want unverified primary email", identity)
+	}
+}
+
+func TestNewRejectsUnknownProviderType(t *testing.T) {
+	cfg := &config.Config{Federation: config.FederationConfig{
+		Providers: map[string]config.IdentityProviderConfig{"corp": {Type: "saml", ClientID: "id"}},
+	}}
+	if _, err := federation.New(cfg); err == nil {
+		t.Fatal("New accepted an unknown provider type")
+	}
+}
+
+// testGitHub — GitHub Enterprise Server на httptest: обмен кода и REST API
+type testGitHub struct {
+	*httptest.Server
+	emails []map[string]any
+
+	mu     sync.Mutex
+	codes  map[string]string // code → code_challenge
+	tokens map[string]bool
+}
+
+func newTestGitHub(t *testing.T, emails []map[string]any) *testGitHub {
+	t.Helper()
+	g := &testGitHub{emails: emails, codes: map[string]string{}, tokens: map[string]bool{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /login/oauth/access_token", g.accessToken)
+	mux.HandleFunc("GET /api/v3/user", g.authorized(func(w http.ResponseWriter) {
+		writeJSON(w, http.StatusOK, map[string]any{"id": 583231, "login": "octocat", "name": "The Octocat"})
+	}))
+	mux.HandleFunc("GET /api/v3/user/emails", g.authorized(func(w http.ResponseWriter) {
+		writeJSON(w, http.StatusOK, g.emails)
+	}))
+	g.Server = httptest.NewServer(mux)
+	t.Cleanup(g.Close)
+	return g
+}
+
+// provider создаётся через federation.New, как из конфигурации
+func (g *testGitHub) provider(t *testing.T) federation.IdentityProvider {
+	t.Helper()
+	cfg := &config.Config{Federation: config.FederationConfig{
+		RedirectURL: testRedirectURL,
+		Providers: map[string]config.IdentityProviderConfig{"github": {
+			Type:         federation.TypeGitHub,
+			Issuer:       g.URL,
+			ClientID:     testClientID,
+			ClientSecret: testClientSecret,
+		}},
+	}}
+	providers, err := federation.New(cfg)
+	if err != nil {
+		t.Fatalf("providers: %v", err)
+	}
+	return providers["github"]
+}
+
+// login проходит страницу входа GitHub и возвращает code и state редиректа
+func (g *testGitHub) login(t *testing.T, authURL string) (code, state string) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("auth url: %v", err)
+	}
+	q := u.Query()
+	if u.Path != "/login/oauth/authorize" || q.Get("client_id") != testClientID || q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected auth url %s", authURL)
+	}
+
+	code = oauth2.GenerateVerifier()
+	g.mu.Lock()
+	g.codes[code] = q.Get("code_challenge")
+	g.mu.Unlock()
+	return code, q.Get("state")
+}
+
+// accessToken, как GitHub, сообщает об ошибке в теле ответа со статусом 200
+func (g *testGitHub) accessToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.PostForm.Get("client_id") != testClientID || r.PostForm.Get("client_secret") != testClientSecret {
+		writeJSON(w, http.StatusOK, map[string]string{"error": "incorrect_client_credentials"})
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	challenge, ok := g.codes[r.PostForm.Get("code")]
+	delete(g.codes, r.PostForm.Get("code"))
+	if !ok || oauth2.S256ChallengeFromVerifier(r.PostForm.Get("code_verifier")) != challenge {
+		writeJSON(w, http.StatusOK, map[string]string{"error": "bad_verification_code"})
+		return
+	}
+	token := oauth2.GenerateVerifier()
+	g.tokens[token] = true
+	writeJSON(w, http.StatusOK, map[string]string{"access_token": token, "token_type": "bearer", "scope": "read:user,user:email"})
+}
+
+func (g *testGitHub) authorized(next func(http.ResponseWriter)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		g.mu.Lock()
+		ok = ok && g.tokens[token]
+		g.mu.Unlock()
+		if !ok {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "Bad credentials"})
+			return
+		}
+		next(w)
+	}
+}
@@ -0,0 +1,365 @@
+package federation_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"auth-micro/internal/auth/config"
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/federation"
+	"auth-micro/internal/auth/repository"
+	"auth-micro/internal/auth/repository/memory"
+	"auth-micro/internal/auth/service"
+	"auth-micro/internal/auth/utils"
+)
+
+// Вход через провайдера целиком: BeginFederatedLogin → страница входа
+// testIssuer → CompleteFederatedLogin
+
+func TestFederatedLoginLinksVerifiedEmail(t *testing.T) {
+	env := newLoginEnv(t)
+	alice := env.users.add(&entity.User{
+		ID:              "5a0e7c1d-2b3f-4e6a-8c9d-0f1e2d3c4b5a",
+		Username:        "alice",
+		Email:           "alice@example.com",
+		EmailVerifiedAt: time.Now(),
+	})
+
+	// Адрес у провайдера в другом регистре — тот же email
+	result, err := env.login(t, map[string]any{"sub": "1001", "email": "Alice@Example.com", "email_verified": true})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	env.wantSession(t, result, alice.ID)
+	if link := env.links.identity("1001"); link == nil || link.UserID != alice.ID {
+		t.Fatalf("identity linked to %+v, want user %s", link, alice.ID)
+	}
+
+	// Следующий вход находит пользователя по привязке, даже если email у провайдера сменился
+	result, err = env.login(t, map[string]any{"sub": "1001", "email": "alice@another.example", "email_verified": true})
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	env.wantSession(t, result, alice.ID)
+	if n := env.users.count(); n != 1 {
+		t.Fatalf("%d users, want 1", n)
+	}
+}
+
+func TestFederatedLoginRefusesUnverifiedEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		existing *entity.User
+		claims   map[string]any
+		want     error
+	}{
+		{
+			name:     "provider has not verified the email of an existing user",
+			existing: &entity.User{Username: "alice", Email: "alice@example.com", EmailVerifiedAt: time.Now()},
+			claims:   map[string]any{"sub": "1001", "email": "alice@example.com", "email_verified": false},
+			want:     service.ErrFederatedEmailNotVerified,
+		},
+		{
+			name:   "provider has not verified the email of a new user",
+			claims: map[string]any{"sub": "1001", "email": "bob@example.com"},
+			want:   service.ErrFederatedEmailNotVerified,
+		},
+		{
+			name:     "local account has not verified the email",
+			existing: &entity.User{Username: "squatter", Email: "alice@example.com"},
+			claims:   map[string]any{"sub": "1001", "email": "alice@example.com", "email_verified": true},
+			want:     service.ErrFederatedEmailConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newLoginEnv(t)
+			users := 0
+			if tt.existing != nil {
+				tt.existing.ID = "0c9b8a7d-6e5f-4a3b-2c1d-0e9f8a7b6c5d"
+				env.users.add(tt.existing)
+				users = 1
+			}
+
+			if _, err := env.login(t, tt.claims); !errors.Is(err, tt.want) {
+				t.Fatalf("login: err = %v, want %v", err, tt.want)
+			}
+			if link := env.links.identity("1001"); link != nil {
+				t.Fatalf("identity linked to user %s", link.UserID)
+			}
+			if n := env.users.count(); n != users {
+				t.Fatalf("%d users, want %d", n, users)
+			}
+		})
+	}
+}
+
+func TestFederatedLoginRegistersNewUser(t *testing.T) {
+	env := newLoginEnv(t)
+	env.users.add(&entity.User{ID: "1e2d3c4b-5a69-4788-9a0b-c1d2e3f4a5b6", Username: "carol", Email: "carol@old.example"})
+
+	result, err := env.login(t, map[string]any{
+		"sub":                "2002",
+		"email":              "carol@example.com",
+		"email_verified":     true,
+		"name":               "Carol",
+		"preferred_username": "Carol",
+	})
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	link := env.links.identity("2002")
+	if link == nil {
+		t.Fatal("identity is not linked")
+	}
+	user := env.users.get(link.UserID)
+	if user == nil || user.Email != "carol@example.com" || user.Name != "Carol" || !user.EmailVerified() {
+		t.Fatalf("registered user = %+v", user)
+	}
+	// username carol уже занят — выбран свободный с суффиксом
+	if !strings.HasPrefix(user.Username, "carol-") {
+		t.Fatalf("username = %q, want carol-NNNN", user.Username)
+	}
+	env.wantSession(t, result, user.ID)
+}
+
+// Завершить вход может только браузер, который его начал
+func TestFederatedLoginRequiresBrowserBinding(t *testing.T) {
+	env := newLoginEnv(t)
+
+	authURL, _, err := env.svc.BeginFederatedLogin(context.Background(), "test")
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	code, state := env.issuer.login(t, authURL, map[string]any{"sub": "1001", "email": "alice@example.com", "email_verified": true})
+
+	_, attackerBinding, err := env.svc.BeginFederatedLogin(context.Background(), "test")
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if _, err := env.svc.CompleteFederatedLogin(context.Background(), state, code, attackerBinding); !errors.Is(err, service.ErrInvalidFederatedState) {
+		t.Fatalf("complete with another binding: err = %v, want ErrInvalidFederatedState", err)
+	}
+	if n := env.users.count(); n != 0 {
+		t.Fatalf("%d users, want 0", n)
+	}
+}
+
+type loginEnv struct {
+	issuer *testIssuer
+	svc    service.UserService
+	users  *fakeUsers
+	links  *fakeFederation
+	jwt    *utils.JWTManager
+}
+
+func newLoginEnv(t *testing.T) *loginEnv {
+	t.Helper()
+	issuer := newTestIssuer(t)
+
+	cfg := &config.Config{
+		JWT: config.JWTConfig{
+			JWTKeyConfig:         config.JWTKeyConfig{Algorithm: utils.AlgHS256, SecretKey: "federation-test-secret"},
+			AccessTokenDuration:  15 * time.Minute,
+			RefreshTokenDuration: time.Hour,
+		},
+		Federation: config.FederationConfig{
+			RedirectURL: testRedirectURL,
+			StateTTL:    time.Minute,
+			Providers:   map[string]config.IdentityProviderConfig{"test": issuer.config()},
+		},
+	}
+	jwtManager, err := utils.NewJWTManager(cfg)
+	if err != nil {
+		t.Fatalf("jwt manager: %v", err)
+	}
+	providers, err := federation.New(cfg)
+	if err != nil {
+		t.Fatalf("providers: %v", err)
+	}
+
+	users := &fakeUsers{users: map[string]*entity.User{}}
+	links := &fakeFederation{
+		identities: map[string]*entity.FederatedIdentity{},
+		states:     map[string]*entity.FederatedLoginState{},
+	}
+	svc := service.NewUserService(
+		users, memory.NewTokenDenylist(), nil, fakeRoles{}, discardEvents{}, nil,
+		nil, fakeMFA{}, nil, nil, nil, nil, links, providers, nil, jwtManager, cfg,
+	)
+	return &loginEnv{issuer: issuer, svc: svc, users: users, links: links, jwt: jwtManager}
+}
+
+// login проходит вход через провайдера; claims попадают в его ID-токен
+func (e *loginEnv) login(t *testing.T, claims map[string]any) (*service.LoginResult, error) {
+	t.Helper()
+	ctx := context.Background()
+	authURL, binding, err := e.svc.BeginFederatedLogin(ctx, "test")
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	code, state := e.issuer.login(t, authURL, claims)
+	return e.svc.CompleteFederatedLogin(ctx, state, code, binding)
+}
+
+// wantSession проверяет, что вход выдал токены пользователю userID
+func (e *loginEnv) wantSession(t *testing.T, result *service.LoginResult, userID string) {
+	t.Helper()
+	if result.MFARequired || result.Tokens == nil {
+		t.Fatalf("login result = %+v, want tokens", result)
+	}
+	claims, err := e.jwt.ValidateToken(result.Tokens.AccessToken)
+	if err != nil || claims.UserID != userID {
+		t.Fatalf("access token for %q (%v), want %q", claims.UserID, err, userID)
+	}
+}
+
+// fakeUsers хранит пользователей и refresh-токены в памяти; остальные
+// методы репозитория сценарию не нужны
+type fakeUsers struct {
+	repository.UserRepository
+
+	mu     sync.Mutex
+	users  map[string]*entity.User
+	tokens []*entity.RefreshToken
+}
+
+func (r *fakeUsers) add(u *entity.User) *entity.User {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[u.ID] = u
+	return u
+}
+
+func (r *fakeUsers) get(id string) *entity.User {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.users[id]
+}
+
+func (r *fakeUsers) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.users)
+}
+
+func (r *fakeUsers) Create(_ context.Context, u *entity.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[u.ID] = u
+	return nil
+}
+
+func (r *fakeUsers) GetByID(_ context.Context, id string) (*entity.User, error) {
+	return r.get(id), nil
+}
+
+func (r *fakeUsers) GetByUsername(_ context.Context, username string) (*entity.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUsers) GetByEmail(_ context.Context, email string) (*entity.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if strings.EqualFold(u.Email, email) {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUsers) MarkEmailVerified(_ context.Context, userID, email string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u := r.users[userID]
+	if u == nil || u.Email != email {
+		return false, nil
+	}
+	u.EmailVerifiedAt = time.Now()
+	return true, nil
+}
+
+func (r *fakeUsers) SaveRefreshToken(_ context.Context, rt *entity.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens = append(r.tokens, rt)
+	return nil
+}
+
+type fakeFederation struct {
+	mu         sync.Mutex
+	identities map[string]*entity.FederatedIdentity // по subject
+	states     map[string]*entity.FederatedLoginState
+}
+
+func (r *fakeFederation) identity(subject string) *entity.FederatedIdentity {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.identities[subject]
+}
+
+func (r *fakeFederation) GetIdentity(_ context.Context, _, subject string) (*entity.FederatedIdentity, error) {
+	return r.identity(subject), nil
+}
+
+func (r *fakeFederation) LinkIdentity(_ context.Context, i *entity.FederatedIdentity) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.identities[i.Subject] = i
+	return nil
+}
+
+func (r *fakeFederation) SaveLoginState(_ context.Context, s *entity.FederatedLoginState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.states[s.State] = s
+	return nil
+}
+
+func (r *fakeFederation) ConsumeLoginState(_ context.Context, state string) (*entity.FederatedLoginState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.states[state]
+	delete(r.states, state)
+	if s == nil || time.Now().After(s.ExpiresAt) {
+		return nil, nil
+	}
+	return s, nil
+}
+
+// fakeMFA — у пользователей нет второго фактора
+type fakeMFA struct {
+	repository.MFARepository
+}
+
+func (fakeMFA) GetTOTP(context.Context, string) (*entity.TOTPFactor, error) {
+	return nil, nil
+}
+
+type fakeRoles struct {
+	repository.RoleRepository
+}
+
+func (fakeRoles) UserPermissions(context.Context, string) ([]string, error) {
+	return nil, nil
+}
+
+type discardEvents struct{}
+
+func (discardEvents) Record(context.Context, *entity.AuthEvent) error {
+	return nil
+}
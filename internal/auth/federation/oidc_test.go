@@ -0,0 +1,280 @@
+package federation_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+
+	"auth-micro/internal/auth/config"
+	"auth-micro/internal/auth/federation"
+)
+
+const (
+	testClientID     = "federation-client"
+	testClientSecret = "federation-secret"
+	testRedirectURL  = "https://app.example/login/callback"
+	testKeyID        = "issuer-key"
+)
+
+func TestOIDCProviderExchange(t *testing.T) {
+	ctx := context.Background()
+	issuer := newTestIssuer(t)
+	provider := federation.NewOIDCProvider(issuer.config(), testRedirectURL)
+
+	verifier := oauth2.GenerateVerifier()
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", oauth2.S256ChallengeFromVerifier(verifier))
+	if err != nil {
+		t.Fatalf("auth code url: %v", err)
+	}
+	code, state := issuer.login(t, authURL, map[string]any{
+		"sub":                "1001",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"name":               "Alice",
+		"preferred_username": "alice",
+	})
+	if state != "state-1" {
+		t.Fatalf("state = %q, want state-1", state)
+	}
+
+	identity, err := provider.Exchange(ctx, code, verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	want := &federation.Identity{
+		Subject:       "1001",
+		Email:         "alice@example.com",
+		EmailVerified: true,
+		Name:          "Alice",
+		Username:      "alice",
+	}
+	if !reflect.DeepEqual(identity, want) {
+		t.Fatalf("identity = %+v, want %+v", identity, want)
+	}
+}
+
+func TestOIDCProviderRejectsInvalidResponse(t *testing.T) {
+	foreignKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		claims map[string]any
+		// nonce и verifier, с которыми клиент завершает вход
+		nonce    string
+		verifier func(issued string) string
+		setup    func(*testIssuer)
+	}{
+		{
+			name:  "nonce mismatch",
+			nonce: "nonce-of-another-login",
+		},
+		{
+			name:  "bad signature",
+			setup: func(i *testIssuer) { i.signKey = foreignKey },
+		},
+		{
+			name:   "other audience",
+			claims: map[string]any{"aud": "another-client"},
+		},
+		{
+			name:     "wrong code verifier",
+			verifier: func(string) string { return oauth2.GenerateVerifier() },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			issuer := newTestIssuer(t)
+			if tt.setup != nil {
+				tt.setup(issuer)
+			}
+			provider := federation.NewOIDCProvider(issuer.config(), testRedirectURL)
+
+			verifier := oauth2.GenerateVerifier()
+			authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", oauth2.S256ChallengeFromVerifier(verifier))
+			if err != nil {
+				t.Fatalf("auth code url: %v", err)
+			}
+			claims := map[string]any{"sub": "1001", "email": "alice@example.com", "email_verified": true}
+			for k, v := range tt.claims {
+				claims[k] = v
+			}
+			code, _ := issuer.login(t, authURL, claims)
+
+			nonce := "nonce-1"
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+			if tt.verifier != nil {
+				verifier = tt.verifier(verifier)
+			}
+			if identity, err := provider.Exchange(ctx, code, verifier, nonce); err == nil {
+				t.Fatalf("exchange accepted the response: %+v", identity)
+			}
+		})
+	}
+}
+
+// testIssuer — провайдер OpenID Connect на httptest: discovery, JWKS и token
+// endpoint. Страницу входа заменяет login
+type testIssuer struct {
+	*httptest.Server
+	key *ecdsa.PrivateKey
+	// signKey подписывает ID-токены; ключ не из JWKS — подделанная подпись
+	signKey *ecdsa.PrivateKey
+
+	mu     sync.Mutex
+	logins map[string]issuerLogin
+}
+
+// issuerLogin — вход пользователя у провайдера, ждущий обмена кода
+type issuerLogin struct {
+	claims        jwt.MapClaims
+	codeChallenge string
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := &testIssuer{key: key, signKey: key, logins: map[string]issuerLogin{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", i.discovery)
+	mux.HandleFunc("GET /jwks", i.jwks)
+	mux.HandleFunc("POST /token", i.token)
+	i.Server = httptest.NewServer(mux)
+	t.Cleanup(i.Close)
+	return i
+}
+
+func (i *testIssuer) config() config.IdentityProviderConfig {
+	return config.IdentityProviderConfig{Issuer: i.URL, ClientID: testClientID, ClientSecret: testClientSecret}
+}
+
+// login проходит страницу входа провайдера по адресу из AuthCodeURL и
+// возвращает code и state редиректа. В ID-токен попадают claims и nonce запроса
+func (i *testIssuer) login(t *testing.T, authURL string, claims map[string]any) (code, state string) {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("auth url: %v", err)
+	}
+	q := u.Query()
+	if u.Path != "/authorize" || q.Get("client_id") != testClientID || q.Get("redirect_uri") != testRedirectURL {
+		t.Fatalf("unexpected auth url %s", authURL)
+	}
+	if q.Get("code_challenge") == "" || q.Get("code_challenge_method") != "S256" {
+		t.Fatalf("auth url without PKCE: %s", authURL)
+	}
+
+	now := time.Now()
+	idClaims := jwt.MapClaims{
+		"iss":   i.URL,
+		"aud":   testClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Minute).Unix(),
+		"nonce": q.Get("nonce"),
+	}
+	for k, v := range claims {
+		idClaims[k] = v
+	}
+
+	code = oauth2.GenerateVerifier()
+	i.mu.Lock()
+	i.logins[code] = issuerLogin{claims: idClaims, codeChallenge: q.Get("code_challenge")}
+	i.mu.Unlock()
+	return code, q.Get("state")
+}
+
+func (i *testIssuer) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                i.URL,
+		"authorization_endpoint":                i.URL + "/authorize",
+		"token_endpoint":                        i.URL + "/token",
+		"jwks_uri":                              i.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"ES256"},
+	})
+}
+
+func (i *testIssuer) jwks(w http.ResponseWriter, _ *http.Request) {
+	coord := func(n interface{ FillBytes([]byte) []byte }) string {
+		return base64.RawURLEncoding.EncodeToString(n.FillBytes(make([]byte, 32)))
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   coord(i.key.X),
+		"y":   coord(i.key.Y),
+		"kid": testKeyID,
+		"alg": "ES256",
+		"use": "sig",
+	}}})
+}
+
+// token обменивает code на ID-токен, проверяя клиента и PKCE
+func (i *testIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != testClientID || secret != testClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	i.mu.Lock()
+	login, ok := i.logins[r.PostForm.Get("code")]
+	delete(i.logins, r.PostForm.Get("code"))
+	signKey := i.signKey
+	i.mu.Unlock()
+	if !ok || oauth2.S256ChallengeFromVerifier(r.PostForm.Get("code_verifier")) != login.codeChallenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodES256, login.claims)
+	idToken.Header["kid"] = testKeyID
+	signed, err := idToken.SignedString(signKey)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": oauth2.GenerateVerifier(),
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     signed,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
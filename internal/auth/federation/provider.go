@@ -0,0 +1,60 @@
+package federation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"auth-micro/internal/auth/config"
+)
+
+// Identity — пользователь провайдера из проверенного ID-токена или его API
+type Identity struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Username      string // preferred_username, если провайдер его отдаёт
+}
+
+// IdentityProvider — внешний провайдер входа. Для OpenID Connect есть
+// NewOIDCProvider, для GitHub, который не выдаёт ID-токены, — NewGitHubProvider.
+// Другой провайдер без OIDC подключается своей реализацией
+type IdentityProvider interface {
+	// AuthCodeURL возвращает адрес страницы входа провайдера
+	AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error)
+	// Exchange обменивает код на токены провайдера и проверяет ID-токен и nonce,
+	// если провайдер их выдаёт
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Identity, error)
+}
+
+// Типы провайдеров в federation.providers.<имя>.type
+const (
+	TypeOIDC   = "oidc"
+	TypeGitHub = "github"
+)
+
+// Providers — провайдеры по имени, которое передаёт клиент
+type Providers map[string]IdentityProvider
+
+// New создаёт провайдеров из cfg.Federation.Providers
+func New(cfg *config.Config) (Providers, error) {
+	providers := make(Providers, len(cfg.Federation.Providers))
+	for name, p := range cfg.Federation.Providers {
+		switch strings.ToLower(p.Type) {
+		case "", TypeOIDC:
+			if p.Issuer == "" || p.ClientID == "" {
+				return nil, fmt.Errorf("identity provider %q: issuer and client_id are required", name)
+			}
+			providers[name] = NewOIDCProvider(p, cfg.Federation.RedirectURL)
+		case TypeGitHub:
+			if p.ClientID == "" {
+				return nil, fmt.Errorf("identity provider %q: client_id is required", name)
+			}
+			providers[name] = NewGitHubProvider(p, cfg.Federation.RedirectURL)
+		default:
+			return nil, fmt.Errorf("identity provider %q: unknown type %q", name, p.Type)
+		}
+	}
+	return providers, nil
+}
@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/service"
+	auth "auth-micro/pkg/auth_v1"
+	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type adminGRPCHandler struct {
+	auth.UnimplementedAdminServer
+	userService service.UserService
+}
+
+func NewAdminGRPCHandler(s service.UserService) auth.AdminServer {
+	return &adminGRPCHandler{userService: s}
+}
+
+func (h *adminGRPCHandler) ListUsers(ctx context.Context, req *auth.ListUsersRequest) (*auth.ListUsersResponse, error) {
+	input := service.ListUsersInput{
+		UsernamePrefix: req.UsernamePrefix,
+		EmailPrefix:    req.EmailPrefix,
+		PageSize:       int(req.PageSize),
+		PageToken:      req.PageToken,
+	}
+	if req.CreatedAfter != nil {
+		input.CreatedAfter = req.CreatedAfter.AsTime()
+	}
+	if req.CreatedBefore != nil {
+		input.CreatedBefore = req.CreatedBefore.AsTime()
+	}
+
+	users, next, err := h.userService.ListUsers(ctx, input)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	resp := &auth.ListUsersResponse{NextPageToken: next}
+	for _, u := range users {
+		resp.Users = append(resp.Users, toUserInfo(u))
+	}
+	return resp, nil
+}
+
+func (h *adminGRPCHandler) GetUserByID(ctx context.Context, req *auth.GetUserByIDRequest) (*auth.GetUserByIDResponse, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+
+	user, err := h.userService.GetUserInfo(ctx, req.UserId)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.GetUserByIDResponse{User: toUserInfo(user)}, nil
+}
+
+func (h *adminGRPCHandler) DisableUser(ctx context.Context, req *auth.DisableUserRequest) (*auth.DisableUserResponse, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+
+	if err := h.userService.DisableUser(ctx, req.UserId); err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.DisableUserResponse{
+		Success: true,
+		Message: "User disabled",
+	}, nil
+}
+
+func (h *adminGRPCHandler) EnableUser(ctx context.Context, req *auth.EnableUserRequest) (*auth.EnableUserResponse, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+
+	if err := h.userService.EnableUser(ctx, req.UserId); err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.EnableUserResponse{
+		Success: true,
+		Message: "User enabled",
+	}, nil
+}
+
+func (h *adminGRPCHandler) ForceLogout(ctx context.Context, req *auth.ForceLogoutRequest) (*auth.ForceLogoutResponse, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+
+	if err := h.userService.ForceLogout(ctx, req.UserId); err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.ForceLogoutResponse{
+		Success: true,
+		Message: "All sessions revoked",
+	}, nil
+}
+
+func (h *adminGRPCHandler) ForcePasswordReset(ctx context.Context, req *auth.ForcePasswordResetRequest) (*auth.ForcePasswordResetResponse, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+
+	if err := h.userService.ForcePasswordReset(ctx, req.UserId); err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.ForcePasswordResetResponse{
+		Success: true,
+		Message: "Password reset email sent",
+	}, nil
+}
+
+func (h *adminGRPCHandler) DeleteUser(ctx context.Context, req *auth.DeleteUserRequest) (*auth.DeleteUserResponse, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+
+	if err := h.userService.DeleteUser(ctx, req.UserId); err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.DeleteUserResponse{
+		Success: true,
+		Message: "User deleted",
+	}, nil
+}
+
+func (h *adminGRPCHandler) ListAuthEvents(ctx context.Context, req *auth.ListAuthEventsRequest) (*auth.ListAuthEventsResponse, error) {
+	input := service.ListAuthEventsInput{
+		UserID:    req.UserId,
+		Type:      req.Type,
+		PageSize:  int(req.PageSize),
+		PageToken: req.PageToken,
+	}
+	if req.Since != nil {
+		input.Since = req.Since.AsTime()
+	}
+	if req.Until != nil {
+		input.Until = req.Until.AsTime()
+	}
+
+	events, next, err := h.userService.ListAuthEvents(ctx, input)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	resp := &auth.ListAuthEventsResponse{NextPageToken: next}
+	for _, e := range events {
+		resp.Events = append(resp.Events, &auth.AuthEvent{
+			Id:        e.ID,
+			UserId:    e.UserID,
+			ActorId:   e.ActorID,
+			Type:      e.Type,
+			Outcome:   e.Outcome,
+			ClientIp:  e.ClientIP,
+			UserAgent: e.UserAgent,
+			CreatedAt: timestamppb.New(e.CreatedAt),
+		})
+	}
+	return resp, nil
+}
+
+func (h *adminGRPCHandler) CreateOAuthClient(ctx context.Context, req *auth.CreateOAuthClientRequest) (*auth.CreateOAuthClientResponse, error) {
+	client, secret, err := h.userService.CreateOAuthClient(ctx, service.OAuthClientInput{
+		Name:                   req.Name,
+		RedirectURIs:           req.RedirectUris,
+		Scopes:                 req.Scopes,
+		GrantTypes:             req.GrantTypes,
+		Public:                 req.Public,
+		FirstParty:             req.FirstParty,
+		PostLogoutRedirectURIs: req.PostLogoutRedirectUris,
+	})
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.CreateOAuthClientResponse{
+		Client:       toOAuthClient(client),
+		ClientSecret: secret,
+	}, nil
+}
+
+func (h *adminGRPCHandler) ListOAuthClients(ctx context.Context, _ *emptypb.Empty) (*auth.ListOAuthClientsResponse, error) {
+	clients, err := h.userService.ListOAuthClients(ctx)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	resp := &auth.ListOAuthClientsResponse{Clients: make([]*auth.OAuthClient, 0, len(clients))}
+	for _, c := range clients {
+		resp.Clients = append(resp.Clients, toOAuthClient(c))
+	}
+	return resp, nil
+}
+
+func (h *adminGRPCHandler) DeleteOAuthClient(ctx context.Context, req *auth.DeleteOAuthClientRequest) (*auth.DeleteOAuthClientResponse, error) {
+	if req.ClientId == "" {
+		return nil, status.Error(codes.InvalidArgument, "client id is required")
+	}
+
+	if err := h.userService.DeleteOAuthClient(ctx, req.ClientId); err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.DeleteOAuthClientResponse{
+		Success: true,
+		Message: "Client deleted",
+	}, nil
+}
+
+func toOAuthClient(c *entity.OAuthClient) *auth.OAuthClient {
+	return &auth.OAuthClient{
+		ClientId:               c.ID,
+		Name:                   c.Name,
+		RedirectUris:           c.RedirectURIs,
+		Scopes:                 c.Scopes,
+		GrantTypes:             c.GrantTypes,
+		Public:                 c.Public(),
+		FirstParty:             c.FirstParty,
+		CreatedAt:              timestamppb.New(c.CreatedAt),
+		PostLogoutRedirectUris: c.PostLogoutRedirectURIs,
+	}
+}
@@ -0,0 +1,395 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"auth-micro/internal/auth/config"
+	"auth-micro/internal/auth/principal"
+	"auth-micro/internal/auth/utils"
+	auth "auth-micro/pkg/auth_v1"
+)
+
+// maxGatewayBody — ограничение размера JSON-тела запроса
+const maxGatewayBody = 1 << 20
+
+// forwardedHeaders — HTTP-заголовки, которые шлюз передаёт в gRPC metadata
+var forwardedHeaders = []string{"authorization", "user-agent", "x-device-label"}
+
+var (
+	unmarshalOptions = protojson.UnmarshalOptions{DiscardUnknown: true}
+	marshalOptions   = protojson.MarshalOptions{EmitUnpopulated: true}
+)
+
+// Gateway — HTTP/JSON-фронтенд к gRPC API. Запросы вызывают те же
+// обработчики через ту же цепочку интерцепторов, что и gRPC-сервер,
+// поэтому аутентификация, лимиты и проверка разрешений не дублируются
+type Gateway struct {
+	mux         *http.ServeMux
+	interceptor grpc.UnaryServerInterceptor
+	corsOrigins []string
+	cookies     config.SessionCookieConfig
+	// Срок жизни сессионных кук равен сроку refresh-токена
+	jwtManager *utils.JWTManager
+	// Кука входа через провайдера живёт, пока действует его state
+	federationTTL time.Duration
+}
+
+func NewGateway(
+	authServer auth.AuthServer,
+	adminServer auth.AdminServer,
+	interceptor grpc.UnaryServerInterceptor,
+	jwtManager *utils.JWTManager,
+	cfg *config.Config,
+) *Gateway {
+	g := &Gateway{
+		mux:         http.NewServeMux(),
+		interceptor: interceptor,
+		corsOrigins: cfg.Server.CORSOrigins,
+		cookies:     cfg.Server.SessionCookie,
+		jwtManager:  jwtManager,
+
+		federationTTL: cfg.Federation.StateTTL,
+	}
+	g.mux.HandleFunc("GET /v1/csrf", g.csrfToken)
+	g.registerAuthRoutes(authServer)
+	g.registerAdminRoutes(adminServer)
+	return g
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if origin := r.Header.Get("Origin"); origin != "" && slices.Contains(g.corsOrigins, origin) {
+		h := w.Header()
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Allow-Credentials", "true")
+		h.Add("Vary", "Origin")
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			h.Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE")
+			h.Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-Device-Label, "+sessionModeHeader+", "+g.cookies.CSRFHeader)
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.Set("Access-Control-Expose-Headers", "Retry-After, "+g.cookies.CSRFHeader)
+	}
+	if err := g.checkCSRF(r); err != nil {
+		writeError(w, err)
+		return
+	}
+	g.mux.ServeHTTP(w, r)
+}
+
+// routeHook дополняет запрос внутри цепочки, когда principal уже известен
+type routeHook func(ctx context.Context, req protoreflect.Message) error
+
+// route — дополнительная обработка маршрута: prepare выполняется до цепочки
+// интерцепторов, finish — после вызова, перед записью ответа (resp == nil при ошибке)
+type route struct {
+	hooks   []routeHook
+	prepare []func(r *http.Request, req protoreflect.Message) error
+	finish  []func(w http.ResponseWriter, r *http.Request, resp protoreflect.Message, err error) error
+}
+
+type routeOption func(*route)
+
+// pathParam — {имя} в шаблоне маршрута
+var pathParam = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// handle регистрирует маршрут pattern ("POST /v1/login") для gRPC-метода.
+// Поля запроса заполняются из JSON-тела, затем из параметров пути и query
+func handle[Req, Resp proto.Message](g *Gateway, pattern, fullMethod string, call func(context.Context, Req) (Resp, error), opts ...routeOption) {
+	rt := &route{}
+	for _, opt := range opts {
+		opt(rt)
+	}
+
+	var params []string
+	for _, m := range pathParam.FindAllStringSubmatch(pattern, -1) {
+		params = append(params, m[1])
+	}
+
+	g.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		var zero Req
+		req := zero.ProtoReflect().New().Interface().(Req)
+
+		if err := decodeRequest(w, r, req, params); err != nil {
+			writeError(w, err)
+			return
+		}
+		for _, prepare := range rt.prepare {
+			if err := prepare(r, req.ProtoReflect()); err != nil {
+				writeError(w, err)
+				return
+			}
+		}
+
+		stream := &headerStream{method: fullMethod}
+		ctx := grpc.NewContextWithServerTransportStream(gatewayContext(r), stream)
+		info := &grpc.UnaryServerInfo{FullMethod: fullMethod}
+
+		resp, err := g.interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+			for _, hook := range rt.hooks {
+				if err := hook(ctx, req.(Req).ProtoReflect()); err != nil {
+					return nil, err
+				}
+			}
+			return call(ctx, req.(Req))
+		})
+
+		stream.copyTo(w.Header())
+		var msg protoreflect.Message
+		if err == nil {
+			msg = resp.(proto.Message).ProtoReflect()
+		}
+		for _, finish := range rt.finish {
+			if ferr := finish(w, r, msg, err); ferr != nil && err == nil {
+				err = ferr
+			}
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		body, err := marshalOptions.Marshal(msg.Interface())
+		if err != nil {
+			writeError(w, status.Error(codes.Internal, "failed to encode response"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	})
+}
+
+// currentUser подставляет в поле field id вызывающего — для маршрутов /me
+func currentUser(field string) routeOption {
+	return func(rt *route) {
+		rt.hooks = append(rt.hooks, func(ctx context.Context, req protoreflect.Message) error {
+			p, ok := principal.FromContext(ctx)
+			if !ok {
+				return status.Error(codes.Unauthenticated, "access token required")
+			}
+			return setField(req, field, p.UserID)
+		})
+	}
+}
+
+func decodeRequest(w http.ResponseWriter, r *http.Request, req proto.Message, params []string) error {
+	if r.Method != http.MethodGet {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxGatewayBody))
+		if err != nil {
+			return status.Error(codes.InvalidArgument, "failed to read request body")
+		}
+		if len(body) > 0 {
+			if err := unmarshalOptions.Unmarshal(body, req); err != nil {
+				return status.Errorf(codes.InvalidArgument, "invalid request body: %v", err)
+			}
+		}
+	}
+
+	msg := req.ProtoReflect()
+	for _, name := range params {
+		if err := setField(msg, name, r.PathValue(name)); err != nil {
+			return err
+		}
+	}
+	for name, values := range r.URL.Query() {
+		for _, value := range values {
+			// Незнакомые параметры (например, против кэширования) пропускаются
+			if err := setField(msg, name, value); err != nil && !errors.Is(err, errUnknownField) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var errUnknownField = errors.New("unknown field")
+
+// setField присваивает строковое значение полю по JSON- или proto-имени.
+// Поддерживаются скаляры, enum, Timestamp (RFC 3339) и repeated из них
+func setField(msg protoreflect.Message, name, value string) error {
+	fields := msg.Descriptor().Fields()
+	fd := fields.ByJSONName(name)
+	if fd == nil {
+		fd = fields.ByName(protoreflect.Name(name))
+	}
+	if fd == nil {
+		return errUnknownField
+	}
+
+	v, err := parseValue(fd, value)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "invalid value for %s: %v", name, err)
+	}
+	if fd.IsList() {
+		msg.Mutable(fd).List().Append(v)
+	} else {
+		msg.Set(fd, v)
+	}
+	return nil
+}
+
+func parseValue(fd protoreflect.FieldDescriptor, s string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte(s)), nil
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(s)
+		return protoreflect.ValueOfBool(b), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfInt32(int32(n)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(s, 10, 64)
+		return protoreflect.ValueOfInt64(n), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(s, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(n)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(s, 10, 64)
+		return protoreflect.ValueOfUint64(n), err
+	case protoreflect.FloatKind:
+		f, err := strconv.ParseFloat(s, 32)
+		return protoreflect.ValueOfFloat32(float32(f)), err
+	case protoreflect.DoubleKind:
+		f, err := strconv.ParseFloat(s, 64)
+		return protoreflect.ValueOfFloat64(f), err
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(s)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		n, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), err
+	case protoreflect.MessageKind:
+		if fd.Message().FullName() == "google.protobuf.Timestamp" {
+			t, err := time.Parse(time.RFC3339, s)
+			return protoreflect.ValueOfMessage(timestamppb.New(t).ProtoReflect()), err
+		}
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported field type %s", fd.Kind())
+}
+
+// gatewayContext переносит заголовки и адрес клиента туда, где их ждут интерцепторы
+func gatewayContext(r *http.Request) context.Context {
+	md := metadata.MD{}
+	for _, key := range forwardedHeaders {
+		if value := r.Header.Get(key); value != "" {
+			md.Set(key, value)
+		}
+	}
+	ctx := metadata.NewIncomingContext(r.Context(), md)
+
+	if addr, err := net.ResolveTCPAddr("tcp", r.RemoteAddr); err == nil {
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: addr})
+	}
+	return ctx
+}
+
+// headerStream собирает заголовки, выставленные через grpc.SetHeader,
+// чтобы шлюз вернул их в HTTP-ответе (например, retry-after)
+type headerStream struct {
+	mu     sync.Mutex
+	method string
+	header metadata.MD
+}
+
+func (s *headerStream) Method() string { return s.method }
+
+func (s *headerStream) SetHeader(md metadata.MD) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *headerStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+
+func (s *headerStream) SetTrailer(metadata.MD) error { return nil }
+
+func (s *headerStream) copyTo(h http.Header) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, values := range s.header {
+		for _, value := range values {
+			h.Add(key, value)
+		}
+	}
+}
+
+// writeError отвечает статусом gRPC в JSON с соответствующим HTTP-кодом
+func writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	for _, detail := range st.Details() {
+		if retry, ok := detail.(*errdetails.RetryInfo); ok && w.Header().Get("Retry-After") == "" {
+			seconds := int(math.Ceil(retry.RetryDelay.AsDuration().Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		}
+	}
+
+	body, merr := marshalOptions.Marshal(st.Proto())
+	if merr != nil {
+		body = []byte(fmt.Sprintf(`{"code":%d,"message":%q}`, st.Code(), st.Message()))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpStatus(st.Code()))
+	w.Write(body)
+}
+
+// httpStatus сопоставляет код gRPC с HTTP-статусом
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // Client Closed Request
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// methodName — полное имя gRPC-метода для UnaryServerInfo
+func methodName(service, method string) string {
+	return "/" + service + "/" + method
+}
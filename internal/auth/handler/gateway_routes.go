@@ -0,0 +1,78 @@
+package handler
+
+import (
+	auth "auth-micro/pkg/auth_v1"
+)
+
+// Маршруты шлюза. Параметры пути называются как поля запроса в JSON;
+// /me подставляет id вызывающего из access-токена. Вход и обновление токена
+// в режиме кук отдают refresh-токен в куке (см. gateway_session.go)
+
+func (g *Gateway) registerAuthRoutes(s auth.AuthServer) {
+	m := func(method string) string { return methodName(auth.Auth_ServiceDesc.ServiceName, method) }
+
+	handle(g, "POST /v1/register", m("Register"), s.Register)
+	handle(g, "POST /v1/login", m("Login"), s.Login, g.issueSession("refreshToken"))
+	handle(g, "POST /v1/login/mfa", m("VerifyMFA"), s.VerifyMFA, g.issueSession("refreshToken"))
+	handle(g, "POST /v1/login/passkey/begin", m("BeginPasskeyLogin"), s.BeginPasskeyLogin)
+	handle(g, "POST /v1/login/passkey/finish", m("FinishPasskeyLogin"), s.FinishPasskeyLogin, g.issueSession("refreshToken"))
+	handle(g, "POST /v1/login/federated/{provider}/begin", m("BeginFederatedLogin"), s.BeginFederatedLogin, g.bindFederatedLogin("binding"))
+	handle(g, "POST /v1/login/federated/complete", m("CompleteFederatedLogin"), s.CompleteFederatedLogin,
+		g.federatedBindingFromCookie("binding"), g.issueSession("refreshToken"))
+	handle(g, "POST /v1/logout", m("Logout"), s.Logout, g.refreshFromCookie("refreshToken"), g.endSession())
+
+	handle(g, "POST /v1/token/refresh", m("RefreshToken"), s.RefreshToken, g.refreshFromCookie("refreshToken"), g.issueSession("refreshToken"))
+	// Выход для браузера: refresh-кука отправляется только на пути /v1/token
+	handle(g, "POST /v1/token/revoke", m("Logout"), s.Logout, g.refreshFromCookie("refreshToken"), g.endSession())
+	handle(g, "POST /v1/token/validate", m("ValidateToken"), s.ValidateToken)
+	handle(g, "POST /v1/token/introspect", m("IntrospectToken"), s.IntrospectToken)
+	handle(g, "GET /v1/jwks", m("GetJWKS"), s.GetJWKS)
+
+	handle(g, "POST /v1/email/verify", m("VerifyEmail"), s.VerifyEmail)
+	handle(g, "POST /v1/email/verification", m("SendVerificationEmail"), s.SendVerificationEmail)
+	handle(g, "POST /v1/email/verification/resend", m("ResendVerificationEmail"), s.ResendVerificationEmail)
+	handle(g, "POST /v1/password/forgot", m("RequestPasswordReset"), s.RequestPasswordReset)
+	handle(g, "POST /v1/password/reset", m("ResetPassword"), s.ResetPassword)
+
+	handle(g, "GET /v1/users/me", m("GetUser"), s.GetUser, currentUser("userId"))
+	handle(g, "GET /v1/users/{userId}", m("GetUser"), s.GetUser)
+	handle(g, "PATCH /v1/users/me", m("UpdateUser"), s.UpdateUser, currentUser("userId"))
+	handle(g, "PATCH /v1/users/{userId}", m("UpdateUser"), s.UpdateUser)
+	handle(g, "POST /v1/users/me/password", m("ChangePassword"), s.ChangePassword)
+
+	handle(g, "POST /v1/mfa/totp/enroll", m("BeginTOTPEnrollment"), s.BeginTOTPEnrollment)
+	handle(g, "POST /v1/mfa/totp/confirm", m("ConfirmTOTP"), s.ConfirmTOTP)
+	handle(g, "POST /v1/mfa/totp/disable", m("DisableTOTP"), s.DisableTOTP)
+	handle(g, "POST /v1/passkeys/begin", m("BeginPasskeyRegistration"), s.BeginPasskeyRegistration)
+	handle(g, "POST /v1/passkeys/finish", m("FinishPasskeyRegistration"), s.FinishPasskeyRegistration)
+
+	handle(g, "GET /v1/sessions", m("ListSessions"), s.ListSessions)
+	handle(g, "DELETE /v1/sessions/{sessionId}", m("RevokeSession"), s.RevokeSession)
+	handle(g, "DELETE /v1/sessions", m("RevokeAllSessions"), s.RevokeAllSessions)
+
+	handle(g, "POST /v1/oauth/authorize", m("AuthorizeOAuthClient"), s.AuthorizeOAuthClient)
+
+	handle(g, "POST /v1/keys/rotate", m("RotateSigningKey"), s.RotateSigningKey)
+	handle(g, "POST /v1/accounts/unlock", m("UnlockAccount"), s.UnlockAccount)
+
+	handle(g, "GET /v1/roles", m("ListRoles"), s.ListRoles)
+	handle(g, "POST /v1/roles", m("CreateRole"), s.CreateRole)
+	handle(g, "POST /v1/users/{userId}/roles", m("AssignRole"), s.AssignRole)
+	handle(g, "DELETE /v1/users/{userId}/roles/{role}", m("RevokeRole"), s.RevokeRole)
+}
+
+func (g *Gateway) registerAdminRoutes(s auth.AdminServer) {
+	m := func(method string) string { return methodName(auth.Admin_ServiceDesc.ServiceName, method) }
+
+	handle(g, "GET /v1/admin/users", m("ListUsers"), s.ListUsers)
+	handle(g, "GET /v1/admin/users/{userId}", m("GetUserByID"), s.GetUserByID)
+	handle(g, "POST /v1/admin/users/{userId}/disable", m("DisableUser"), s.DisableUser)
+	handle(g, "POST /v1/admin/users/{userId}/enable", m("EnableUser"), s.EnableUser)
+	handle(g, "POST /v1/admin/users/{userId}/logout", m("ForceLogout"), s.ForceLogout)
+	handle(g, "POST /v1/admin/users/{userId}/password-reset", m("ForcePasswordReset"), s.ForcePasswordReset)
+	handle(g, "DELETE /v1/admin/users/{userId}", m("DeleteUser"), s.DeleteUser)
+	handle(g, "GET /v1/admin/events", m("ListAuthEvents"), s.ListAuthEvents)
+	handle(g, "GET /v1/admin/oauth-clients", m("ListOAuthClients"), s.ListOAuthClients)
+	handle(g, "POST /v1/admin/oauth-clients", m("CreateOAuthClient"), s.CreateOAuthClient)
+	handle(g, "DELETE /v1/admin/oauth-clients/{clientId}", m("DeleteOAuthClient"), s.DeleteOAuthClient)
+}
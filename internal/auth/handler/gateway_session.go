@@ -0,0 +1,251 @@
+package handler
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Браузерные сессии шлюза. Клиент с заголовком X-Session-Mode: cookie получает
+// refresh-токен в HttpOnly-куке вместо тела ответа, access-токен остаётся в теле.
+// Пока у браузера есть сессионные куки, изменяющие запросы должны передавать
+// CSRF-токен из куки в заголовке (double-submit)
+
+const (
+	sessionModeHeader = "X-Session-Mode"
+	sessionModeCookie = "cookie"
+)
+
+// cookieMode — клиент просит куки или уже пришёл с refresh-кукой
+func (g *Gateway) cookieMode(r *http.Request) bool {
+	if strings.EqualFold(r.Header.Get(sessionModeHeader), sessionModeCookie) {
+		return true
+	}
+	_, err := r.Cookie(g.cookies.Name)
+	return err == nil
+}
+
+// issueSession переносит refresh-токен из поля field ответа в куку.
+// Новый вход выдаёт новый CSRF-токен, обновление сохраняет текущий
+func (g *Gateway) issueSession(field string) routeOption {
+	return func(rt *route) {
+		rt.finish = append(rt.finish, func(w http.ResponseWriter, r *http.Request, resp protoreflect.Message, err error) error {
+			if err != nil || !g.cookieMode(r) {
+				return nil
+			}
+			fd := resp.Descriptor().Fields().ByJSONName(field)
+			token := resp.Get(fd).String()
+			if token == "" {
+				// Например, Login ждёт второй фактор
+				return nil
+			}
+
+			csrf := ""
+			if _, err := r.Cookie(g.cookies.Name); err == nil {
+				if c, err := r.Cookie(g.cookies.CSRFCookie); err == nil {
+					csrf = c.Value
+				}
+			}
+			if csrf == "" {
+				if csrf, err = newCSRFToken(); err != nil {
+					return status.Error(codes.Internal, "failed to issue session")
+				}
+			}
+			resp.Clear(fd)
+
+			http.SetCookie(w, g.refreshCookie(token, g.cookieMaxAge()))
+			http.SetCookie(w, g.csrfCookie(csrf, g.cookieMaxAge()))
+			w.Header().Set(g.cookies.CSRFHeader, csrf)
+			w.Header().Set("Cache-Control", "no-store")
+			return nil
+		})
+	}
+}
+
+// refreshFromCookie подставляет refresh-токен из куки, если его нет в запросе
+func (g *Gateway) refreshFromCookie(field string) routeOption {
+	return func(rt *route) {
+		rt.prepare = append(rt.prepare, func(r *http.Request, req protoreflect.Message) error {
+			fd := req.Descriptor().Fields().ByJSONName(field)
+			if req.Get(fd).String() != "" {
+				return nil
+			}
+			if c, err := r.Cookie(g.cookies.Name); err == nil {
+				req.Set(fd, protoreflect.ValueOfString(c.Value))
+			}
+			return nil
+		})
+	}
+}
+
+// endSession удаляет сессионные куки после выхода, даже если токен уже недействителен
+func (g *Gateway) endSession() routeOption {
+	return func(rt *route) {
+		rt.finish = append(rt.finish, func(w http.ResponseWriter, r *http.Request, _ protoreflect.Message, _ error) error {
+			if g.cookieMode(r) {
+				http.SetCookie(w, g.refreshCookie("", -1))
+				http.SetCookie(w, g.csrfCookie("", -1))
+			}
+			return nil
+		})
+	}
+}
+
+// federatedLoginPath — кука входа через провайдера отправляется только на его маршруты
+const federatedLoginPath = "/v1/login/federated"
+
+// bindFederatedLogin переносит секрет binding из ответа BeginFederatedLogin в
+// HttpOnly-куку: завершить вход сможет только браузер, который его начал
+func (g *Gateway) bindFederatedLogin(field string) routeOption {
+	return func(rt *route) {
+		rt.finish = append(rt.finish, func(w http.ResponseWriter, _ *http.Request, resp protoreflect.Message, err error) error {
+			if err != nil {
+				return nil
+			}
+			fd := resp.Descriptor().Fields().ByJSONName(field)
+			binding := resp.Get(fd).String()
+			resp.Clear(fd)
+
+			http.SetCookie(w, g.federationCookie(binding, int(g.federationTTL.Seconds())))
+			w.Header().Set("Cache-Control", "no-store")
+			return nil
+		})
+	}
+}
+
+// federatedBindingFromCookie подставляет binding из куки, если его нет в запросе,
+// и удаляет куку: state одноразовый, даже если вход не удался
+func (g *Gateway) federatedBindingFromCookie(field string) routeOption {
+	return func(rt *route) {
+		rt.prepare = append(rt.prepare, func(r *http.Request, req protoreflect.Message) error {
+			fd := req.Descriptor().Fields().ByJSONName(field)
+			if req.Get(fd).String() != "" {
+				return nil
+			}
+			if c, err := r.Cookie(g.cookies.FederationCookie); err == nil {
+				req.Set(fd, protoreflect.ValueOfString(c.Value))
+			}
+			return nil
+		})
+		rt.finish = append(rt.finish, func(w http.ResponseWriter, r *http.Request, _ protoreflect.Message, _ error) error {
+			if _, err := r.Cookie(g.cookies.FederationCookie); err == nil {
+				http.SetCookie(w, g.federationCookie("", -1))
+			}
+			return nil
+		})
+	}
+}
+
+// checkCSRF сверяет заголовок с CSRF-кукой в изменяющих запросах браузера
+// с сессионными куками. Запросы только с Authorization не проверяются:
+// сторонний сайт не может подставить заголовок
+func (g *Gateway) checkCSRF(r *http.Request) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil
+	}
+
+	csrf, csrfErr := r.Cookie(g.cookies.CSRFCookie)
+	_, refreshErr := r.Cookie(g.cookies.Name)
+	if csrfErr != nil && refreshErr != nil {
+		return nil
+	}
+
+	header := r.Header.Get(g.cookies.CSRFHeader)
+	if csrfErr != nil || header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(csrf.Value)) != 1 {
+		return status.Error(codes.PermissionDenied, "invalid CSRF token")
+	}
+	return nil
+}
+
+// csrfToken отдаёт текущий CSRF-токен: клиент на другом origin не может
+// прочитать куку и восстанавливает токен после перезагрузки страницы
+func (g *Gateway) csrfToken(w http.ResponseWriter, r *http.Request) {
+	var token string
+	if c, err := r.Cookie(g.cookies.CSRFCookie); err == nil {
+		token = c.Value
+	} else {
+		if token, err = newCSRFToken(); err != nil {
+			writeError(w, status.Error(codes.Internal, "failed to issue CSRF token"))
+			return
+		}
+		http.SetCookie(w, g.csrfCookie(token, g.cookieMaxAge()))
+	}
+
+	w.Header().Set(g.cookies.CSRFHeader, token)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"csrfToken":%q}`, token)
+}
+
+// refreshCookie доступна только серверу и только на пути обновления токена
+func (g *Gateway) refreshCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     g.cookies.Name,
+		Value:    value,
+		Path:     g.cookies.Path,
+		Domain:   g.cookies.Domain,
+		MaxAge:   maxAge,
+		Secure:   g.cookies.Secure,
+		HttpOnly: true,
+		SameSite: sameSite(g.cookies.SameSite),
+	}
+}
+
+// csrfCookie читается скриптом своего origin и отправляется на все пути
+func (g *Gateway) csrfCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     g.cookies.CSRFCookie,
+		Value:    value,
+		Path:     "/",
+		Domain:   g.cookies.Domain,
+		MaxAge:   maxAge,
+		Secure:   g.cookies.Secure,
+		SameSite: sameSite(g.cookies.SameSite),
+	}
+}
+
+// federationCookie доступна только серверу и только на маршрутах входа через провайдера
+func (g *Gateway) federationCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     g.cookies.FederationCookie,
+		Value:    value,
+		Path:     federatedLoginPath,
+		Domain:   g.cookies.Domain,
+		MaxAge:   maxAge,
+		Secure:   g.cookies.Secure,
+		HttpOnly: true,
+		SameSite: sameSite(g.cookies.SameSite),
+	}
+}
+
+func sameSite(mode string) http.SameSite {
+	switch strings.ToLower(mode) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteStrictMode
+	}
+}
+
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate CSRF token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// cookieMaxAge — срок жизни сессионных кук в секундах
+func (g *Gateway) cookieMaxAge() int {
+	return int(g.jwtManager.RefreshTokenDuration().Seconds())
+}
@@ -1,13 +1,18 @@
 package handler
 
 import (
+	"auth-micro/internal/auth/entity"
 	"auth-micro/internal/auth/service"
 	auth "auth-micro/pkg/auth_v1"
 	"context"
+	"errors"
+	"time"
 
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
@@ -34,17 +39,8 @@ func (h *grpcHandler) Register(ctx context.Context, req *auth.RegisterRequest) (
 	}
 
 	return &auth.RegisterResponse{
-		Id: user.ID,
-		UserInfo: &auth.UserInfo{
-			Id:        user.ID,
-			Username:  user.Username,
-			Email:     user.Email,
-			Name:      user.Name,
-			Age:       user.Age,
-			Bio:       user.Bio,
-			CreatedAt: timestamppb.New(user.CreatedAt),
-			UpdatedAt: timestamppb.New(user.UpdatedAt),
-		},
+		Id:       user.ID,
+		UserInfo: toUserInfo(user),
 	}, nil
 }
 
@@ -53,14 +49,176 @@ func (h *grpcHandler) Login(ctx context.Context, req *auth.LoginRequest) (*auth.
 		return nil, status.Error(codes.InvalidArgument, "username and password are required")
 	}
 
-	accessToken, refreshToken, err := h.userService.Login(ctx, req.Username, req.Password)
+	result, err := h.userService.Login(ctx, req.Username, req.Password)
 	if err != nil {
-		return nil, status.Error(codes.Unauthenticated, "invalid credentials")
+		return nil, loginError(err)
+	}
+
+	if result.MFARequired {
+		return &auth.LoginResponse{
+			MfaRequired:  true,
+			MfaToken:     result.MFAToken,
+			MfaExpiresAt: timestamppb.New(result.MFAExpiresAt),
+		}, nil
+	}
+
+	return &auth.LoginResponse{
+		AccessToken:  result.Tokens.AccessToken,
+		RefreshToken: result.Tokens.RefreshToken,
+	}, nil
+}
+
+func (h *grpcHandler) VerifyMFA(ctx context.Context, req *auth.VerifyMFARequest) (*auth.VerifyMFAResponse, error) {
+	if req.MfaToken == "" || req.Code == "" {
+		return nil, status.Error(codes.InvalidArgument, "mfa token and code are required")
+	}
+
+	pair, err := h.userService.VerifyMFA(ctx, req.MfaToken, req.Code)
+	if err != nil {
+		return nil, loginError(err)
+	}
+
+	return &auth.VerifyMFAResponse{
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresAt:    timestamppb.New(pair.ExpiresAt),
+	}, nil
+}
+
+func (h *grpcHandler) BeginPasskeyLogin(ctx context.Context, _ *emptypb.Empty) (*auth.BeginPasskeyLoginResponse, error) {
+	ceremonyID, options, err := h.userService.BeginPasskeyLogin(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "failed to begin passkey login")
+	}
+
+	return &auth.BeginPasskeyLoginResponse{
+		CeremonyId:           ceremonyID,
+		PublicKeyOptionsJson: string(options),
+	}, nil
+}
+
+func (h *grpcHandler) FinishPasskeyLogin(ctx context.Context, req *auth.FinishPasskeyLoginRequest) (*auth.FinishPasskeyLoginResponse, error) {
+	if req.CeremonyId == "" || req.CredentialJson == "" {
+		return nil, status.Error(codes.InvalidArgument, "ceremony id and credential are required")
+	}
+
+	pair, err := h.userService.FinishPasskeyLogin(ctx, req.CeremonyId, []byte(req.CredentialJson))
+	if err != nil {
+		return nil, loginError(err)
+	}
+
+	return &auth.FinishPasskeyLoginResponse{
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresAt:    timestamppb.New(pair.ExpiresAt),
+	}, nil
+}
+
+func (h *grpcHandler) BeginFederatedLogin(ctx context.Context, req *auth.BeginFederatedLoginRequest) (*auth.BeginFederatedLoginResponse, error) {
+	if req.Provider == "" {
+		return nil, status.Error(codes.InvalidArgument, "provider is required")
+	}
+
+	authURL, binding, err := h.userService.BeginFederatedLogin(ctx, req.Provider)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.BeginFederatedLoginResponse{AuthorizationUrl: authURL, Binding: binding}, nil
+}
+
+func (h *grpcHandler) CompleteFederatedLogin(ctx context.Context, req *auth.CompleteFederatedLoginRequest) (*auth.LoginResponse, error) {
+	if req.State == "" || req.Code == "" || req.Binding == "" {
+		return nil, status.Error(codes.InvalidArgument, "state, code and binding are required")
+	}
+
+	result, err := h.userService.CompleteFederatedLogin(ctx, req.State, req.Code, req.Binding)
+	if err != nil {
+		return nil, loginError(err)
+	}
+
+	if result.MFARequired {
+		return &auth.LoginResponse{
+			MfaRequired:  true,
+			MfaToken:     result.MFAToken,
+			MfaExpiresAt: timestamppb.New(result.MFAExpiresAt),
+		}, nil
 	}
 
 	return &auth.LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+		AccessToken:  result.Tokens.AccessToken,
+		RefreshToken: result.Tokens.RefreshToken,
+	}, nil
+}
+
+func (h *grpcHandler) BeginPasskeyRegistration(ctx context.Context, _ *emptypb.Empty) (*auth.BeginPasskeyRegistrationResponse, error) {
+	ceremonyID, options, err := h.userService.BeginPasskeyRegistration(ctx)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.BeginPasskeyRegistrationResponse{
+		CeremonyId:           ceremonyID,
+		PublicKeyOptionsJson: string(options),
+	}, nil
+}
+
+func (h *grpcHandler) FinishPasskeyRegistration(ctx context.Context, req *auth.FinishPasskeyRegistrationRequest) (*auth.FinishPasskeyRegistrationResponse, error) {
+	if req.CeremonyId == "" || req.CredentialJson == "" {
+		return nil, status.Error(codes.InvalidArgument, "ceremony id and credential are required")
+	}
+
+	cred, err := h.userService.FinishPasskeyRegistration(ctx, req.CeremonyId, []byte(req.CredentialJson), req.Name)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.FinishPasskeyRegistrationResponse{
+		Success:      true,
+		CredentialId: cred.ID,
+	}, nil
+}
+
+func (h *grpcHandler) BeginTOTPEnrollment(ctx context.Context, _ *emptypb.Empty) (*auth.BeginTOTPEnrollmentResponse, error) {
+	enrollment, err := h.userService.BeginTOTPEnrollment(ctx)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.BeginTOTPEnrollmentResponse{
+		Secret:     enrollment.Secret,
+		OtpauthUri: enrollment.URI,
+	}, nil
+}
+
+func (h *grpcHandler) ConfirmTOTP(ctx context.Context, req *auth.ConfirmTOTPRequest) (*auth.ConfirmTOTPResponse, error) {
+	if req.Code == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	recoveryCodes, err := h.userService.ConfirmTOTP(ctx, req.Code)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.ConfirmTOTPResponse{
+		Success:       true,
+		RecoveryCodes: recoveryCodes,
+	}, nil
+}
+
+func (h *grpcHandler) DisableTOTP(ctx context.Context, req *auth.DisableTOTPRequest) (*auth.DisableTOTPResponse, error) {
+	if req.Code == "" {
+		return nil, status.Error(codes.InvalidArgument, "code is required")
+	}
+
+	if err := h.userService.DisableTOTP(ctx, req.Code); err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.DisableTOTPResponse{
+		Success: true,
+		Message: "Two-factor authentication disabled",
 	}, nil
 }
 
@@ -70,7 +228,7 @@ func (h *grpcHandler) Logout(ctx context.Context, req *auth.LogoutRequest) (*aut
 	}
 
 	if err := h.userService.Logout(ctx, req.RefreshToken); err != nil {
-		return nil, status.Error(codes.Internal, "failed to logout")
+		return nil, userError(err)
 	}
 
 	return &auth.LogoutResponse{
@@ -85,25 +243,505 @@ func (h *grpcHandler) ChangePassword(ctx context.Context, req *auth.ChangePasswo
 		return nil, status.Error(codes.InvalidArgument, "current and new passwords are required")
 	}
 
-	// Получить токен из metadata (Handler НЕ знает что внутри токена!)
-	md, ok := metadata.FromIncomingContext(ctx)
-	if !ok {
-		return nil, status.Error(codes.Unauthenticated, "missing token")
+	// Пользователя берёт из контекста сервис — его туда кладёт AuthInterceptor
+	pair, err := h.userService.ChangePassword(ctx, req.OldPassword, req.NewPassword, req.KeepCurrentSession)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	resp := &auth.ChangePasswordResponse{
+		Success: true,
+		Message: "Password changed successfully",
+	}
+	// С keepCurrentSession клиент продолжает пользоваться текущими токенами
+	if pair != nil {
+		resp.AccessToken = pair.AccessToken
+		resp.RefreshToken = pair.RefreshToken
+		resp.ExpiresAt = timestamppb.New(pair.ExpiresAt)
+	}
+	return resp, nil
+}
+
+func (h *grpcHandler) SendVerificationEmail(ctx context.Context, _ *emptypb.Empty) (*auth.SendVerificationEmailResponse, error) {
+	if err := h.userService.SendVerificationEmail(ctx); err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.SendVerificationEmailResponse{
+		Success: true,
+		Message: "Verification email sent",
+	}, nil
+}
+
+func (h *grpcHandler) VerifyEmail(ctx context.Context, req *auth.VerifyEmailRequest) (*auth.VerifyEmailResponse, error) {
+	if req.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	if err := h.userService.VerifyEmail(ctx, req.Token); err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.VerifyEmailResponse{
+		Success: true,
+		Message: "Email verified",
+	}, nil
+}
+
+func (h *grpcHandler) ResendVerificationEmail(ctx context.Context, req *auth.ResendVerificationEmailRequest) (*auth.ResendVerificationEmailResponse, error) {
+	if req.Email == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+
+	if err := h.userService.ResendVerificationEmail(ctx, req.Email); err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.ResendVerificationEmailResponse{
+		Success: true,
+		Message: "If the email is registered and not verified, a verification link has been sent",
+	}, nil
+}
+
+func (h *grpcHandler) RequestPasswordReset(ctx context.Context, req *auth.RequestPasswordResetRequest) (*auth.RequestPasswordResetResponse, error) {
+	if req.Email == "" {
+		return nil, status.Error(codes.InvalidArgument, "email is required")
+	}
+
+	if err := h.userService.RequestPasswordReset(ctx, req.Email); err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.RequestPasswordResetResponse{
+		Success: true,
+		Message: "If the email is registered, a password reset link has been sent",
+	}, nil
+}
+
+func (h *grpcHandler) ResetPassword(ctx context.Context, req *auth.ResetPasswordRequest) (*auth.ResetPasswordResponse, error) {
+	if req.Token == "" || req.NewPassword == "" {
+		return nil, status.Error(codes.InvalidArgument, "token and new password are required")
+	}
+
+	if err := h.userService.ResetPassword(ctx, req.Token, req.NewPassword); err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.ResetPasswordResponse{
+		Success: true,
+		Message: "Password has been reset",
+	}, nil
+}
+
+func (h *grpcHandler) RefreshToken(ctx context.Context, req *auth.RefreshTokenRequest) (*auth.RefreshTokenResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, status.Error(codes.InvalidArgument, "refresh token is required")
+	}
+
+	pair, err := h.userService.RefreshAccessToken(ctx, req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRefreshTokenReused):
+			return nil, status.Error(codes.Unauthenticated, "refresh token reuse detected, session revoked")
+		case errors.Is(err, service.ErrInvalidRefreshToken):
+			return nil, status.Error(codes.Unauthenticated, "invalid refresh token")
+		case errors.Is(err, service.ErrAccountDisabled):
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		default:
+			return nil, status.Error(codes.Internal, "failed to refresh token")
+		}
+	}
+
+	return &auth.RefreshTokenResponse{
+		AccessToken:  pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresAt:    timestamppb.New(pair.ExpiresAt),
+	}, nil
+}
+
+func (h *grpcHandler) ValidateToken(ctx context.Context, req *auth.ValidateTokenRequest) (*auth.ValidateTokenResponse, error) {
+	if req.AccessToken == "" {
+		return nil, status.Error(codes.InvalidArgument, "access token is required")
+	}
+
+	info, err := h.userService.ValidateAccessToken(ctx, req.AccessToken)
+	if err != nil {
+		st, ok := tokenStatus(err)
+		if !ok {
+			return nil, status.Error(codes.Internal, "failed to validate token")
+		}
+		return &auth.ValidateTokenResponse{Status: st, Reason: err.Error()}, nil
+	}
+
+	return &auth.ValidateTokenResponse{
+		Valid:     true,
+		UserId:    info.UserID,
+		ExpiresAt: optionalTimestamp(info.ExpiresAt),
+		Status:    auth.TokenStatus_TOKEN_STATUS_VALID,
+	}, nil
+}
+
+func (h *grpcHandler) IntrospectToken(ctx context.Context, req *auth.IntrospectTokenRequest) (*auth.IntrospectTokenResponse, error) {
+	if req.Token == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	info, err := h.userService.IntrospectToken(ctx, req.Token)
+	if err != nil {
+		if errors.Is(err, service.ErrPermissionDenied) || errors.Is(err, service.ErrUnauthenticated) {
+			return nil, userError(err)
+		}
+		st, ok := tokenStatus(err)
+		if !ok {
+			return nil, status.Error(codes.Internal, "failed to introspect token")
+		}
+		return &auth.IntrospectTokenResponse{Status: st, Reason: err.Error()}, nil
+	}
+
+	return &auth.IntrospectTokenResponse{
+		Active:    true,
+		Sub:       info.UserID,
+		TokenType: info.Type,
+		Jti:       info.TokenID,
+		Exp:       optionalTimestamp(info.ExpiresAt),
+		Iat:       optionalTimestamp(info.IssuedAt),
+		Nbf:       optionalTimestamp(info.NotBefore),
+		Status:    auth.TokenStatus_TOKEN_STATUS_VALID,
+	}, nil
+}
+
+func (h *grpcHandler) GetJWKS(ctx context.Context, _ *emptypb.Empty) (*auth.GetJWKSResponse, error) {
+	set := h.userService.PublicKeys()
+
+	keys := make([]*auth.JWK, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		keys = append(keys, &auth.JWK{
+			Kty: k.Kty,
+			Use: k.Use,
+			Alg: k.Alg,
+			Kid: k.Kid,
+			N:   k.N,
+			E:   k.E,
+			Crv: k.Crv,
+			X:   k.X,
+			Y:   k.Y,
+		})
+	}
+
+	return &auth.GetJWKSResponse{Keys: keys}, nil
+}
+
+func (h *grpcHandler) RotateSigningKey(ctx context.Context, _ *emptypb.Empty) (*auth.RotateSigningKeyResponse, error) {
+	instance, current, verification, err := h.userService.RotateSigningKey(ctx)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.RotateSigningKeyResponse{
+		Instance:           instance,
+		CurrentKeyId:       current,
+		VerificationKeyIds: verification,
+	}, nil
+}
+
+func (h *grpcHandler) ListSessions(ctx context.Context, req *auth.ListSessionsRequest) (*auth.ListSessionsResponse, error) {
+	sessions, err := h.userService.ListSessions(ctx, req.UserId)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	resp := &auth.ListSessionsResponse{Sessions: make([]*auth.Session, 0, len(sessions))}
+	for _, session := range sessions {
+		resp.Sessions = append(resp.Sessions, &auth.Session{
+			Id:          session.ID,
+			ClientIp:    session.ClientIP,
+			UserAgent:   session.UserAgent,
+			DeviceLabel: session.DeviceLabel,
+			CreatedAt:   timestamppb.New(session.CreatedAt),
+			LastUsedAt:  timestamppb.New(session.LastUsedAt),
+			ExpiresAt:   timestamppb.New(session.ExpiresAt),
+			Current:     session.Current,
+		})
+	}
+	return resp, nil
+}
+
+func (h *grpcHandler) RevokeSession(ctx context.Context, req *auth.RevokeSessionRequest) (*auth.RevokeSessionResponse, error) {
+	if req.SessionId == "" {
+		return nil, status.Error(codes.InvalidArgument, "session id is required")
+	}
+
+	if err := h.userService.RevokeSession(ctx, req.UserId, req.SessionId); err != nil {
+		return nil, userError(err)
 	}
 
-	tokens := md.Get("authorization")
-	if len(tokens) == 0 {
-		return nil, status.Error(codes.Unauthenticated, "missing token")
+	return &auth.RevokeSessionResponse{
+		Success: true,
+		Message: "Session revoked",
+	}, nil
+}
+
+func (h *grpcHandler) RevokeAllSessions(ctx context.Context, req *auth.RevokeAllSessionsRequest) (*auth.RevokeAllSessionsResponse, error) {
+	if err := h.userService.RevokeAllSessions(ctx, req.UserId, req.KeepCurrent); err != nil {
+		return nil, userError(err)
 	}
 
-	// Просто передаем токен в Service (Handler не парсит его!)
-	err := h.userService.ChangePassword(ctx, tokens[0], req.OldPassword, req.NewPassword)
+	return &auth.RevokeAllSessionsResponse{
+		Success: true,
+		Message: "Sessions revoked",
+	}, nil
+}
+
+func (h *grpcHandler) AuthorizeOAuthClient(ctx context.Context, req *auth.AuthorizeOAuthClientRequest) (*auth.AuthorizeOAuthClientResponse, error) {
+	result, err := h.userService.Authorize(ctx, service.AuthorizeInput{
+		ResponseType:        req.ResponseType,
+		ClientID:            req.ClientId,
+		RedirectURI:         req.RedirectUri,
+		Scope:               req.Scope,
+		State:               req.State,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		Nonce:               req.Nonce,
+		Approve:             req.Approve,
+	})
 	if err != nil {
-		return nil, status.Error(codes.Internal, err.Error())
+		return nil, userError(err)
+	}
+
+	resp := &auth.AuthorizeOAuthClientResponse{
+		RedirectUri:     result.RedirectURI,
+		ConsentRequired: result.ConsentRequired,
+		Scopes:          result.Scopes,
+	}
+	if result.Client != nil {
+		resp.ClientName = result.Client.Name
+	}
+	return resp, nil
+}
+
+func (h *grpcHandler) UnlockAccount(ctx context.Context, req *auth.UnlockAccountRequest) (*auth.UnlockAccountResponse, error) {
+	if req.Username == "" {
+		return nil, status.Error(codes.InvalidArgument, "username is required")
+	}
+
+	if err := h.userService.UnlockAccount(ctx, req.Username, req.Ip); err != nil {
+		return nil, userError(err)
 	}
 
-	return &auth.ChangePasswordResponse{
+	return &auth.UnlockAccountResponse{
 		Success: true,
-		Message: "Password changed successfully",
+		Message: "Account unlocked",
 	}, nil
 }
+
+func (h *grpcHandler) ListRoles(ctx context.Context, _ *emptypb.Empty) (*auth.ListRolesResponse, error) {
+	roles, err := h.userService.ListRoles(ctx)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	resp := &auth.ListRolesResponse{Roles: make([]*auth.Role, 0, len(roles))}
+	for _, role := range roles {
+		resp.Roles = append(resp.Roles, toRole(role))
+	}
+	return resp, nil
+}
+
+func (h *grpcHandler) CreateRole(ctx context.Context, req *auth.CreateRoleRequest) (*auth.CreateRoleResponse, error) {
+	if req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "role name is required")
+	}
+
+	role, err := h.userService.CreateRole(ctx, &entity.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	})
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.CreateRoleResponse{Role: toRole(role)}, nil
+}
+
+func (h *grpcHandler) AssignRole(ctx context.Context, req *auth.AssignRoleRequest) (*auth.AssignRoleResponse, error) {
+	if req.UserId == "" || req.Role == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id and role are required")
+	}
+
+	if err := h.userService.AssignRole(ctx, req.UserId, req.Role); err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.AssignRoleResponse{
+		Success: true,
+		Message: "Role assigned",
+	}, nil
+}
+
+func (h *grpcHandler) RevokeRole(ctx context.Context, req *auth.RevokeRoleRequest) (*auth.RevokeRoleResponse, error) {
+	if req.UserId == "" || req.Role == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id and role are required")
+	}
+
+	if err := h.userService.RevokeRole(ctx, req.UserId, req.Role); err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.RevokeRoleResponse{
+		Success: true,
+		Message: "Role revoked",
+	}, nil
+}
+
+func toRole(role *entity.Role) *auth.Role {
+	return &auth.Role{
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.Permissions,
+		CreatedAt:   timestamppb.New(role.CreatedAt),
+	}
+}
+
+// loginError переводит ошибки входа в gRPC-статусы. Блокировка отдаётся
+// отдельным кодом с подсказкой retry-after, но одинаково для существующих
+// и несуществующих аккаунтов
+func loginError(err error) error {
+	var locked *service.AccountLockedError
+	switch {
+	case errors.As(err, &locked):
+		wait := time.Until(locked.Until)
+		st := status.New(codes.PermissionDenied, locked.Error())
+		if detailed, derr := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(wait)}); derr == nil {
+			st = detailed
+		}
+		return st.Err()
+	case errors.Is(err, service.ErrAccountDisabled):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, service.ErrInvalidCredentials):
+		return status.Error(codes.Unauthenticated, "invalid credentials")
+	case errors.Is(err, service.ErrEmailNotVerified):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrInvalidMFAToken), errors.Is(err, service.ErrInvalidMFACode),
+		errors.Is(err, service.ErrInvalidPasskeyCeremony), errors.Is(err, service.ErrInvalidPasskey),
+		errors.Is(err, service.ErrInvalidFederatedState), errors.Is(err, service.ErrFederatedLoginFailed):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, service.ErrFederatedEmailConflict), errors.Is(err, service.ErrFederatedEmailNotVerified):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Error(codes.Internal, "failed to login")
+	}
+}
+
+// optionalTimestamp оставляет поле пустым, если claim в токене не было
+func optionalTimestamp(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// tokenStatus сопоставляет ошибку проверки токена со статусом в ответе.
+// ok == false означает внутреннюю ошибку, а не невалидный токен
+func tokenStatus(err error) (auth.TokenStatus, bool) {
+	switch {
+	case errors.Is(err, service.ErrTokenExpired):
+		return auth.TokenStatus_TOKEN_STATUS_EXPIRED, true
+	case errors.Is(err, service.ErrTokenInvalidSignature):
+		return auth.TokenStatus_TOKEN_STATUS_INVALID_SIGNATURE, true
+	case errors.Is(err, service.ErrTokenWrongType):
+		return auth.TokenStatus_TOKEN_STATUS_WRONG_TYPE, true
+	case errors.Is(err, service.ErrTokenRevoked):
+		return auth.TokenStatus_TOKEN_STATUS_REVOKED, true
+	case errors.Is(err, service.ErrTokenMalformed):
+		return auth.TokenStatus_TOKEN_STATUS_MALFORMED, true
+	default:
+		return auth.TokenStatus_TOKEN_STATUS_UNSPECIFIED, false
+	}
+}
+
+func (h *grpcHandler) GetUser(ctx context.Context, req *auth.GetUserRequest) (*auth.GetUserResponse, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+
+	user, err := h.userService.GetUserInfo(ctx, req.UserId)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.GetUserResponse{
+		Id:       user.ID,
+		UserInfo: toUserInfo(user),
+	}, nil
+}
+
+func (h *grpcHandler) UpdateUser(ctx context.Context, req *auth.UpdateUserRequest) (*auth.UpdateUserResponse, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "user id is required")
+	}
+
+	user, err := h.userService.UpdateUser(ctx, req.UserId, entity.UserProfileUpdate{
+		Name:  req.Name,
+		Email: req.Email,
+		Age:   req.Age,
+		Bio:   req.Bio,
+	})
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	return &auth.UpdateUserResponse{
+		Id:        user.ID,
+		UserInfo:  toUserInfo(user),
+		CreatedAt: timestamppb.New(user.CreatedAt),
+		UpdatedAt: timestamppb.New(user.UpdatedAt),
+	}, nil
+}
+
+// userError переводит ошибки операций с профилем в gRPC-статусы
+func userError(err error) error {
+	var oauthErr *service.OAuthError
+	switch {
+	case errors.As(err, &oauthErr):
+		return status.Error(codes.InvalidArgument, oauthErr.Description)
+	case errors.Is(err, service.ErrUnauthenticated):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, service.ErrPermissionDenied), errors.Is(err, service.ErrAccountDisabled):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrSessionNotFound),
+		errors.Is(err, service.ErrRoleNotFound), errors.Is(err, service.ErrOAuthClientNotFound),
+		errors.Is(err, service.ErrIdentityProviderNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrEmailTaken), errors.Is(err, service.ErrRoleExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, service.ErrInvalidArgument), errors.Is(err, service.ErrWrongPassword),
+		errors.Is(err, service.ErrInvalidPageToken), errors.Is(err, service.ErrInvalidVerificationToken), errors.Is(err, service.ErrInvalidResetToken),
+		errors.Is(err, service.ErrInvalidMFACode), errors.Is(err, service.ErrInvalidPasskeyCeremony),
+		errors.Is(err, service.ErrInvalidPasskey):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrEmailAlreadyVerified), errors.Is(err, service.ErrMFAAlreadyEnabled),
+		errors.Is(err, service.ErrMFANotEnabled), errors.Is(err, service.ErrUserHasNoEmail),
+		errors.Is(err, service.ErrSigningKeyUnchanged):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+func toUserInfo(user *entity.User) *auth.UserInfo {
+	return &auth.UserInfo{
+		Id:        user.ID,
+		Username:  user.Username,
+		Email:     user.Email,
+		Name:      user.Name,
+		Age:       user.Age,
+		Bio:       user.Bio,
+		CreatedAt: timestamppb.New(user.CreatedAt),
+		UpdatedAt: timestamppb.New(user.UpdatedAt),
+
+		EmailVerified: user.EmailVerified(),
+		Roles:         user.Roles,
+		Disabled:      user.Disabled(),
+	}
+}
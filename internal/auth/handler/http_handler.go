@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"auth-micro/internal/auth/service"
+)
+
+// NewHTTPHandler возвращает HTTP-роуты сервиса
+func NewHTTPHandler(s service.UserService) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/jwks.json", jwksHandler(s))
+	mux.HandleFunc("GET /oauth/authorize", authorizeHandler(s))
+	mux.HandleFunc("POST /oauth/token", tokenHandler(s))
+	mux.HandleFunc("GET /.well-known/openid-configuration", discoveryHandler(s))
+	mux.HandleFunc("GET /userinfo", userInfoHandler(s))
+	mux.HandleFunc("POST /userinfo", userInfoHandler(s))
+	mux.HandleFunc("GET /oauth/logout", endSessionHandler(s))
+	mux.HandleFunc("POST /oauth/logout", endSessionHandler(s))
+	return mux
+}
+
+// jwksHandler отдаёт публичные ключи для офлайн-проверки токенов
+func jwksHandler(s service.UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		json.NewEncoder(w).Encode(s.PublicKeys())
+	}
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"auth-micro/internal/auth/clientinfo"
+	"auth-micro/internal/auth/service"
+)
+
+// tokenResponse — успешный ответ /oauth/token (RFC 6749, 5.1)
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+// oauthErrorResponse — ошибка OAuth (RFC 6749, 5.2)
+type oauthErrorResponse struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// authorizeHandler — точка входа браузера в authorization code flow.
+// Проверенный запрос уходит на страницу входа и согласия, ошибки — клиенту
+// на redirect_uri; если клиент или redirect_uri неизвестны, ошибка показывается здесь
+func authorizeHandler(s service.UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		redirect, err := s.BeginAuthorization(r.Context(), service.AuthorizeInput{
+			ResponseType:        q.Get("response_type"),
+			ClientID:            q.Get("client_id"),
+			RedirectURI:         q.Get("redirect_uri"),
+			Scope:               q.Get("scope"),
+			State:               q.Get("state"),
+			CodeChallenge:       q.Get("code_challenge"),
+			CodeChallengeMethod: q.Get("code_challenge_method"),
+			Nonce:               q.Get("nonce"),
+		})
+		if err != nil {
+			writeOAuthError(w, err)
+			return
+		}
+		http.Redirect(w, r, redirect, http.StatusFound)
+	}
+}
+
+// tokenHandler выдаёт токены по коду авторизации, refresh-токену или
+// client credentials. Клиент передаёт секрет через HTTP Basic или в форме
+func tokenHandler(s service.UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxGatewayBody)
+		if err := r.ParseForm(); err != nil {
+			writeOAuthError(w, &service.OAuthError{Code: service.OAuthInvalidRequest, Description: "invalid form body"})
+			return
+		}
+
+		input := service.OAuthTokenInput{
+			GrantType:    r.PostForm.Get("grant_type"),
+			ClientID:     r.PostForm.Get("client_id"),
+			ClientSecret: r.PostForm.Get("client_secret"),
+			Code:         r.PostForm.Get("code"),
+			RedirectURI:  r.PostForm.Get("redirect_uri"),
+			CodeVerifier: r.PostForm.Get("code_verifier"),
+			RefreshToken: r.PostForm.Get("refresh_token"),
+			Scope:        r.PostForm.Get("scope"),
+		}
+		// В Basic client_id и секрет дополнительно закодированы как в форме (RFC 6749, 2.3.1)
+		if id, secret, ok := r.BasicAuth(); ok {
+			input.ClientID, _ = url.QueryUnescape(id)
+			input.ClientSecret, _ = url.QueryUnescape(secret)
+		}
+
+		ctx := clientinfo.WithInfo(r.Context(), clientinfo.Info{
+			IP:        remoteIP(r),
+			UserAgent: r.UserAgent(),
+		})
+		pair, err := s.ExchangeOAuthToken(ctx, input)
+		if err != nil {
+			writeOAuthError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(tokenResponse{
+			AccessToken:  pair.AccessToken,
+			TokenType:    "Bearer",
+			ExpiresIn:    int64(time.Until(pair.ExpiresAt).Seconds()),
+			RefreshToken: pair.RefreshToken,
+			Scope:        strings.Join(pair.Scopes, " "),
+			IDToken:      pair.IDToken,
+		})
+	}
+}
+
+func writeOAuthError(w http.ResponseWriter, err error) {
+	var oauthErr *service.OAuthError
+	if !errors.As(err, &oauthErr) {
+		log.Printf("oauth request failed: %v", err)
+		oauthErr = &service.OAuthError{Code: service.OAuthServerError, Description: "internal error"}
+	}
+
+	code := http.StatusBadRequest
+	switch oauthErr.Code {
+	case service.OAuthInvalidClient:
+		code = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	case service.OAuthServerError:
+		code = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(oauthErrorResponse{Error: oauthErr.Code, Description: oauthErr.Description})
+}
+
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
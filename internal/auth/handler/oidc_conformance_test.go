@@ -0,0 +1,357 @@
+package handler_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	"auth-micro/internal/auth/config"
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/handler"
+	"auth-micro/internal/auth/principal"
+	"auth-micro/internal/auth/repository"
+	"auth-micro/internal/auth/repository/memory"
+	"auth-micro/internal/auth/service"
+	"auth-micro/internal/auth/utils"
+)
+
+const (
+	testClientID     = "conformance-client"
+	testClientSecret = "conformance-secret"
+	testRedirectURI  = "https://client.example/callback"
+	testLogoutURI    = "https://client.example/logged-out"
+)
+
+// Полный сценарий OpenID Connect глазами стороннего клиента (go-oidc):
+// discovery → authorize → token → проверка ID-токена → userinfo → end_session
+func TestOpenIDConnectConformance(t *testing.T) {
+	ctx := context.Background()
+	env := newOIDCEnv(t, signingKeyConfig(t))
+
+	provider, err := oidc.NewProvider(ctx, env.server.URL)
+	if err != nil {
+		t.Fatalf("discovery: %v", err)
+	}
+	var discovery struct {
+		ScopesSupported    []string `json:"scopes_supported"`
+		EndSessionEndpoint string   `json:"end_session_endpoint"`
+	}
+	if err := provider.Claims(&discovery); err != nil {
+		t.Fatalf("discovery claims: %v", err)
+	}
+	if !slices.Contains(discovery.ScopesSupported, oidc.ScopeOpenID) {
+		t.Fatalf("scopes_supported = %v, want openid", discovery.ScopesSupported)
+	}
+
+	conf := oauth2.Config{
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  testRedirectURI,
+		Scopes:       []string{oidc.ScopeOpenID, "profile", "email"},
+	}
+	verifier := oauth2.GenerateVerifier()
+	authURL := conf.AuthCodeURL("state-1", oidc.Nonce("nonce-1"), oauth2.S256ChallengeOption(verifier))
+
+	// /oauth/authorize отправляет браузер на страницу согласия с параметрами запроса
+	consent := env.follow(t, authURL)
+	if consent.Host != "consent.example" {
+		t.Fatalf("authorize redirected to %s, want consent page", consent)
+	}
+	q := consent.Query()
+	result, err := env.svc.Authorize(env.userContext(), service.AuthorizeInput{
+		ResponseType:        q.Get("response_type"),
+		ClientID:            q.Get("client_id"),
+		RedirectURI:         q.Get("redirect_uri"),
+		Scope:               q.Get("scope"),
+		State:               q.Get("state"),
+		CodeChallenge:       q.Get("code_challenge"),
+		CodeChallengeMethod: q.Get("code_challenge_method"),
+		Nonce:               q.Get("nonce"),
+	})
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	callback, err := url.Parse(result.RedirectURI)
+	if err != nil {
+		t.Fatalf("callback: %v", err)
+	}
+	if got := callback.Query().Get("state"); got != "state-1" {
+		t.Fatalf("state = %q, want state-1", got)
+	}
+
+	token, err := conf.Exchange(ctx, callback.Query().Get("code"), oauth2.VerifierOption(verifier))
+	if err != nil {
+		t.Fatalf("token: %v", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		t.Fatal("token response has no id_token")
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: testClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		t.Fatalf("verify id token: %v", err)
+	}
+	if idToken.Nonce != "nonce-1" || idToken.Subject != env.user.ID {
+		t.Fatalf("id token nonce %q subject %q", idToken.Nonce, idToken.Subject)
+	}
+	var idClaims struct {
+		Email             string `json:"email"`
+		PreferredUsername string `json:"preferred_username"`
+	}
+	if err := idToken.Claims(&idClaims); err != nil {
+		t.Fatalf("id token claims: %v", err)
+	}
+	if idClaims.Email != env.user.Email || idClaims.PreferredUsername != env.user.Username {
+		t.Fatalf("id token claims = %+v", idClaims)
+	}
+
+	info, err := provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+	if err != nil {
+		t.Fatalf("userinfo: %v", err)
+	}
+	if info.Subject != env.user.ID || info.Email != env.user.Email || !info.EmailVerified {
+		t.Fatalf("userinfo = %+v", info)
+	}
+
+	logout := env.follow(t, discovery.EndSessionEndpoint+"?"+url.Values{
+		"id_token_hint":            {rawIDToken},
+		"post_logout_redirect_uri": {testLogoutURI},
+		"state":                    {"state-2"},
+	}.Encode())
+	if logout.Host != "client.example" || logout.Query().Get("state") != "state-2" {
+		t.Fatalf("end_session redirected to %s", logout)
+	}
+
+	// После выхода access-токен сессии больше не открывает /userinfo
+	if _, err := provider.UserInfo(ctx, oauth2.StaticTokenSource(token)); err == nil {
+		t.Fatal("userinfo accepted an access token of the ended session")
+	}
+}
+
+// С симметричным ключом OpenID Connect не предлагается и не выдаётся
+func TestOpenIDConnectRequiresAsymmetricKey(t *testing.T) {
+	env := newOIDCEnv(t, config.JWTKeyConfig{Algorithm: utils.AlgHS256, SecretKey: "conformance-hmac-secret"})
+
+	provider := env.svc.OpenIDProvider()
+	if slices.Contains(provider.Scopes, entity.ScopeOpenID) || len(provider.SigningAlgorithms) != 0 {
+		t.Fatalf("discovery offers OpenID Connect with HS256: %+v", provider)
+	}
+
+	result, err := env.svc.Authorize(env.userContext(), service.AuthorizeInput{
+		ResponseType: "code",
+		ClientID:     testClientID,
+		RedirectURI:  testRedirectURI,
+		Scope:        "openid",
+	})
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	callback, _ := url.Parse(result.RedirectURI)
+	if got := callback.Query().Get("error"); got != service.OAuthInvalidScope {
+		t.Fatalf("error = %q, want %s", got, service.OAuthInvalidScope)
+	}
+}
+
+type oidcEnv struct {
+	server *httptest.Server
+	svc    service.UserService
+	user   *entity.User
+}
+
+func newOIDCEnv(t *testing.T, key config.JWTKeyConfig) *oidcEnv {
+	t.Helper()
+
+	server := httptest.NewServer(nil)
+	t.Cleanup(server.Close)
+
+	cfg := &config.Config{
+		JWT: config.JWTConfig{
+			JWTKeyConfig:         key,
+			AccessTokenDuration:  15 * time.Minute,
+			RefreshTokenDuration: time.Hour,
+		},
+		OAuth: config.OAuthConfig{
+			Issuer:     server.URL,
+			ConsentURL: "https://consent.example/consent",
+			CodeTTL:    time.Minute,
+		},
+	}
+	jwtManager, err := utils.NewJWTManager(cfg)
+	if err != nil {
+		t.Fatalf("jwt manager: %v", err)
+	}
+
+	secretHash, err := utils.HashPassword(testClientSecret)
+	if err != nil {
+		t.Fatalf("hash secret: %v", err)
+	}
+	user := &entity.User{
+		ID:              "7d0c4a4e-5f4b-4a8e-9d7e-2f1d1a0b9c11",
+		Username:        "alice",
+		Name:            "Alice",
+		Email:           "alice@example.com",
+		EmailVerifiedAt: time.Now(),
+	}
+	users := &fakeUsers{users: map[string]*entity.User{user.ID: user}}
+	oauth := &fakeOAuth{
+		clients: map[string]*entity.OAuthClient{testClientID: {
+			ID:                     testClientID,
+			Name:                   "Conformance",
+			SecretHash:             secretHash,
+			RedirectURIs:           []string{testRedirectURI},
+			PostLogoutRedirectURIs: []string{testLogoutURI},
+			Scopes:                 []string{entity.ScopeOpenID, entity.ScopeProfile, entity.ScopeEmail},
+			GrantTypes:             []string{entity.GrantAuthorizationCode, entity.GrantRefreshToken},
+			FirstParty:             true,
+		}},
+		codes: map[string]*entity.OAuthAuthorizationCode{},
+	}
+
+	svc := service.NewUserService(
+		users, memory.NewTokenDenylist(), nil, fakeRoles{}, discardEvents{}, nil,
+		nil, nil, nil, nil, nil, oauth, nil, nil, nil, jwtManager, cfg,
+	)
+	server.Config.Handler = handler.NewHTTPHandler(svc)
+
+	return &oidcEnv{server: server, svc: svc, user: user}
+}
+
+// userContext — запрос страницы согласия от вошедшего пользователя
+func (e *oidcEnv) userContext() context.Context {
+	return principal.WithPrincipal(context.Background(), &principal.Principal{UserID: e.user.ID})
+}
+
+// follow выполняет GET и возвращает адрес перенаправления
+func (e *oidcEnv) follow(t *testing.T, rawURL string) *url.URL {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		t.Fatalf("GET %s: %v", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("GET %s: status %d, want 302", rawURL, resp.StatusCode)
+	}
+	location, err := resp.Location()
+	if err != nil {
+		t.Fatalf("GET %s: %v", rawURL, err)
+	}
+	return location
+}
+
+// signingKeyConfig создаёт ключ ES256 во временном PEM-файле
+func signingKeyConfig(t *testing.T) config.JWTKeyConfig {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwt.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return config.JWTKeyConfig{Algorithm: utils.AlgES256, PrivateKeyPath: path}
+}
+
+// fakeUsers хранит пользователей и refresh-токены в памяти; остальные
+// методы репозитория сценарию не нужны
+type fakeUsers struct {
+	repository.UserRepository
+
+	mu     sync.Mutex
+	users  map[string]*entity.User
+	tokens []*entity.RefreshToken
+}
+
+func (r *fakeUsers) GetByID(_ context.Context, id string) (*entity.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.users[id], nil
+}
+
+func (r *fakeUsers) SaveRefreshToken(_ context.Context, rt *entity.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens = append(r.tokens, rt)
+	return nil
+}
+
+func (r *fakeUsers) RevokeUserRefreshTokenFamily(_ context.Context, userID, familyID string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var revoked bool
+	for _, rt := range r.tokens {
+		if rt.UserID == userID && rt.FamilyID == familyID && !rt.Revoked {
+			rt.Revoked = true
+			revoked = true
+		}
+	}
+	return revoked, nil
+}
+
+type fakeOAuth struct {
+	repository.OAuthRepository
+
+	mu      sync.Mutex
+	clients map[string]*entity.OAuthClient
+	codes   map[string]*entity.OAuthAuthorizationCode
+}
+
+func (r *fakeOAuth) GetClient(_ context.Context, id string) (*entity.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.clients[id], nil
+}
+
+func (r *fakeOAuth) SaveAuthorizationCode(_ context.Context, code *entity.OAuthAuthorizationCode) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codes[code.Code] = code
+	return nil
+}
+
+func (r *fakeOAuth) ConsumeAuthorizationCode(_ context.Context, code string) (*entity.OAuthAuthorizationCode, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c := r.codes[code]
+	delete(r.codes, code)
+	return c, nil
+}
+
+type fakeRoles struct {
+	repository.RoleRepository
+}
+
+func (fakeRoles) UserPermissions(context.Context, string) ([]string, error) {
+	return nil, nil
+}
+
+type discardEvents struct{}
+
+func (discardEvents) Record(context.Context, *entity.AuthEvent) error {
+	return nil
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/service"
+	"auth-micro/internal/auth/utils"
+)
+
+// openIDConfiguration — метаданные провайдера (OpenID Connect Discovery 1.0, 3)
+type openIDConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	EndSessionEndpoint                string   `json:"end_session_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// userInfoResponse — ответ /userinfo (OIDC Core, 5.3.2)
+type userInfoResponse struct {
+	Subject string `json:"sub"`
+	utils.UserClaims
+}
+
+// discoveryHandler отдаёт /.well-known/openid-configuration. Адреса эндпоинтов
+// строятся от issuer, поэтому он должен совпадать с внешним адресом сервера
+func discoveryHandler(s service.UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provider := s.OpenIDProvider()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		json.NewEncoder(w).Encode(openIDConfiguration{
+			Issuer:                            provider.Issuer,
+			AuthorizationEndpoint:             provider.Issuer + "/oauth/authorize",
+			TokenEndpoint:                     provider.Issuer + "/oauth/token",
+			UserInfoEndpoint:                  provider.Issuer + "/userinfo",
+			JWKSURI:                           provider.Issuer + "/.well-known/jwks.json",
+			EndSessionEndpoint:                provider.Issuer + "/oauth/logout",
+			ScopesSupported:                   provider.Scopes,
+			ResponseTypesSupported:            []string{"code"},
+			GrantTypesSupported:               []string{entity.GrantAuthorizationCode, entity.GrantRefreshToken, entity.GrantClientCredentials},
+			SubjectTypesSupported:             []string{"public"},
+			IDTokenSigningAlgValuesSupported:  provider.SigningAlgorithms,
+			TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+			CodeChallengeMethodsSupported:     []string{"S256"},
+			ClaimsSupported: []string{
+				"sub", "iss", "aud", "exp", "iat", "nonce", "sid",
+				"name", "preferred_username", "updated_at", "email", "email_verified",
+			},
+		})
+	}
+}
+
+// userInfoHandler отдаёт claims пользователя по access-токену из заголовка Authorization
+func userInfoHandler(s service.UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			// Запрос без токена получает только схему (RFC 6750, 3.1)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "access token required", http.StatusUnauthorized)
+			return
+		}
+
+		info, err := s.UserInfo(r.Context(), token)
+		if err != nil {
+			writeBearerError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(userInfoResponse{Subject: info.Subject, UserClaims: info.UserClaims})
+	}
+}
+
+// endSessionHandler — выход, инициированный клиентом (OpenID Connect
+// RP-Initiated Logout 1.0). Параметры принимаются в query или в форме
+func endSessionHandler(s service.UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxGatewayBody)
+		if err := r.ParseForm(); err != nil {
+			writeOAuthError(w, &service.OAuthError{Code: service.OAuthInvalidRequest, Description: "invalid form body"})
+			return
+		}
+
+		redirect, err := s.EndSession(r.Context(), service.EndSessionInput{
+			IDTokenHint:           r.Form.Get("id_token_hint"),
+			ClientID:              r.Form.Get("client_id"),
+			PostLogoutRedirectURI: r.Form.Get("post_logout_redirect_uri"),
+			State:                 r.Form.Get("state"),
+		})
+		if err != nil {
+			writeOAuthError(w, err)
+			return
+		}
+		if redirect != "" {
+			http.Redirect(w, r, redirect, http.StatusFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		fmt.Fprintln(w, "You have been logged out")
+	}
+}
+
+// writeBearerError отвечает на ошибку доступа по bearer-токену (RFC 6750, 3)
+func writeBearerError(w http.ResponseWriter, err error) {
+	var oauthErr *service.OAuthError
+	if !errors.As(err, &oauthErr) {
+		log.Printf("userinfo request failed: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	code := http.StatusUnauthorized
+	if oauthErr.Code == service.OAuthInsufficientScope {
+		code = http.StatusForbidden
+	}
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error=%q, error_description=%q`, oauthErr.Code, oauthErr.Description))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+}
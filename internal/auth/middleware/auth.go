@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"auth-micro/internal/auth/principal"
+	"auth-micro/internal/auth/repository"
+	"auth-micro/internal/auth/utils"
+)
+
+// publicMethods — методы, доступные без access-токена
+var publicMethods = map[string]bool{
+	"/api.Auth/Register":                true,
+	"/api.Auth/Login":                   true,
+	"/api.Auth/VerifyMFA":               true,
+	"/api.Auth/BeginPasskeyLogin":       true,
+	"/api.Auth/FinishPasskeyLogin":      true,
+	"/api.Auth/BeginFederatedLogin":     true,
+	"/api.Auth/CompleteFederatedLogin":  true,
+	"/api.Auth/Logout":                  true,
+	"/api.Auth/RefreshToken":            true,
+	"/api.Auth/ValidateToken":           true,
+	"/api.Auth/GetJWKS":                 true,
+	"/api.Auth/VerifyEmail":             true,
+	"/api.Auth/ResendVerificationEmail": true,
+	"/api.Auth/RequestPasswordReset":    true,
+	"/api.Auth/ResetPassword":           true,
+}
+
+// errMissingToken — запрос без заголовка authorization
+var errMissingToken = status.Error(codes.Unauthenticated, "missing token")
+
+// AuthInterceptor проверяет "authorization: Bearer <jwt>" для всех методов,
+// кроме публичных, и кладёт principal в контекст запроса. Публичный метод
+// получает principal только с действительным токеном, а невалидный или
+// истёкший игнорирует: клиент обновляет токен как раз тогда, когда в
+// заголовке у него истёкший access-токен
+func AuthInterceptor(jwtManager *utils.JWTManager, denylist repository.TokenDenylist) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		p, err := authenticate(ctx, jwtManager, denylist)
+		if err != nil {
+			if publicMethods[info.FullMethod] {
+				return handler(ctx, req)
+			}
+			return nil, err
+		}
+
+		return handler(principal.WithPrincipal(ctx, p), req)
+	}
+}
+
+func authenticate(ctx context.Context, jwtManager *utils.JWTManager, denylist repository.TokenDenylist) (*principal.Principal, error) {
+	token, err := bearerToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := jwtManager.ValidateToken(token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	if claims.Type != "access" {
+		return nil, status.Error(codes.Unauthenticated, "access token required")
+	}
+
+	// Отзыв по jti, по сессии и по отсечке пользователя (отключён, удалён, принудительный выход)
+	revoked, err := denylist.IsRevoked(ctx, claims.AccessToken())
+	if err != nil {
+		log.Printf("failed to check token revocation: %v", err)
+		return nil, status.Error(codes.Unavailable, "failed to check token revocation")
+	}
+	if revoked {
+		return nil, status.Error(codes.Unauthenticated, "token revoked")
+	}
+
+	p := &principal.Principal{
+		UserID:    claims.UserID,
+		TokenID:   claims.ID,
+		SessionID: claims.SessionID,
+		Roles:     claims.Roles,
+		Scopes:    claims.Scopes,
+		ClientID:  claims.ClientID,
+	}
+	if claims.ExpiresAt != nil {
+		p.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return p, nil
+}
+
+func bearerToken(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", errMissingToken
+	}
+
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return "", errMissingToken
+	}
+
+	scheme, token, found := strings.Cut(values[0], " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", status.Error(codes.Unauthenticated, "authorization must be \"Bearer <token>\"")
+	}
+
+	return token, nil
+}
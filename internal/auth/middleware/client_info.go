@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"unicode/utf8"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"auth-micro/internal/auth/clientinfo"
+)
+
+// maxDeviceLabelLength — refresh_tokens.device_label VARCHAR(100)
+const maxDeviceLabelLength = 100
+
+// ClientInfoInterceptor кладёт в контекст IP клиента, user agent и метку устройства
+func ClientInfoInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		var ci clientinfo.Info
+
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			ci.IP = p.Addr.String()
+			if host, _, err := net.SplitHostPort(ci.IP); err == nil {
+				ci.IP = host
+			}
+		}
+
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			ci.UserAgent = firstValue(md, "user-agent")
+			ci.DeviceLabel = truncate(firstValue(md, "x-device-label"), maxDeviceLabelLength)
+		}
+
+		return handler(clientinfo.WithInfo(ctx, ci), req)
+	}
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// truncate обрезает s до max символов, не разрывая UTF-8
+func truncate(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return string([]rune(s)[:max])
+}
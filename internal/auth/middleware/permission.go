@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"auth-micro/internal/auth/config"
+	"auth-micro/internal/auth/principal"
+	pb "auth-micro/pkg/auth_v1"
+)
+
+// MethodPermissions — разрешения, нужные для вызова метода; ключ — имя метода в нижнем регистре
+type MethodPermissions map[string][]string
+
+// NewMethodPermissions собирает требования из опции required_permissions
+// методов auth.proto и из rbac.method_permissions; конфигурация главнее
+func NewMethodPermissions(cfg *config.Config) MethodPermissions {
+	perms := make(MethodPermissions)
+
+	services := pb.File_auth_proto.Services()
+	for i := 0; i < services.Len(); i++ {
+		methods := services.Get(i).Methods()
+		for j := 0; j < methods.Len(); j++ {
+			m := methods.Get(j)
+			required, _ := proto.GetExtension(m.Options(), pb.E_RequiredPermissions).([]string)
+			if len(required) > 0 {
+				perms[strings.ToLower(string(m.Name()))] = required
+			}
+		}
+	}
+
+	for method, required := range cfg.RBAC.MethodPermissions {
+		perms[method] = required
+	}
+	return perms
+}
+
+// PermissionInterceptor отклоняет вызов, если в scopes токена нет всех
+// разрешений метода. Токену OAuth-клиента доступны только публичные методы
+// и методы с разрешениями: управлять аккаунтом пользователя клиент не может.
+// Должен стоять после AuthInterceptor
+func PermissionInterceptor(perms MethodPermissions) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		required := perms[strings.ToLower(path.Base(info.FullMethod))]
+		p, ok := principal.FromContext(ctx)
+		if len(required) == 0 {
+			if ok && p.ClientID != "" && !publicMethods[info.FullMethod] {
+				return nil, status.Error(codes.PermissionDenied, "method is not available to oauth clients")
+			}
+			return handler(ctx, req)
+		}
+
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "missing token")
+		}
+		for _, permission := range required {
+			if !p.HasScope(permission) {
+				return nil, status.Errorf(codes.PermissionDenied, "permission %q required", permission)
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
@@ -2,14 +2,208 @@ package middleware
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"path"
+	"strings"
+	"sync"
+	"time"
 
-	"go.uber.org/ratelimit"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"auth-micro/internal/auth/clientinfo"
+	"auth-micro/internal/auth/config"
+	"auth-micro/internal/auth/principal"
 )
 
-func RateLimitInterceptor(rl ratelimit.Limiter) grpc.UnaryServerInterceptor {
+// RateLimiter ограничивает запросы отдельно по IP клиента, по пользователю
+// из токена и по username из запроса. У каждого ключа свой token bucket,
+// так что шумный клиент не тормозит остальных
+type RateLimiter struct {
+	cfg config.RateLimitConfig
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// bucketIdleTTL — через сколько неиспользуемая (и уже полная) корзина удаляется
+const bucketIdleTTL = 10 * time.Minute
+
+// maxUsernameKeyLength — users.username VARCHAR(50): длиннее имён не бывает,
+// а ключ из сырого username запроса иначе ничем не ограничен
+const maxUsernameKeyLength = 50
+
+func NewRateLimiter(cfg *config.Config) *RateLimiter {
+	return &RateLimiter{
+		cfg:       cfg.RateLimit,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// usernameRequest — запросы, в которых есть целевой username (Login, Register)
+type usernameRequest interface {
+	GetUsername() string
+}
+
+// RateLimitInterceptor отклоняет запросы сверх лимита по IP и по username
+// с ResourceExhausted и подсказкой retry-after, не блокируя горутину.
+// Ставится после ClientInfoInterceptor, но до AuthInterceptor: иначе запросы
+// с невалидным токеном отклоняются, не расходуя лимит
+func RateLimitInterceptor(rl *RateLimiter) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-		rl.Take()
+		limits, scope := rl.limitsFor(path.Base(info.FullMethod))
+
+		// IP проверяется первым: отклонённый по нему запрос не трогает корзину username
+		var keys []limitKey
+		if ip := clientinfo.FromContext(ctx).IP; ip != "" {
+			keys = append(keys, limitKey{scope + "|ip|" + ip, limits.PerIP})
+		}
+		if r, ok := req.(usernameRequest); ok && r.GetUsername() != "" {
+			keys = append(keys, limitKey{scope + "|username|" + usernameKey(r.GetUsername()), limits.PerUsername})
+		}
+
+		if wait, ok := rl.allow(keys, time.Now()); !ok {
+			return nil, exhausted(ctx, wait)
+		}
+
 		return handler(ctx, req)
 	}
 }
+
+// UserRateLimitInterceptor ограничивает запросы по пользователю из токена.
+// Ставится после AuthInterceptor — пользователь берётся из контекста
+func UserRateLimitInterceptor(rl *RateLimiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		p, ok := principal.FromContext(ctx)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		limits, scope := rl.limitsFor(path.Base(info.FullMethod))
+		keys := []limitKey{{scope + "|user|" + p.UserID, limits.PerUser}}
+		if wait, ok := rl.allow(keys, time.Now()); !ok {
+			return nil, exhausted(ctx, wait)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+type limitKey struct {
+	key   string
+	limit config.RateLimit
+}
+
+// limitsFor возвращает лимиты метода и область корзин: у методов с
+// собственными лимитами корзины свои, остальные делят общие
+func (rl *RateLimiter) limitsFor(method string) (config.MethodRateLimit, string) {
+	def := config.RateLimit{
+		RequestsPerSecond: float64(rl.cfg.RequestsPerSecond),
+		Burst:             rl.cfg.Burst,
+	}
+
+	m, ok := rl.cfg.Methods[strings.ToLower(method)]
+	if !ok {
+		return config.MethodRateLimit{PerIP: def, PerUser: def}, "*"
+	}
+	if m.PerIP.RequestsPerSecond <= 0 {
+		m.PerIP = def
+	}
+	if m.PerUser.RequestsPerSecond <= 0 {
+		m.PerUser = def
+	}
+	return m, method
+}
+
+// usernameKey приводит username запроса к ключу корзины ограниченной длины
+func usernameKey(username string) string {
+	key := []rune(strings.ToLower(username))
+	if len(key) > maxUsernameKeyLength {
+		key = key[:maxUsernameKeyLength]
+	}
+	return string(key)
+}
+
+// allow списывает по токену со всех корзин запроса. Корзины проверяются по
+// порядку ключей; на первой пустой запрос отклоняется с временем до появления
+// токена, и ни одна корзина не меняется и не создаётся
+func (rl *RateLimiter) allow(keys []limitKey, now time.Time) (time.Duration, bool) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	rl.sweep(now)
+
+	tokens := make([]float64, len(keys))
+	for i, k := range keys {
+		if k.limit.RequestsPerSecond <= 0 {
+			continue
+		}
+		tokens[i] = rl.tokens(k, now)
+		if tokens[i] < 1 {
+			return time.Duration((1 - tokens[i]) / k.limit.RequestsPerSecond * float64(time.Second)), false
+		}
+	}
+
+	for i, k := range keys {
+		if k.limit.RequestsPerSecond <= 0 {
+			continue
+		}
+		if b, ok := rl.buckets[k.key]; ok {
+			b.tokens, b.lastSeen = tokens[i]-1, now
+		} else {
+			rl.buckets[k.key] = &bucket{tokens: tokens[i] - 1, lastSeen: now}
+		}
+	}
+	return 0, true
+}
+
+// tokens возвращает число токенов в корзине ключа на момент now, не меняя её.
+// Отсутствующая корзина полна
+func (rl *RateLimiter) tokens(k limitKey, now time.Time) float64 {
+	burst := float64(k.limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	b, ok := rl.buckets[k.key]
+	if !ok {
+		return burst
+	}
+	return math.Min(burst, b.tokens+now.Sub(b.lastSeen).Seconds()*k.limit.RequestsPerSecond)
+}
+
+// sweep удаляет давно не использовавшиеся корзины, чтобы карта не росла бесконечно
+func (rl *RateLimiter) sweep(now time.Time) {
+	if now.Sub(rl.lastSweep) < bucketIdleTTL {
+		return
+	}
+	rl.lastSweep = now
+	for key, b := range rl.buckets {
+		if now.Sub(b.lastSeen) > bucketIdleTTL {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// exhausted формирует ResourceExhausted с RetryInfo и заголовком retry-after (секунды)
+func exhausted(ctx context.Context, wait time.Duration) error {
+	seconds := int(math.Ceil(wait.Seconds()))
+	_ = grpc.SetHeader(ctx, metadata.Pairs("retry-after", fmt.Sprint(seconds)))
+
+	st := status.New(codes.ResourceExhausted, fmt.Sprintf("rate limit exceeded, retry after %ds", seconds))
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(wait)}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
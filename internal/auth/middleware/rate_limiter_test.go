@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"auth-micro/internal/auth/config"
+)
+
+// Запрос, отклонённый по IP, не заводит корзину username
+func TestRateLimiterDenialTouchesNoBuckets(t *testing.T) {
+	rl := NewRateLimiter(&config.Config{})
+	limit := config.RateLimit{RequestsPerSecond: 1, Burst: 1}
+	now := time.Now()
+
+	ip := limitKey{"login|ip|192.0.2.1", limit}
+	if _, ok := rl.allow([]limitKey{ip, {"login|username|alice", limit}}, now); !ok {
+		t.Fatal("first request denied")
+	}
+
+	for i := 0; i < 100; i++ {
+		username := limitKey{"login|username|" + usernameKey(strings.Repeat("x", i+1)), limit}
+		if _, ok := rl.allow([]limitKey{ip, username}, now); ok {
+			t.Fatal("request over the IP limit allowed")
+		}
+	}
+	if n := len(rl.buckets); n != 2 {
+		t.Fatalf("%d buckets, want 2", n)
+	}
+}
+
+func TestUsernameKeyIsBounded(t *testing.T) {
+	if key := usernameKey(strings.Repeat("Я", 10000)); len([]rune(key)) != maxUsernameKeyLength {
+		t.Fatalf("key length = %d, want %d", len([]rune(key)), maxUsernameKeyLength)
+	}
+	if key := usernameKey("Alice"); key != "alice" {
+		t.Fatalf("key = %q, want alice", key)
+	}
+}
@@ -0,0 +1,74 @@
+package outbox
+
+import (
+	"fmt"
+	"time"
+
+	"auth-micro/internal/auth/entity"
+	pb "auth-micro/pkg/auth_v1"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Конструкторы событий для записи в outbox вместе с изменением пользователя
+
+func UserRegistered(u *entity.User) (*entity.OutboxEvent, error) {
+	return newEvent(u.ID, entity.OutboxUserRegistered, func(e *pb.UserEvent) {
+		e.Event = &pb.UserEvent_Registered{Registered: &pb.UserRegistered{
+			Username: u.Username,
+			Email:    u.Email,
+			Roles:    u.Roles,
+		}}
+	})
+}
+
+func UserEmailChanged(userID, oldEmail, newEmail string) (*entity.OutboxEvent, error) {
+	return newEvent(userID, entity.OutboxUserEmailChanged, func(e *pb.UserEvent) {
+		e.Event = &pb.UserEvent_EmailChanged{EmailChanged: &pb.UserEmailChanged{
+			OldEmail: oldEmail,
+			NewEmail: newEmail,
+		}}
+	})
+}
+
+func UserDisabled(userID string) (*entity.OutboxEvent, error) {
+	return newEvent(userID, entity.OutboxUserDisabled, func(e *pb.UserEvent) {
+		e.Event = &pb.UserEvent_Disabled{Disabled: &pb.UserDisabled{}}
+	})
+}
+
+func UserEnabled(userID string) (*entity.OutboxEvent, error) {
+	return newEvent(userID, entity.OutboxUserEnabled, func(e *pb.UserEvent) {
+		e.Event = &pb.UserEvent_Enabled{Enabled: &pb.UserEnabled{}}
+	})
+}
+
+func UserDeleted(userID string) (*entity.OutboxEvent, error) {
+	return newEvent(userID, entity.OutboxUserDeleted, func(e *pb.UserEvent) {
+		e.Event = &pb.UserEvent_Deleted{Deleted: &pb.UserDeleted{}}
+	})
+}
+
+func newEvent(userID, eventType string, fill func(*pb.UserEvent)) (*entity.OutboxEvent, error) {
+	now := time.Now()
+	event := &pb.UserEvent{
+		Id:         uuid.NewString(),
+		UserId:     userID,
+		OccurredAt: timestamppb.New(now),
+	}
+	fill(event)
+
+	payload, err := proto.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s event: %w", eventType, err)
+	}
+	return &entity.OutboxEvent{
+		ID:        event.Id,
+		UserID:    userID,
+		Type:      eventType,
+		Payload:   payload,
+		CreatedAt: now,
+	}, nil
+}
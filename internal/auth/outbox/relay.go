@@ -0,0 +1,135 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"auth-micro/internal/auth/config"
+	"auth-micro/internal/auth/publisher"
+	"auth-micro/internal/auth/repository"
+)
+
+const (
+	// Пауза перед повтором растёт вдвое с каждой неудачей, но не дольше maxRetryDelay
+	baseRetryDelay = time.Second
+	maxRetryDelay  = 5 * time.Minute
+
+	publishTimeout = 10 * time.Second
+	purgeInterval  = time.Hour
+)
+
+// Relay доставляет события из outbox издателю «хотя бы один раз»: событие
+// отмечается доставленным только после подтверждения брокера. События одного
+// пользователя доставляются в порядке создания, разных — независимо
+type Relay struct {
+	repo      repository.OutboxRepository
+	publisher publisher.Publisher
+	cfg       config.OutboxConfig
+}
+
+func NewRelay(repo repository.OutboxRepository, pub publisher.Publisher, cfg *config.Config) *Relay {
+	return &Relay{repo: repo, publisher: pub, cfg: cfg.Outbox}
+}
+
+// Run доставляет события, пока не отменён ctx
+func (r *Relay) Run(ctx context.Context) {
+	interval := r.cfg.PollInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastPurge := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for ctx.Err() == nil {
+			more, err := r.deliverBatch(ctx)
+			if err != nil {
+				log.Printf("Outbox relay: %v", err)
+				break
+			}
+			if !more {
+				break
+			}
+		}
+
+		if r.cfg.Retention > 0 && time.Since(lastPurge) >= purgeInterval {
+			lastPurge = time.Now()
+			purged, err := r.repo.DeletePublished(ctx, r.cfg.Retention)
+			if err != nil {
+				log.Printf("Failed to purge outbox: %v", err)
+			} else if purged > 0 {
+				log.Printf("Purged %d published outbox events", purged)
+			}
+		}
+	}
+}
+
+// deliverBatch публикует пачку событий; more — пачка не пуста и стоит сразу
+// забрать следующую: за доставленными событиями пользователей могут ждать
+// их следующие. В пачке у каждого пользователя одно событие (см. ClaimPending),
+// поэтому ошибка одного не задерживает остальных, а следующие события того же
+// пользователя не будут забраны, пока не доставлено неудавшееся
+func (r *Relay) deliverBatch(ctx context.Context) (more bool, err error) {
+	events, err := r.repo.ClaimPending(ctx, r.batchSize(), r.lease())
+	if err != nil {
+		return false, err
+	}
+
+	for _, e := range events {
+		pubCtx, cancel := context.WithTimeout(ctx, publishTimeout)
+		err := r.publisher.Publish(pubCtx, publisher.Message{
+			ID:        e.ID,
+			Key:       e.UserID,
+			Type:      e.Type,
+			Payload:   e.Payload,
+			CreatedAt: e.CreatedAt,
+		})
+		cancel()
+		if err != nil {
+			if ferr := r.repo.MarkFailed(ctx, e.ID, err.Error(), retryDelay(e.Attempts)); ferr != nil {
+				log.Printf("Failed to record outbox event %s failure: %v", e.ID, ferr)
+			}
+			log.Printf("Failed to publish outbox event %s (attempt %d): %v", e.ID, e.Attempts+1, err)
+			continue
+		}
+
+		// Если отметка не сохранится, событие будет доставлено повторно
+		if err := r.repo.MarkPublished(ctx, e.ID); err != nil {
+			return false, err
+		}
+	}
+	return len(events) > 0, nil
+}
+
+func (r *Relay) batchSize() int {
+	if r.cfg.BatchSize <= 0 {
+		return 100
+	}
+	return r.cfg.BatchSize
+}
+
+func (r *Relay) lease() time.Duration {
+	if r.cfg.Lease <= 0 {
+		return 30 * time.Second
+	}
+	return r.cfg.Lease
+}
+
+func retryDelay(attempts int) time.Duration {
+	d := baseRetryDelay
+	for i := 0; i < attempts && d < maxRetryDelay; i++ {
+		d *= 2
+	}
+	if d > maxRetryDelay {
+		d = maxRetryDelay
+	}
+	return d
+}
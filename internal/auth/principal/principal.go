@@ -0,0 +1,51 @@
+package principal
+
+import (
+	"context"
+	"time"
+)
+
+// Principal — аутентифицированный вызывающий, извлечённый из access-токена
+type Principal struct {
+	UserID    string
+	TokenID   string
+	SessionID string
+	Roles     []string
+	Scopes    []string  // разрешения ролей на момент выпуска токена
+	ExpiresAt time.Time // срок access-токена, нужен для его отзыва
+	// ClientID — OAuth-клиент, которому выдан токен; у client credentials UserID пуст
+	ClientID string
+}
+
+type ctxKey struct{}
+
+// WithPrincipal кладёт principal в контекст запроса
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// FromContext достаёт principal, положенный AuthInterceptor
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// HasRole проверяет, есть ли у вызывающего роль
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope проверяет, выдан ли токену scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
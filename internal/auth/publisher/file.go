@@ -0,0 +1,71 @@
+package publisher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	pb "auth-micro/pkg/auth_v1"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+type filePublisher struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+}
+
+// NewStdoutPublisher печатает события в stdout — для локальной разработки
+func NewStdoutPublisher() Publisher {
+	return &filePublisher{w: os.Stdout}
+}
+
+// NewFilePublisher дописывает события в path по одному JSON на строку,
+// чтобы тесты и разработчики могли прочитать их локально
+func NewFilePublisher(path string) (Publisher, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open outbox file: %w", err)
+	}
+	return &filePublisher{w: f, closer: f}, nil
+}
+
+func (p *filePublisher) Publish(_ context.Context, msg Message) error {
+	var event pb.UserEvent
+	if err := proto.Unmarshal(msg.Payload, &event); err != nil {
+		return fmt.Errorf("decode event %s: %w", msg.ID, err)
+	}
+	payload, err := protojson.Marshal(&event)
+	if err != nil {
+		return fmt.Errorf("encode event %s: %w", msg.ID, err)
+	}
+
+	line, err := json.Marshal(struct {
+		ID        string          `json:"id"`
+		Key       string          `json:"key"`
+		Type      string          `json:"type"`
+		CreatedAt time.Time       `json:"createdAt"`
+		Event     json.RawMessage `json:"event"`
+	}{msg.ID, msg.Key, msg.Type, msg.CreatedAt, payload})
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, err = p.w.Write(append(line, '\n'))
+	return err
+}
+
+func (p *filePublisher) Close() error {
+	if p.closer == nil {
+		return nil
+	}
+	return p.closer.Close()
+}
@@ -0,0 +1,42 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+type natsPublisher struct {
+	conn    *nats.Conn
+	js      nats.JetStreamContext
+	subject string
+}
+
+// NewNATSPublisher публикует события в JetStream: в отличие от core NATS,
+// приём подтверждается, а повтор с тем же Nats-Msg-Id отбрасывается потоком
+func NewNATSPublisher(url, subject string) (Publisher, error) {
+	conn, err := nats.Connect(url)
+	if err != nil {
+		return nil, fmt.Errorf("connect to nats: %w", err)
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("init jetstream: %w", err)
+	}
+	return &natsPublisher{conn: conn, js: js, subject: subject}, nil
+}
+
+func (p *natsPublisher) Publish(ctx context.Context, msg Message) error {
+	m := nats.NewMsg(p.subject)
+	m.Data = msg.Payload
+	m.Header.Set("Event-Type", msg.Type)
+	m.Header.Set("Event-Key", msg.Key)
+	_, err := p.js.PublishMsg(m, nats.MsgId(msg.ID), nats.Context(ctx))
+	return err
+}
+
+func (p *natsPublisher) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,41 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"auth-micro/internal/auth/config"
+)
+
+// Message — событие для других сервисов
+type Message struct {
+	ID        string // одинаков при повторной доставке
+	Key       string // id пользователя: события одного пользователя идут в одну партицию
+	Type      string
+	Payload   []byte // api.UserEvent в protobuf
+	CreatedAt time.Time
+}
+
+// Publisher доставляет события брокеру. Publish возвращает nil только
+// после того, как брокер подтвердил приём
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// New выбирает реализацию по cfg.Outbox.Publisher
+func New(cfg *config.Config) (Publisher, error) {
+	switch cfg.Outbox.Publisher {
+	case "", "stdout":
+		return NewStdoutPublisher(), nil
+	case "file":
+		return NewFilePublisher(cfg.Outbox.FilePath)
+	case "kafka":
+		return NewKafkaPublisher(cfg.Outbox.KafkaBrokers, cfg.Outbox.Topic)
+	case "nats":
+		return NewNATSPublisher(cfg.Outbox.NATSURL, cfg.Outbox.Topic)
+	default:
+		return nil, fmt.Errorf("unknown outbox publisher %q", cfg.Outbox.Publisher)
+	}
+}
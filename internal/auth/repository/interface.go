@@ -3,17 +3,179 @@ package repository
 import (
 	"auth-micro/internal/auth/entity"
 	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrRefreshTokenRevoked возвращается при попытке ротировать уже отозванный токен
+	ErrRefreshTokenRevoked = errors.New("refresh token already revoked")
+	// ErrEmailTaken возвращается, если email уже занят другим пользователем
+	ErrEmailTaken = errors.New("email already taken")
+	// ErrRoleExists возвращается при создании роли с занятым именем
+	ErrRoleExists = errors.New("role already exists")
+	// ErrRoleNotFound возвращается, если роли или разрешения нет в справочнике
+	ErrRoleNotFound = errors.New("role or permission not found")
 )
 
 type UserRepository interface {
 	Create(ctx context.Context, user *entity.User) error
 	GetByUsername(ctx context.Context, username string) (*entity.User, error)
+	// GetByEmail ищет email без учёта регистра
 	GetByEmail(ctx context.Context, email string) (*entity.User, error)
 
 	SaveRefreshToken(ctx context.Context, rt *entity.RefreshToken) error
 	GetRefreshToken(ctx context.Context, token string) (*entity.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, oldID string, newToken *entity.RefreshToken) error
 	RevokeRefreshToken(ctx context.Context, token string) error
-	RevokeUserRefreshTokens(ctx context.Context, userID string) error
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+	// RevokeUserRefreshTokens и RevokeOtherUserRefreshTokens возвращают ID завершённых сессий
+	RevokeUserRefreshTokens(ctx context.Context, userID string) ([]string, error)
+	RevokeOtherUserRefreshTokens(ctx context.Context, userID, keepFamilyID string) ([]string, error)
+	RevokeUserRefreshTokenFamily(ctx context.Context, userID, familyID string) (bool, error)
+	// DeleteExpiredRefreshTokens удаляет истёкшие refresh-токены. Отозванные, но
+	// не истёкшие остаются: по ним распознаётся повторное использование
+	DeleteExpiredRefreshTokens(ctx context.Context) (int64, error)
+	ListSessions(ctx context.Context, userID string) ([]*entity.Session, error)
 	GetByID(ctx context.Context, id string) (*entity.User, error)
 	UpdatePassword(ctx context.Context, userID, hashedPassword string) error
+	UpdateProfile(ctx context.Context, userID string, upd entity.UserProfileUpdate) (*entity.User, error)
+	// MarkEmailVerified возвращает false, если email пользователя уже не совпадает с переданным
+	MarkEmailVerified(ctx context.Context, userID, email string) (bool, error)
+	ListUsers(ctx context.Context, filter entity.UserFilter) ([]*entity.User, error)
+	SetDisabled(ctx context.Context, userID string, disabled bool) (bool, error)
+	DeleteUser(ctx context.Context, userID string) (bool, error)
+}
+
+// OutboxRepository — события для других сервисов, ожидающие доставки.
+// Записываются репозиторием пользователей в его транзакциях
+type OutboxRepository interface {
+	// ClaimPending забирает до limit недоставленных событий на время lease,
+	// чтобы их не доставлял параллельно другой экземпляр. От каждого
+	// пользователя забирается только самое раннее недоставленное событие,
+	// поэтому события одного пользователя доставляются по порядку
+	ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]*entity.OutboxEvent, error)
+	MarkPublished(ctx context.Context, id string) error
+	// MarkFailed сохраняет ошибку; следующая попытка — не раньше чем через retryAfter
+	MarkFailed(ctx context.Context, id, reason string, retryAfter time.Duration) error
+	DeletePublished(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+// EventSink принимает события безопасности. Журнал в БД — одна из реализаций,
+// экспорт в другие системы подключается через этот же интерфейс
+type EventSink interface {
+	Record(ctx context.Context, event *entity.AuthEvent) error
+}
+
+// AuthEventRepository — журнал событий безопасности; записи только добавляются
+type AuthEventRepository interface {
+	EventSink
+	ListAuthEvents(ctx context.Context, filter entity.AuthEventFilter) ([]*entity.AuthEvent, error)
+}
+
+// TokenDenylist — отозванные до истечения срока access-токены: по jti,
+// все токены сессии или все токены пользователя, выданные до момента. Запись нужна только до exp последнего такого токена,
+// после этого её можно удалить
+type TokenDenylist interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	// RevokeSessions отзывает access-токены, выданные в сессиях sessionIDs
+	RevokeSessions(ctx context.Context, sessionIDs []string, expiresAt time.Time) error
+	// RevokeUser отзывает access-токены пользователя, выданные раньше before
+	RevokeUser(ctx context.Context, userID string, before, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, token entity.AccessToken) (bool, error)
+	PurgeExpired(ctx context.Context) (int64, error)
+}
+
+// LoginAttemptRepository хранит счётчики неудачных входов
+type LoginAttemptRepository interface {
+	// LockedUntil возвращает самую позднюю блокировку среди ключей (нулевое время — нет блокировки)
+	LockedUntil(ctx context.Context, keys ...string) (time.Time, error)
+	// RegisterFailure увеличивает счётчик; если последняя ошибка была раньше
+	// now-window, счёт начинается заново, а после now-resetAfter обнуляются и блокировки
+	RegisterFailure(ctx context.Context, key string, window, resetAfter time.Duration) (*entity.LoginAttempts, error)
+	// Lock блокирует ключ до until, сбрасывает счётчик и увеличивает число блокировок
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, keys ...string) error
+	// DeleteStale удаляет счётчики без ошибок за olderThan и без действующей блокировки
+	DeleteStale(ctx context.Context, olderThan time.Duration) (int64, error)
+}
+
+// OneTimeTokenRepository хранит одноразовые токены из писем
+type OneTimeTokenRepository interface {
+	Save(ctx context.Context, token *entity.OneTimeToken) error
+	// Consume атомарно помечает токен использованным и возвращает его;
+	// nil, nil — токена нет, он истёк или уже использован
+	Consume(ctx context.Context, purpose, token string) (*entity.OneTimeToken, error)
+	// DeleteExpired удаляет истёкшие токены, в том числе использованные
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// MFARepository хранит второй фактор пользователей
+type MFARepository interface {
+	// SaveTOTP начинает подключение заново: неподтверждённый секрет заменяет прежний
+	SaveTOTP(ctx context.Context, userID string, secretEncrypted []byte) error
+	GetTOTP(ctx context.Context, userID string) (*entity.TOTPFactor, error)
+	// ConfirmTOTP включает фактор и заменяет коды восстановления
+	ConfirmTOTP(ctx context.Context, userID string, step int64, recoveryCodes []string) error
+	// UseTOTPStep запоминает принятый интервал; false — код этого или
+	// более позднего интервала уже использовался
+	UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error)
+	// DeleteTOTP отключает фактор вместе с кодами восстановления
+	DeleteTOTP(ctx context.Context, userID string) error
+	// UseRecoveryCode гасит код; false — кода нет или он уже использован
+	UseRecoveryCode(ctx context.Context, userID, code string) (bool, error)
+}
+
+// WebAuthnRepository хранит ключи доступа и незавершённые церемонии WebAuthn
+type WebAuthnRepository interface {
+	SaveCredential(ctx context.Context, cred *entity.WebAuthnCredential) error
+	ListCredentials(ctx context.Context, userID string) ([]*entity.WebAuthnCredential, error)
+	// UpdateCredentialUsage сохраняет счётчик подписей после успешного входа
+	UpdateCredentialUsage(ctx context.Context, credentialID []byte, signCount uint32, backupState bool) error
+
+	SaveSession(ctx context.Context, session *entity.WebAuthnSession) error
+	// ConsumeSession удаляет и возвращает церемонию; nil, nil — её нет или она истекла
+	ConsumeSession(ctx context.Context, id, purpose string) (*entity.WebAuthnSession, error)
+}
+
+// OAuthRepository хранит OAuth-клиентов, коды авторизации и согласия пользователей
+type OAuthRepository interface {
+	CreateClient(ctx context.Context, client *entity.OAuthClient) error
+	// GetClient возвращает nil, nil, если клиента нет
+	GetClient(ctx context.Context, id string) (*entity.OAuthClient, error)
+	ListClients(ctx context.Context) ([]*entity.OAuthClient, error)
+	// DeleteClient удаляет клиента вместе с его кодами, согласиями и refresh-токенами
+	DeleteClient(ctx context.Context, id string) (bool, error)
+
+	SaveAuthorizationCode(ctx context.Context, code *entity.OAuthAuthorizationCode) error
+	// ConsumeAuthorizationCode удаляет и возвращает код; nil, nil — его нет или он истёк
+	ConsumeAuthorizationCode(ctx context.Context, code string) (*entity.OAuthAuthorizationCode, error)
+
+	// ConsentedScopes возвращает scope, на которые пользователь уже согласился
+	ConsentedScopes(ctx context.Context, userID, clientID string) ([]string, error)
+	// SaveConsent добавляет scope к согласию пользователя
+	SaveConsent(ctx context.Context, userID, clientID string, scopes []string) error
+}
+
+// FederationRepository хранит привязки внешних учётных записей и незавершённые входы через провайдеров
+type FederationRepository interface {
+	// GetIdentity возвращает nil, nil, если учётная запись не привязана
+	GetIdentity(ctx context.Context, provider, subject string) (*entity.FederatedIdentity, error)
+	LinkIdentity(ctx context.Context, identity *entity.FederatedIdentity) error
+
+	SaveLoginState(ctx context.Context, state *entity.FederatedLoginState) error
+	// ConsumeLoginState удаляет и возвращает состояние; nil, nil — его нет или оно истекло
+	ConsumeLoginState(ctx context.Context, state string) (*entity.FederatedLoginState, error)
+}
+
+// RoleRepository хранит роли, их разрешения и назначения пользователям
+type RoleRepository interface {
+	ListRoles(ctx context.Context) ([]*entity.Role, error)
+	CreateRole(ctx context.Context, role *entity.Role) error
+	// AssignRole идемпотентна: повторное назначение не ошибка
+	AssignRole(ctx context.Context, userID, role string) error
+	// RevokeRole возвращает false, если роль не была назначена
+	RevokeRole(ctx context.Context, userID, role string) (bool, error)
+	// UserPermissions возвращает объединение разрешений всех ролей пользователя
+	UserPermissions(ctx context.Context, userID string) ([]string, error)
 }
@@ -0,0 +1,102 @@
+package memory
+
+import (
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/repository"
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenDenylist хранит отозванные jti, сессии и отсечки пользователей в памяти процесса.
+// Подходит для одного инстанса; при нескольких репликах нужен postgres
+type tokenDenylist struct {
+	mu       sync.RWMutex
+	revoked  map[string]time.Time
+	sessions map[string]time.Time
+	users    map[string]userCutoff
+}
+
+// userCutoff — токены пользователя, выданные до before, отозваны до expiresAt
+type userCutoff struct {
+	before    time.Time
+	expiresAt time.Time
+}
+
+func NewTokenDenylist() repository.TokenDenylist {
+	return &tokenDenylist{
+		revoked:  make(map[string]time.Time),
+		sessions: make(map[string]time.Time),
+		users:    make(map[string]userCutoff),
+	}
+}
+
+func (d *tokenDenylist) Revoke(_ context.Context, jti string, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.revoked[jti] = expiresAt
+	return nil
+}
+
+func (d *tokenDenylist) RevokeSessions(_ context.Context, sessionIDs []string, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, id := range sessionIDs {
+		if expiresAt.After(d.sessions[id]) {
+			d.sessions[id] = expiresAt
+		}
+	}
+	return nil
+}
+
+func (d *tokenDenylist) RevokeUser(_ context.Context, userID string, before, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	cutoff := d.users[userID]
+	if before.After(cutoff.before) {
+		cutoff.before = before
+	}
+	if expiresAt.After(cutoff.expiresAt) {
+		cutoff.expiresAt = expiresAt
+	}
+	d.users[userID] = cutoff
+	return nil
+}
+
+func (d *tokenDenylist) IsRevoked(_ context.Context, token entity.AccessToken) (bool, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	now := time.Now()
+	if expiresAt, ok := d.revoked[token.ID]; ok && now.Before(expiresAt) {
+		return true, nil
+	}
+	if expiresAt, ok := d.sessions[token.SessionID]; ok && now.Before(expiresAt) {
+		return true, nil
+	}
+	// iat округлён до секунды: токен той же секунды считаем отозванным
+	cutoff, ok := d.users[token.UserID]
+	return ok && now.Before(cutoff.expiresAt) && token.IssuedAt.Before(cutoff.before), nil
+}
+
+func (d *tokenDenylist) PurgeExpired(_ context.Context) (int64, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	var purged int64
+	for _, entries := range []map[string]time.Time{d.revoked, d.sessions} {
+		for key, expiresAt := range entries {
+			if now.After(expiresAt) {
+				delete(entries, key)
+				purged++
+			}
+		}
+	}
+	for userID, cutoff := range d.users {
+		if now.After(cutoff.expiresAt) {
+			delete(d.users, userID)
+			purged++
+		}
+	}
+	return purged, nil
+}
@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"auth-micro/client"
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/repository"
+	"context"
+	"time"
+)
+
+type tokenDenylist struct {
+	db *client.DB
+}
+
+func NewTokenDenylist(db *client.DB) repository.TokenDenylist {
+	return &tokenDenylist{db: db}
+}
+
+func (d *tokenDenylist) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := d.db.Pool.Exec(ctx, `
+		INSERT INTO revoked_tokens (jti, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING
+	`, jti, expiresAt)
+	return err
+}
+
+func (d *tokenDenylist) RevokeSessions(ctx context.Context, sessionIDs []string, expiresAt time.Time) error {
+	_, err := d.db.Pool.Exec(ctx, `
+		INSERT INTO revoked_sessions (session_id, expires_at)
+		SELECT unnest($1::text[]), $2
+		ON CONFLICT (session_id) DO UPDATE
+		SET expires_at = GREATEST(revoked_sessions.expires_at, EXCLUDED.expires_at)
+	`, sessionIDs, expiresAt)
+	return err
+}
+
+func (d *tokenDenylist) RevokeUser(ctx context.Context, userID string, before, expiresAt time.Time) error {
+	_, err := d.db.Pool.Exec(ctx, `
+		INSERT INTO revoked_user_tokens (user_id, revoked_before, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET revoked_before = GREATEST(revoked_user_tokens.revoked_before, EXCLUDED.revoked_before),
+		    expires_at = GREATEST(revoked_user_tokens.expires_at, EXCLUDED.expires_at)
+	`, userID, before, expiresAt)
+	return err
+}
+
+// IsRevoked сравнивает iat с отсечкой строго: iat округлён до секунды, поэтому
+// токен, выданный в ту же секунду после отсечки, тоже считается отозванным
+func (d *tokenDenylist) IsRevoked(ctx context.Context, token entity.AccessToken) (bool, error) {
+	var revoked bool
+	err := d.db.Pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)
+		    OR EXISTS (SELECT 1 FROM revoked_sessions WHERE session_id = $2)
+		    OR EXISTS (SELECT 1 FROM revoked_user_tokens WHERE user_id = $3 AND revoked_before > $4)
+	`, token.ID, token.SessionID, token.UserID, token.IssuedAt).Scan(&revoked)
+	return revoked, err
+}
+
+// PurgeExpired удаляет записи о токенах, срок которых уже истёк сам
+func (d *tokenDenylist) PurgeExpired(ctx context.Context) (int64, error) {
+	var purged int64
+	for _, query := range []string{
+		`DELETE FROM revoked_tokens WHERE expires_at < NOW()`,
+		`DELETE FROM revoked_sessions WHERE expires_at < NOW()`,
+		`DELETE FROM revoked_user_tokens WHERE expires_at < NOW()`,
+	} {
+		tag, err := d.db.Pool.Exec(ctx, query)
+		if err != nil {
+			return purged, err
+		}
+		purged += tag.RowsAffected()
+	}
+	return purged, nil
+}
@@ -0,0 +1,86 @@
+package postgres
+
+import (
+	"auth-micro/client"
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/repository"
+	"context"
+	"fmt"
+	"strings"
+)
+
+type authEventRepo struct {
+	db *client.DB
+}
+
+func NewAuthEventRepo(db *client.DB) repository.AuthEventRepository {
+	return &authEventRepo{db: db}
+}
+
+func (r *authEventRepo) Record(ctx context.Context, e *entity.AuthEvent) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO auth_events (id, user_id, actor_id, event_type, outcome, client_ip, user_agent, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, e.ID, nullString(e.UserID), nullString(e.ActorID), e.Type, e.Outcome, e.ClientIP, e.UserAgent, e.CreatedAt)
+	return err
+}
+
+// ListAuthEvents возвращает записи журнала от новых к старым
+func (r *authEventRepo) ListAuthEvents(ctx context.Context, f entity.AuthEventFilter) ([]*entity.AuthEvent, error) {
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.UserID != "" {
+		where = append(where, "user_id = "+arg(f.UserID))
+	}
+	if f.Type != "" {
+		where = append(where, "event_type = "+arg(f.Type))
+	}
+	if !f.Since.IsZero() {
+		where = append(where, "created_at >= "+arg(f.Since))
+	}
+	if !f.Until.IsZero() {
+		where = append(where, "created_at < "+arg(f.Until))
+	}
+	if f.BeforeID != "" {
+		where = append(where, fmt.Sprintf("(created_at, id) < (%s, %s)", arg(f.BeforeCreatedAt), arg(f.BeforeID)))
+	}
+
+	query := `
+		SELECT id, COALESCE(user_id, ''), COALESCE(actor_id, ''), event_type, outcome,
+		       client_ip, user_agent, created_at
+		FROM auth_events`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT " + arg(f.Limit)
+
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*entity.AuthEvent
+	for rows.Next() {
+		var e entity.AuthEvent
+		if err := rows.Scan(&e.ID, &e.UserID, &e.ActorID, &e.Type, &e.Outcome,
+			&e.ClientIP, &e.UserAgent, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, &e)
+	}
+	return events, rows.Err()
+}
+
+// nullString превращает пустую строку в NULL
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
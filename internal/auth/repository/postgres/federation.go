@@ -0,0 +1,80 @@
+package postgres
+
+import (
+	"auth-micro/client"
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/repository"
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+type federationRepo struct {
+	db *client.DB
+}
+
+func NewFederationRepo(db *client.DB) repository.FederationRepository {
+	return &federationRepo{db: db}
+}
+
+func (r *federationRepo) GetIdentity(ctx context.Context, provider, subject string) (*entity.FederatedIdentity, error) {
+	i := entity.FederatedIdentity{Provider: provider, Subject: subject}
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT user_id, email, created_at
+		FROM federated_identities WHERE provider = $1 AND subject = $2
+	`, provider, subject).Scan(&i.UserID, &i.Email, &i.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &i, nil
+}
+
+func (r *federationRepo) LinkIdentity(ctx context.Context, i *entity.FederatedIdentity) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO federated_identities (provider, subject, user_id, email, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, i.Provider, i.Subject, i.UserID, i.Email, i.CreatedAt)
+	return err
+}
+
+// SaveLoginState заодно удаляет истёкшие состояния брошенных входов
+func (r *federationRepo) SaveLoginState(ctx context.Context, s *entity.FederatedLoginState) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM federated_login_states WHERE expires_at < NOW()`); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO federated_login_states (state_hash, provider, nonce, code_verifier, binding_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, hashToken(s.State), s.Provider, s.Nonce, s.CodeVerifier, s.BindingHash, s.ExpiresAt, s.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *federationRepo) ConsumeLoginState(ctx context.Context, state string) (*entity.FederatedLoginState, error) {
+	s := entity.FederatedLoginState{State: state}
+	err := r.db.Pool.QueryRow(ctx, `
+		DELETE FROM federated_login_states
+		WHERE state_hash = $1 AND expires_at > NOW()
+		RETURNING provider, nonce, code_verifier, binding_hash, expires_at, created_at
+	`, hashToken(state)).Scan(&s.Provider, &s.Nonce, &s.CodeVerifier, &s.BindingHash, &s.ExpiresAt, &s.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &s, nil
+}
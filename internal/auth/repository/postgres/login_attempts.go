@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"auth-micro/client"
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/repository"
+	"context"
+	"time"
+)
+
+type loginAttemptRepo struct {
+	db *client.DB
+}
+
+func NewLoginAttemptRepo(db *client.DB) repository.LoginAttemptRepository {
+	return &loginAttemptRepo{db: db}
+}
+
+func (r *loginAttemptRepo) LockedUntil(ctx context.Context, keys ...string) (time.Time, error) {
+	var lockedUntil *time.Time
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT MAX(locked_until) FROM login_attempts
+		WHERE attempt_key = ANY($1) AND locked_until > NOW()
+	`, keys).Scan(&lockedUntil)
+	if err != nil || lockedUntil == nil {
+		return time.Time{}, err
+	}
+	return *lockedUntil, nil
+}
+
+func (r *loginAttemptRepo) RegisterFailure(ctx context.Context, key string, window, resetAfter time.Duration) (*entity.LoginAttempts, error) {
+	a := entity.LoginAttempts{Key: key}
+	var lockedUntil *time.Time
+	err := r.db.Pool.QueryRow(ctx, `
+		INSERT INTO login_attempts (attempt_key, failures, last_failure_at)
+		VALUES ($1, 1, NOW())
+		ON CONFLICT (attempt_key) DO UPDATE SET
+			failures = CASE
+				WHEN login_attempts.last_failure_at < NOW() - $2::interval THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			lockouts = CASE
+				WHEN login_attempts.last_failure_at < NOW() - $3::interval THEN 0
+				ELSE login_attempts.lockouts
+			END,
+			last_failure_at = NOW()
+		RETURNING failures, lockouts, locked_until
+	`, key, window, resetAfter).Scan(&a.Failures, &a.Lockouts, &lockedUntil)
+	if err != nil {
+		return nil, err
+	}
+	if lockedUntil != nil {
+		a.LockedUntil = *lockedUntil
+	}
+	return &a, nil
+}
+
+func (r *loginAttemptRepo) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE login_attempts
+		SET failures = 0, lockouts = lockouts + 1, locked_until = $2
+		WHERE attempt_key = $1
+	`, key, until)
+	return err
+}
+
+func (r *loginAttemptRepo) Reset(ctx context.Context, keys ...string) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		DELETE FROM login_attempts WHERE attempt_key = ANY($1)
+	`, keys)
+	return err
+}
+
+func (r *loginAttemptRepo) DeleteStale(ctx context.Context, olderThan time.Duration) (int64, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		DELETE FROM login_attempts
+		WHERE last_failure_at < NOW() - make_interval(secs => $1)
+		  AND (locked_until IS NULL OR locked_until < NOW())
+	`, olderThan.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
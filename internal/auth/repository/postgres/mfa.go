@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"auth-micro/client"
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/repository"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+type mfaRepo struct {
+	db *client.DB
+}
+
+func NewMFARepo(db *client.DB) repository.MFARepository {
+	return &mfaRepo{db: db}
+}
+
+func (r *mfaRepo) SaveTOTP(ctx context.Context, userID string, secretEncrypted []byte) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO totp_factors (user_id, secret_encrypted, created_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			secret_encrypted = EXCLUDED.secret_encrypted,
+			confirmed_at = NULL,
+			last_used_step = NULL,
+			created_at = EXCLUDED.created_at
+	`, userID, secretEncrypted)
+	return err
+}
+
+func (r *mfaRepo) GetTOTP(ctx context.Context, userID string) (*entity.TOTPFactor, error) {
+	f := entity.TOTPFactor{UserID: userID}
+	var confirmedAt *time.Time
+	var lastUsedStep *int64
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT secret_encrypted, confirmed_at, last_used_step, created_at
+		FROM totp_factors WHERE user_id = $1
+	`, userID).Scan(&f.SecretEncrypted, &confirmedAt, &lastUsedStep, &f.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if confirmedAt != nil {
+		f.ConfirmedAt = *confirmedAt
+	}
+	if lastUsedStep != nil {
+		f.LastUsedStep = *lastUsedStep
+	}
+	return &f, nil
+}
+
+func (r *mfaRepo) ConfirmTOTP(ctx context.Context, userID string, step int64, recoveryCodes []string) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE totp_factors SET confirmed_at = NOW(), last_used_step = $2
+		WHERE user_id = $1
+	`, userID, step)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, code := range recoveryCodes {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO recovery_codes (id, user_id, code_hash)
+			VALUES ($1, $2, $3)
+		`, uuid.NewString(), userID, hashToken(code))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *mfaRepo) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE totp_factors SET last_used_step = $2
+		WHERE user_id = $1 AND (last_used_step IS NULL OR last_used_step < $2)
+	`, userID, step)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *mfaRepo) DeleteTOTP(ctx context.Context, userID string) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM totp_factors WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *mfaRepo) UseRecoveryCode(ctx context.Context, userID, code string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, hashToken(code))
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
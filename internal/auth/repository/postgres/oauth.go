@@ -0,0 +1,152 @@
+package postgres
+
+import (
+	"auth-micro/client"
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/repository"
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+type oauthRepo struct {
+	db *client.DB
+}
+
+func NewOAuthRepo(db *client.DB) repository.OAuthRepository {
+	return &oauthRepo{db: db}
+}
+
+const oauthClientColumns = `id, name, COALESCE(secret_hash, ''), redirect_uris, scopes, grant_types, first_party,
+	post_logout_redirect_uris, created_at`
+
+func scanOAuthClient(row pgx.Row) (*entity.OAuthClient, error) {
+	var c entity.OAuthClient
+	err := row.Scan(&c.ID, &c.Name, &c.SecretHash, &c.RedirectURIs, &c.Scopes, &c.GrantTypes, &c.FirstParty, &c.PostLogoutRedirectURIs, &c.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *oauthRepo) CreateClient(ctx context.Context, c *entity.OAuthClient) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO oauth_clients (id, name, secret_hash, redirect_uris, scopes, grant_types, first_party,
+		                           post_logout_redirect_uris, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`, c.ID, c.Name, nullString(c.SecretHash), emptyIfNil(c.RedirectURIs), emptyIfNil(c.Scopes), emptyIfNil(c.GrantTypes),
+		c.FirstParty, emptyIfNil(c.PostLogoutRedirectURIs), c.CreatedAt)
+	return err
+}
+
+func (r *oauthRepo) GetClient(ctx context.Context, id string) (*entity.OAuthClient, error) {
+	return scanOAuthClient(r.db.Pool.QueryRow(ctx, `
+		SELECT `+oauthClientColumns+`
+		FROM oauth_clients WHERE id = $1
+	`, id))
+}
+
+func (r *oauthRepo) ListClients(ctx context.Context) ([]*entity.OAuthClient, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT `+oauthClientColumns+`
+		FROM oauth_clients
+		ORDER BY created_at, id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clients []*entity.OAuthClient
+	for rows.Next() {
+		c, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, c)
+	}
+	return clients, rows.Err()
+}
+
+func (r *oauthRepo) DeleteClient(ctx context.Context, id string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `DELETE FROM oauth_clients WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// SaveAuthorizationCode заодно удаляет истёкшие коды, которые так и не обменяли
+func (r *oauthRepo) SaveAuthorizationCode(ctx context.Context, c *entity.OAuthAuthorizationCode) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM oauth_authorization_codes WHERE expires_at < NOW()`); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO oauth_authorization_codes (code_hash, client_id, user_id, redirect_uri, scopes,
+		                                       code_challenge, code_challenge_method, nonce, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, hashToken(c.Code), c.ClientID, c.UserID, c.RedirectURI, emptyIfNil(c.Scopes),
+		c.CodeChallenge, c.CodeChallengeMethod, c.Nonce, c.ExpiresAt, c.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *oauthRepo) ConsumeAuthorizationCode(ctx context.Context, code string) (*entity.OAuthAuthorizationCode, error) {
+	c := entity.OAuthAuthorizationCode{Code: code}
+	err := r.db.Pool.QueryRow(ctx, `
+		DELETE FROM oauth_authorization_codes
+		WHERE code_hash = $1 AND expires_at > NOW()
+		RETURNING client_id, user_id, redirect_uri, scopes, code_challenge, code_challenge_method, nonce, expires_at, created_at
+	`, hashToken(code)).Scan(&c.ClientID, &c.UserID, &c.RedirectURI, &c.Scopes,
+		&c.CodeChallenge, &c.CodeChallengeMethod, &c.Nonce, &c.ExpiresAt, &c.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *oauthRepo) ConsentedScopes(ctx context.Context, userID, clientID string) ([]string, error) {
+	var scopes []string
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT scopes FROM oauth_consents WHERE user_id = $1 AND client_id = $2
+	`, userID, clientID).Scan(&scopes)
+	if err != nil && err != pgx.ErrNoRows {
+		return nil, err
+	}
+	return scopes, nil
+}
+
+func (r *oauthRepo) SaveConsent(ctx context.Context, userID, clientID string, scopes []string) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO oauth_consents (user_id, client_id, scopes)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, client_id) DO UPDATE
+		SET scopes = ARRAY(SELECT DISTINCT unnest(oauth_consents.scopes || EXCLUDED.scopes) ORDER BY 1),
+		    granted_at = NOW()
+	`, userID, clientID, emptyIfNil(scopes))
+	return err
+}
+
+// emptyIfNil — NOT NULL-колонки-массивы не принимают NULL, в который pgx превращает nil-срез
+func emptyIfNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
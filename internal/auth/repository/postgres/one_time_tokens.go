@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"auth-micro/client"
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/repository"
+	"context"
+
+	"github.com/jackc/pgx/v4"
+)
+
+type oneTimeTokenRepo struct {
+	db *client.DB
+}
+
+func NewOneTimeTokenRepo(db *client.DB) repository.OneTimeTokenRepository {
+	return &oneTimeTokenRepo{db: db}
+}
+
+// Save сохраняет токен и гасит прежние неиспользованные токены пользователя
+// с тем же назначением — действительна только последняя ссылка
+func (r *oneTimeTokenRepo) Save(ctx context.Context, t *entity.OneTimeToken) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE one_time_tokens SET used_at = NOW()
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+	`, t.UserID, t.Purpose)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO one_time_tokens (id, user_id, purpose, token_hash, email, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, t.ID, t.UserID, t.Purpose, hashToken(t.Token), t.Email, t.ExpiresAt, t.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *oneTimeTokenRepo) Consume(ctx context.Context, purpose, token string) (*entity.OneTimeToken, error) {
+	t := entity.OneTimeToken{Purpose: purpose, Token: token}
+	err := r.db.Pool.QueryRow(ctx, `
+		UPDATE one_time_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING id, user_id, email, expires_at, created_at
+	`, hashToken(token), purpose).Scan(&t.ID, &t.UserID, &t.Email, &t.ExpiresAt, &t.CreatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (r *oneTimeTokenRepo) DeleteExpired(ctx context.Context) (int64, error) {
+	tag, err := r.db.Pool.Exec(ctx, `DELETE FROM one_time_tokens WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
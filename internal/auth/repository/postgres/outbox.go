@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"auth-micro/client"
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/repository"
+	"context"
+	"sort"
+	"time"
+)
+
+type outboxRepo struct {
+	db *client.DB
+}
+
+func NewOutboxRepo(db *client.DB) repository.OutboxRepository {
+	return &outboxRepo{db: db}
+}
+
+// insertOutboxEvent пишет событие в транзакции, изменившей пользователя
+func insertOutboxEvent(ctx context.Context, q execer, e *entity.OutboxEvent) error {
+	_, err := q.Exec(ctx, `
+		INSERT INTO outbox (id, user_id, event_type, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, e.ID, e.UserID, e.Type, e.Payload, e.CreatedAt)
+	return err
+}
+
+func (r *outboxRepo) ClaimPending(ctx context.Context, limit int, lease time.Duration) ([]*entity.OutboxEvent, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		UPDATE outbox SET locked_until = NOW() + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id FROM outbox o
+			WHERE published_at IS NULL AND (locked_until IS NULL OR locked_until < NOW())
+			  -- Только самое раннее недоставленное событие пользователя: следующие
+			  -- ждут, пока оно не будет доставлено, даже если оно занято или ждёт повтора
+			  AND NOT EXISTS (
+				SELECT 1 FROM outbox e
+				WHERE e.user_id = o.user_id AND e.published_at IS NULL
+				  AND (e.created_at, e.id) < (o.created_at, o.id)
+			  )
+			ORDER BY created_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, event_type, payload, attempts, created_at
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*entity.OutboxEvent
+	for rows.Next() {
+		var e entity.OutboxEvent
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Type, &e.Payload, &e.Attempts, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING не сохраняет порядок подзапроса
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].CreatedAt.Before(events[j].CreatedAt)
+	})
+	return events, nil
+}
+
+func (r *outboxRepo) MarkPublished(ctx context.Context, id string) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE outbox SET published_at = NOW(), locked_until = NULL WHERE id = $1
+	`, id)
+	return err
+}
+
+func (r *outboxRepo) MarkFailed(ctx context.Context, id, reason string, retryAfter time.Duration) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE outbox
+		SET attempts = attempts + 1,
+		    last_error = $2,
+		    locked_until = NOW() + make_interval(secs => $3)
+		WHERE id = $1
+	`, id, reason, retryAfter.Seconds())
+	return err
+}
+
+func (r *outboxRepo) DeletePublished(ctx context.Context, olderThan time.Duration) (int64, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		DELETE FROM outbox WHERE published_at < NOW() - make_interval(secs => $1)
+	`, olderThan.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
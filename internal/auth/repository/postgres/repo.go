@@ -3,12 +3,54 @@ package postgres
 import (
 	"auth-micro/client"
 	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/outbox"
 	"auth-micro/internal/auth/repository"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
 
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 )
 
+// uniqueViolation — код ошибки Postgres при нарушении UNIQUE
+const uniqueViolation = "23505"
+
+// execer — общий интерфейс пула и транзакции, чтобы запросы можно было
+// выполнять как отдельно, так и внутри транзакции
+type execer interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+}
+
+// userColumns — колонки users в порядке, который ожидает scanUser;
+// роли пользователя собираются подзапросом из user_roles
+const userColumns = `id, username, name, email, age, bio, password,
+	ARRAY(SELECT role FROM user_roles WHERE user_id = users.id ORDER BY role),
+	email_verified_at, disabled_at, created_at, updated_at`
+
+// scanUser читает пользователя из строки результата; если строки нет — nil, nil
+func scanUser(row pgx.Row) (*entity.User, error) {
+	var u entity.User
+	var emailVerifiedAt, disabledAt *time.Time
+	err := row.Scan(&u.ID, &u.Username, &u.Name, &u.Email, &u.Age, &u.Bio, &u.Password, &u.Roles,
+		&emailVerifiedAt, &disabledAt, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if emailVerifiedAt != nil {
+		u.EmailVerifiedAt = *emailVerifiedAt
+	}
+	if disabledAt != nil {
+		u.DisabledAt = *disabledAt
+	}
+	return &u, nil
+}
+
 type userRepo struct {
 	db *client.DB
 }
@@ -18,54 +60,71 @@ func NewUserRepo(db *client.DB) repository.UserRepository {
 }
 
 func (r *userRepo) Create(ctx context.Context, u *entity.User) error {
-	_, err := r.db.Pool.Exec(ctx, `
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
 		INSERT INTO users (id, username, name, email, age, bio, password, created_at, updated_at)
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 	`, u.ID, u.Username, u.Name, u.Email, u.Age, u.Bio, u.Password, u.CreatedAt, u.UpdatedAt)
-	return err
+	if err != nil {
+		return err
+	}
+
+	for _, role := range u.Roles {
+		if err := assignRole(ctx, tx, u.ID, role); err != nil {
+			return err
+		}
+	}
+
+	event, err := outbox.UserRegistered(u)
+	if err != nil {
+		return err
+	}
+	if err := insertOutboxEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
 func (r *userRepo) GetByUsername(ctx context.Context, username string) (*entity.User, error) {
-	row := r.db.Pool.QueryRow(ctx, `
-		SELECT id, username, name, email, age, bio, password, created_at, updated_at
+	return scanUser(r.db.Pool.QueryRow(ctx, `
+		SELECT `+userColumns+`
 		FROM users WHERE username = $1
-	`, username)
-
-	var u entity.User
-	if err := row.Scan(&u.ID, &u.Username, &u.Name, &u.Email, &u.Age, &u.Bio, &u.Password, &u.CreatedAt, &u.UpdatedAt); err != nil {
-		return nil, err
-	}
-	return &u, nil
+	`, username))
 }
 
+// GetByEmail сравнивает адреса без учёта регистра. Если старые записи
+// различаются только регистром, первым идёт пользователь с подтверждённым email
 func (r *userRepo) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
-	row := r.db.Pool.QueryRow(ctx, `
-		SELECT id, username, name, email, age, bio, password, created_at, updated_at
-		FROM users WHERE email = $1
-	`, email)
-
-	var u entity.User
-	if err := row.Scan(&u.ID, &u.Username, &u.Name, &u.Email, &u.Age, &u.Bio, &u.Password, &u.CreatedAt, &u.UpdatedAt); err != nil {
-		return nil, err
-	}
-	return &u, nil
+	return scanUser(r.db.Pool.QueryRow(ctx, `
+		SELECT `+userColumns+`
+		FROM users WHERE LOWER(email) = LOWER($1)
+		ORDER BY email_verified_at IS NULL, created_at
+		LIMIT 1
+	`, email))
 }
 
 func (r *userRepo) SaveRefreshToken(ctx context.Context, rt *entity.RefreshToken) error {
-	_, err := r.db.Pool.Exec(ctx, `
-        INSERT INTO refresh_tokens (id, user_id, token, expires_at, created_at, revoked)
-        VALUES ($1, $2, $3, $4, $5, $6)
-    `, rt.ID, rt.UserID, rt.Token, rt.ExpiresAt, rt.CreatedAt, rt.Revoked)
-	return err
+	return insertRefreshToken(ctx, r.db.Pool, rt)
 }
 
+// GetRefreshToken возвращает токен в том числе отозванный — сервису это нужно,
+// чтобы распознать повторное использование уже ротированного токена
 func (r *userRepo) GetRefreshToken(ctx context.Context, token string) (*entity.RefreshToken, error) {
 	var rt entity.RefreshToken
+	var parentID *string
 	err := r.db.Pool.QueryRow(ctx, `
-        SELECT id, user_id, token, expires_at, created_at, revoked
+        SELECT id, user_id, family_id, parent_id, client_ip, user_agent, device_label,
+               COALESCE(client_id, ''), scopes, last_used_at, expires_at, created_at, revoked
         FROM refresh_tokens
-        WHERE token = $1 AND revoked = false
-    `, token).Scan(&rt.ID, &rt.UserID, &rt.Token, &rt.ExpiresAt, &rt.CreatedAt, &rt.Revoked)
+        WHERE token_hash = $1
+    `, hashToken(token)).Scan(&rt.ID, &rt.UserID, &rt.FamilyID, &parentID, &rt.ClientIP, &rt.UserAgent, &rt.DeviceLabel,
+		&rt.ClientID, &rt.Scopes, &rt.LastUsedAt, &rt.ExpiresAt, &rt.CreatedAt, &rt.Revoked)
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
@@ -73,23 +132,137 @@ func (r *userRepo) GetRefreshToken(ctx context.Context, token string) (*entity.R
 		}
 		return nil, err
 	}
+	if parentID != nil {
+		rt.ParentID = *parentID
+	}
+	rt.Token = token
 	return &rt, nil
 }
 
+// RotateRefreshToken атомарно отзывает старый токен и сохраняет новый.
+// Если старый уже отозван (например, параллельный запрос успел раньше),
+// возвращает repository.ErrRefreshTokenRevoked
+func (r *userRepo) RotateRefreshToken(ctx context.Context, oldID string, newToken *entity.RefreshToken) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+        UPDATE refresh_tokens SET revoked = true WHERE id = $1 AND revoked = false
+    `, oldID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return repository.ErrRefreshTokenRevoked
+	}
+
+	if err := insertRefreshToken(ctx, tx, newToken); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
 func (r *userRepo) RevokeRefreshToken(ctx context.Context, token string) error {
 	_, err := r.db.Pool.Exec(ctx, `
-        UPDATE refresh_tokens SET revoked = true WHERE token = $1
-    `, token)
+        UPDATE refresh_tokens SET revoked = true WHERE token_hash = $1
+    `, hashToken(token))
 	return err
 }
 
-func (r *userRepo) RevokeUserRefreshTokens(ctx context.Context, userID string) error {
+func (r *userRepo) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
 	_, err := r.db.Pool.Exec(ctx, `
-        UPDATE refresh_tokens SET revoked = true WHERE user_id = $1
-    `, userID)
+        UPDATE refresh_tokens SET revoked = true WHERE family_id = $1
+    `, familyID)
 	return err
 }
 
+func (r *userRepo) RevokeUserRefreshTokens(ctx context.Context, userID string) ([]string, error) {
+	return r.revokeFamilies(ctx, `
+        WITH revoked AS (
+            UPDATE refresh_tokens SET revoked = true
+            WHERE user_id = $1 AND revoked = false
+            RETURNING family_id
+        )
+        SELECT DISTINCT family_id FROM revoked
+    `, userID)
+}
+
+// RevokeOtherUserRefreshTokens отзывает все сессии пользователя, кроме keepFamilyID
+func (r *userRepo) RevokeOtherUserRefreshTokens(ctx context.Context, userID, keepFamilyID string) ([]string, error) {
+	return r.revokeFamilies(ctx, `
+        WITH revoked AS (
+            UPDATE refresh_tokens SET revoked = true
+            WHERE user_id = $1 AND family_id <> $2 AND revoked = false
+            RETURNING family_id
+        )
+        SELECT DISTINCT family_id FROM revoked
+    `, userID, keepFamilyID)
+}
+
+// revokeFamilies выполняет отзыв и возвращает ID затронутых семейств
+func (r *userRepo) revokeFamilies(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var families []string
+	for rows.Next() {
+		var familyID string
+		if err := rows.Scan(&familyID); err != nil {
+			return nil, err
+		}
+		families = append(families, familyID)
+	}
+	return families, rows.Err()
+}
+
+// RevokeUserRefreshTokenFamily отзывает одну сессию пользователя.
+// Возвращает false, если активной сессии с таким ID у пользователя нет
+func (r *userRepo) RevokeUserRefreshTokenFamily(ctx context.Context, userID, familyID string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+        UPDATE refresh_tokens SET revoked = true
+        WHERE user_id = $1 AND family_id = $2 AND revoked = false
+    `, userID, familyID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ListSessions возвращает активные сессии: по одному неотозванному и
+// неистёкшему токену на семейство, время логина берётся у корня семейства
+func (r *userRepo) ListSessions(ctx context.Context, userID string) ([]*entity.Session, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+        SELECT rt.family_id, rt.user_id, rt.client_ip, rt.user_agent, rt.device_label,
+               COALESCE(root.created_at, rt.created_at), rt.last_used_at, rt.expires_at
+        FROM refresh_tokens rt
+        LEFT JOIN refresh_tokens root ON root.id = rt.family_id
+        WHERE rt.user_id = $1 AND rt.revoked = false AND rt.expires_at > NOW()
+        ORDER BY rt.last_used_at DESC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*entity.Session
+	for rows.Next() {
+		var s entity.Session
+		if err := rows.Scan(&s.ID, &s.UserID, &s.ClientIP, &s.UserAgent, &s.DeviceLabel,
+			&s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, &s)
+	}
+	return sessions, rows.Err()
+}
+
 func (r *userRepo) GetUser(ctx context.Context, username string) (*entity.User, error) {
 	row := r.db.Pool.QueryRow(ctx, `
 		SELECT id, username, name, email, age, bio, password, created_at, updated_at
@@ -105,19 +278,10 @@ func (r *userRepo) GetUser(ctx context.Context, username string) (*entity.User,
 
 // GetByID получает пользователя по ID
 func (r *userRepo) GetByID(ctx context.Context, id string) (*entity.User, error) {
-	row := r.db.Pool.QueryRow(ctx, `
-		SELECT id, username, name, email, age, bio, password, created_at, updated_at
+	return scanUser(r.db.Pool.QueryRow(ctx, `
+		SELECT `+userColumns+`
 		FROM users WHERE id = $1
-	`, id)
-
-	var u entity.User
-	if err := row.Scan(&u.ID, &u.Username, &u.Name, &u.Email, &u.Age, &u.Bio, &u.Password, &u.CreatedAt, &u.UpdatedAt); err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, nil
-		}
-		return nil, err
-	}
-	return &u, nil
+	`, id))
 }
 
 // UpdatePassword обновляет только пароль пользователя
@@ -129,3 +293,97 @@ func (r *userRepo) UpdatePassword(ctx context.Context, userID, hashedPassword st
 	`, hashedPassword, userID)
 	return err
 }
+
+func insertRefreshToken(ctx context.Context, q execer, rt *entity.RefreshToken) error {
+	var parentID *string
+	if rt.ParentID != "" {
+		parentID = &rt.ParentID
+	}
+	_, err := q.Exec(ctx, `
+        INSERT INTO refresh_tokens (id, user_id, token_hash, family_id, parent_id, client_ip, user_agent, device_label,
+                                    client_id, scopes, last_used_at, expires_at, created_at, revoked)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+    `, rt.ID, rt.UserID, hashToken(rt.Token), rt.FamilyID, parentID, rt.ClientIP, rt.UserAgent, rt.DeviceLabel,
+		nullString(rt.ClientID), emptyIfNil(rt.Scopes), rt.LastUsedAt, rt.ExpiresAt, rt.CreatedAt, rt.Revoked)
+	return err
+}
+
+// UpdateProfile обновляет только переданные поля профиля и возвращает пользователя
+func (r *userRepo) UpdateProfile(ctx context.Context, userID string, upd entity.UserProfileUpdate) (*entity.User, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var oldEmail string
+	err = tx.QueryRow(ctx, `SELECT email FROM users WHERE id = $1 FOR UPDATE`, userID).Scan(&oldEmail)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	// Смена email сбрасывает его подтверждение
+	u, err := scanUser(tx.QueryRow(ctx, `
+		UPDATE users
+		SET name = COALESCE($2, name),
+		    email = COALESCE($3, email),
+		    age = COALESCE($4, age),
+		    bio = COALESCE($5, bio),
+		    email_verified_at = CASE
+		        WHEN $3::varchar IS NOT NULL AND $3::varchar <> email THEN NULL
+		        ELSE email_verified_at
+		    END
+		WHERE id = $1
+		RETURNING `+userColumns, userID, upd.Name, upd.Email, upd.Age, upd.Bio))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return nil, repository.ErrEmailTaken
+		}
+		return nil, err
+	}
+
+	if u.Email != oldEmail {
+		event, err := outbox.UserEmailChanged(u.ID, oldEmail, u.Email)
+		if err != nil {
+			return nil, err
+		}
+		if err := insertOutboxEvent(ctx, tx, event); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// hashToken — в БД хранится только SHA-256 от refresh-токена, поиск идёт по хэшу
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MarkEmailVerified подтверждает email, если он не менялся после отправки письма
+func (r *userRepo) MarkEmailVerified(ctx context.Context, userID, email string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		UPDATE users SET email_verified_at = NOW()
+		WHERE id = $1 AND email = $2
+	`, userID, email)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *userRepo) DeleteExpiredRefreshTokens(ctx context.Context) (int64, error) {
+	tag, err := r.db.Pool.Exec(ctx, `DELETE FROM refresh_tokens WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"auth-micro/client"
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/repository"
+	"context"
+	"errors"
+
+	"github.com/jackc/pgconn"
+)
+
+// foreignKeyViolation — код ошибки Postgres при нарушении внешнего ключа
+const foreignKeyViolation = "23503"
+
+type roleRepo struct {
+	db *client.DB
+}
+
+func NewRoleRepo(db *client.DB) repository.RoleRepository {
+	return &roleRepo{db: db}
+}
+
+func (r *roleRepo) ListRoles(ctx context.Context) ([]*entity.Role, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT r.name, r.description, r.created_at,
+		       ARRAY(SELECT permission FROM role_permissions WHERE role = r.name ORDER BY permission)
+		FROM roles r
+		ORDER BY r.name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []*entity.Role
+	for rows.Next() {
+		var role entity.Role
+		if err := rows.Scan(&role.Name, &role.Description, &role.CreatedAt, &role.Permissions); err != nil {
+			return nil, err
+		}
+		roles = append(roles, &role)
+	}
+	return roles, rows.Err()
+}
+
+func (r *roleRepo) CreateRole(ctx context.Context, role *entity.Role) error {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO roles (name, description, created_at)
+		VALUES ($1, $2, $3)
+	`, role.Name, role.Description, role.CreatedAt)
+	if err != nil {
+		return roleError(err)
+	}
+
+	for _, permission := range role.Permissions {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO role_permissions (role, permission)
+			VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, role.Name, permission)
+		if err != nil {
+			return roleError(err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *roleRepo) AssignRole(ctx context.Context, userID, role string) error {
+	return roleError(assignRole(ctx, r.db.Pool, userID, role))
+}
+
+func (r *roleRepo) RevokeRole(ctx context.Context, userID, role string) (bool, error) {
+	tag, err := r.db.Pool.Exec(ctx, `
+		DELETE FROM user_roles WHERE user_id = $1 AND role = $2
+	`, userID, role)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *roleRepo) UserPermissions(ctx context.Context, userID string) ([]string, error) {
+	var permissions []string
+	err := r.db.Pool.QueryRow(ctx, `
+		SELECT ARRAY(
+			SELECT DISTINCT rp.permission
+			FROM user_roles ur
+			JOIN role_permissions rp ON rp.role = ur.role
+			WHERE ur.user_id = $1
+			ORDER BY rp.permission
+		)
+	`, userID).Scan(&permissions)
+	return permissions, err
+}
+
+func assignRole(ctx context.Context, q execer, userID, role string) error {
+	_, err := q.Exec(ctx, `
+		INSERT INTO user_roles (user_id, role)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, userID, role)
+	return err
+}
+
+// roleError переводит нарушения ограничений в ошибки репозитория
+func roleError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case uniqueViolation:
+			return repository.ErrRoleExists
+		case foreignKeyViolation:
+			return repository.ErrRoleNotFound
+		}
+	}
+	return err
+}
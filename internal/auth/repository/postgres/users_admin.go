@@ -0,0 +1,140 @@
+package postgres
+
+import (
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/outbox"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// ListUsers возвращает страницу пользователей по фильтру (keyset-пагинация по created_at, id)
+func (r *userRepo) ListUsers(ctx context.Context, f entity.UserFilter) ([]*entity.User, error) {
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if f.UsernamePrefix != "" {
+		where = append(where, "username LIKE "+arg(likePrefix(f.UsernamePrefix)))
+	}
+	if f.EmailPrefix != "" {
+		where = append(where, "email LIKE "+arg(likePrefix(f.EmailPrefix)))
+	}
+	if !f.CreatedAfter.IsZero() {
+		where = append(where, "created_at >= "+arg(f.CreatedAfter))
+	}
+	if !f.CreatedBefore.IsZero() {
+		where = append(where, "created_at < "+arg(f.CreatedBefore))
+	}
+	if f.AfterID != "" {
+		where = append(where, fmt.Sprintf("(created_at, id) > (%s, %s)", arg(f.AfterCreatedAt), arg(f.AfterID)))
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY created_at, id LIMIT " + arg(f.Limit)
+
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*entity.User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// SetDisabled отключает или включает пользователя; false — пользователя нет
+func (r *userRepo) SetDisabled(ctx context.Context, userID string, disabled bool) (bool, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var wasDisabled bool
+	err = tx.QueryRow(ctx, `
+		SELECT disabled_at IS NOT NULL FROM users WHERE id = $1 FOR UPDATE
+	`, userID).Scan(&wasDisabled)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	// Состояние не меняется — событие не нужно
+	if wasDisabled == disabled {
+		return true, nil
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users
+		SET disabled_at = CASE WHEN $2 THEN NOW() END,
+		    updated_at = NOW()
+		WHERE id = $1
+	`, userID, disabled)
+	if err != nil {
+		return false, err
+	}
+
+	var event *entity.OutboxEvent
+	if disabled {
+		event, err = outbox.UserDisabled(userID)
+	} else {
+		event, err = outbox.UserEnabled(userID)
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := insertOutboxEvent(ctx, tx, event); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// DeleteUser удаляет пользователя; токены, роли и факторы удаляются каскадно
+func (r *userRepo) DeleteUser(ctx context.Context, userID string) (bool, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		return false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	event, err := outbox.UserDeleted(userID)
+	if err != nil {
+		return false, err
+	}
+	if err := insertOutboxEvent(ctx, tx, event); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit(ctx)
+}
+
+// likePrefix экранирует спецсимволы LIKE и добавляет % в конец
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+}
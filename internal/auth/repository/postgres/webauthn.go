@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"auth-micro/client"
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/repository"
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+type webAuthnRepo struct {
+	db *client.DB
+}
+
+func NewWebAuthnRepo(db *client.DB) repository.WebAuthnRepository {
+	return &webAuthnRepo{db: db}
+}
+
+func (r *webAuthnRepo) SaveCredential(ctx context.Context, c *entity.WebAuthnCredential) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO webauthn_credentials (id, user_id, credential_id, public_key, attestation_type, transports,
+		                                  aaguid, sign_count, backup_eligible, backup_state, name, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`, c.ID, c.UserID, c.CredentialID, c.PublicKey, c.AttestationType, c.Transports,
+		c.AAGUID, int64(c.SignCount), c.BackupEligible, c.BackupState, c.Name, c.CreatedAt)
+	return err
+}
+
+func (r *webAuthnRepo) ListCredentials(ctx context.Context, userID string) ([]*entity.WebAuthnCredential, error) {
+	rows, err := r.db.Pool.Query(ctx, `
+		SELECT id, user_id, credential_id, public_key, attestation_type, transports,
+		       aaguid, sign_count, backup_eligible, backup_state, name, created_at, last_used_at
+		FROM webauthn_credentials
+		WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var creds []*entity.WebAuthnCredential
+	for rows.Next() {
+		var c entity.WebAuthnCredential
+		var signCount int64
+		var lastUsedAt *time.Time
+		err := rows.Scan(&c.ID, &c.UserID, &c.CredentialID, &c.PublicKey, &c.AttestationType, &c.Transports,
+			&c.AAGUID, &signCount, &c.BackupEligible, &c.BackupState, &c.Name, &c.CreatedAt, &lastUsedAt)
+		if err != nil {
+			return nil, err
+		}
+		c.SignCount = uint32(signCount)
+		if lastUsedAt != nil {
+			c.LastUsedAt = *lastUsedAt
+		}
+		creds = append(creds, &c)
+	}
+	return creds, rows.Err()
+}
+
+func (r *webAuthnRepo) UpdateCredentialUsage(ctx context.Context, credentialID []byte, signCount uint32, backupState bool) error {
+	_, err := r.db.Pool.Exec(ctx, `
+		UPDATE webauthn_credentials
+		SET sign_count = $2, backup_state = $3, last_used_at = NOW()
+		WHERE credential_id = $1
+	`, credentialID, int64(signCount), backupState)
+	return err
+}
+
+// SaveSession заодно удаляет брошенные церемонии
+func (r *webAuthnRepo) SaveSession(ctx context.Context, s *entity.WebAuthnSession) error {
+	if _, err := r.db.Pool.Exec(ctx, `DELETE FROM webauthn_sessions WHERE expires_at < NOW()`); err != nil {
+		return err
+	}
+
+	var userID *string
+	if s.UserID != "" {
+		userID = &s.UserID
+	}
+	_, err := r.db.Pool.Exec(ctx, `
+		INSERT INTO webauthn_sessions (id, user_id, purpose, data, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, s.ID, userID, s.Purpose, s.Data, s.ExpiresAt)
+	return err
+}
+
+func (r *webAuthnRepo) ConsumeSession(ctx context.Context, id, purpose string) (*entity.WebAuthnSession, error) {
+	s := entity.WebAuthnSession{ID: id, Purpose: purpose}
+	var userID *string
+	err := r.db.Pool.QueryRow(ctx, `
+		DELETE FROM webauthn_sessions
+		WHERE id = $1 AND purpose = $2
+		RETURNING user_id, data, expires_at
+	`, id, purpose).Scan(&userID, &s.Data, &s.ExpiresAt)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !s.ExpiresAt.After(time.Now()) {
+		return nil, nil
+	}
+	if userID != nil {
+		s.UserID = *userID
+	}
+	return &s, nil
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/utils"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// ListUsers возвращает страницу пользователей. Пустой nextPageToken — страниц больше нет
+func (s *userService) ListUsers(ctx context.Context, input ListUsersInput) ([]*entity.User, string, error) {
+	if _, err := requirePermission(ctx, entity.PermissionUsersRead); err != nil {
+		return nil, "", err
+	}
+
+	pageSize, err := normalizePageSize(input.PageSize)
+	if err != nil {
+		return nil, "", err
+	}
+
+	filter := entity.UserFilter{
+		UsernamePrefix: input.UsernamePrefix,
+		EmailPrefix:    input.EmailPrefix,
+		CreatedAfter:   input.CreatedAfter,
+		CreatedBefore:  input.CreatedBefore,
+		// Лишняя запись показывает, есть ли следующая страница
+		Limit: pageSize + 1,
+	}
+	if input.PageToken != "" {
+		createdAt, id, err := decodePageToken(input.PageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		filter.AfterCreatedAt, filter.AfterID = createdAt, id
+	}
+
+	users, err := s.repo.ListUsers(ctx, filter)
+	if err != nil {
+		return nil, "", fmt.Errorf("database error: %w", err)
+	}
+
+	var next string
+	if len(users) > pageSize {
+		users = users[:pageSize]
+		last := users[len(users)-1]
+		next = encodePageToken(last.CreatedAt, last.ID)
+	}
+	return users, next, nil
+}
+
+// DisableUser запрещает пользователю вход, завершает его сессии и отзывает
+// выданные access-токены
+func (s *userService) DisableUser(ctx context.Context, userID string) error {
+	p, err := requirePermission(ctx, entity.PermissionUsersWrite)
+	if err != nil {
+		return err
+	}
+	if p.UserID == userID {
+		return fmt.Errorf("%w: cannot disable yourself", ErrInvalidArgument)
+	}
+
+	if err := s.setDisabled(ctx, userID, true); err != nil {
+		return err
+	}
+	if err := s.endSessions(ctx, userID, ""); err != nil {
+		return err
+	}
+	if err := s.revokeUserTokens(ctx, userID); err != nil {
+		return err
+	}
+
+	s.recordEvent(ctx, userID, entity.EventUserDisabled, entity.OutcomeSuccess)
+	return nil
+}
+
+func (s *userService) EnableUser(ctx context.Context, userID string) error {
+	if _, err := requirePermission(ctx, entity.PermissionUsersWrite); err != nil {
+		return err
+	}
+
+	if err := s.setDisabled(ctx, userID, false); err != nil {
+		return err
+	}
+
+	s.recordEvent(ctx, userID, entity.EventUserEnabled, entity.OutcomeSuccess)
+	return nil
+}
+
+func (s *userService) setDisabled(ctx context.Context, userID string, disabled bool) error {
+	found, err := s.repo.SetDisabled(ctx, userID, disabled)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if !found {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// ForceLogout завершает все сессии пользователя и отзывает его access-токены
+func (s *userService) ForceLogout(ctx context.Context, userID string) error {
+	if _, err := requirePermission(ctx, entity.PermissionUsersWrite); err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	if err := s.endSessions(ctx, userID, ""); err != nil {
+		return err
+	}
+	if err := s.revokeUserTokens(ctx, userID); err != nil {
+		return err
+	}
+
+	s.recordEvent(ctx, userID, entity.EventForcedLogout, entity.OutcomeSuccess)
+	return nil
+}
+
+// ForcePasswordReset заменяет пароль случайным, завершает сессии и отправляет
+// пользователю ссылку для сброса пароля
+func (s *userService) ForcePasswordReset(ctx context.Context, userID string) error {
+	if _, err := requirePermission(ctx, entity.PermissionUsersWrite); err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	// Без email пользователь не сможет задать новый пароль
+	if user.Email == "" {
+		return ErrUserHasNoEmail
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return fmt.Errorf("failed to generate password: %w", err)
+	}
+	hashedPassword, err := utils.HashPassword(base64.RawURLEncoding.EncodeToString(secret))
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := s.repo.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if err := s.endSessions(ctx, user.ID, ""); err != nil {
+		return err
+	}
+	if err := s.revokeUserTokens(ctx, user.ID); err != nil {
+		return err
+	}
+
+	s.recordEvent(ctx, user.ID, entity.EventForcedPasswordReset, entity.OutcomeSuccess)
+	return s.sendPasswordResetEmail(ctx, user)
+}
+
+// DeleteUser удаляет пользователя вместе с его сессиями и факторами
+func (s *userService) DeleteUser(ctx context.Context, userID string) error {
+	p, err := requirePermission(ctx, entity.PermissionUsersDelete)
+	if err != nil {
+		return err
+	}
+	if p.UserID == userID {
+		return fmt.Errorf("%w: cannot delete yourself", ErrInvalidArgument)
+	}
+
+	deleted, err := s.repo.DeleteUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if !deleted {
+		return ErrUserNotFound
+	}
+	if err := s.revokeUserTokens(ctx, userID); err != nil {
+		return err
+	}
+
+	s.recordEvent(ctx, userID, entity.EventUserDeleted, entity.OutcomeSuccess)
+	return nil
+}
+
+func normalizePageSize(size int) (int, error) {
+	switch {
+	case size < 0:
+		return 0, fmt.Errorf("%w: page size cannot be negative", ErrInvalidArgument)
+	case size == 0:
+		return defaultPageSize, nil
+	case size > maxPageSize:
+		return maxPageSize, nil
+	}
+	return size, nil
+}
+
+// Токен страницы — base64 от "created_at|id" последней записи
+func encodePageToken(createdAt time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(createdAt.UTC().Format(time.RFC3339Nano) + "|" + id))
+}
+
+func decodePageToken(token string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidPageToken
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return time.Time{}, "", ErrInvalidPageToken
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidPageToken
+	}
+	return createdAt, id, nil
+}
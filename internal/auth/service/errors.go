@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"time"
+)
+
+// Ошибки сервиса, по которым хэндлер выбирает gRPC-код ответа
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+
+	ErrTokenExpired          = errors.New("token expired")
+	ErrTokenInvalidSignature = errors.New("token signature is invalid")
+	ErrTokenMalformed        = errors.New("token is malformed")
+	ErrTokenWrongType        = errors.New("wrong token type")
+	ErrTokenRevoked          = errors.New("token revoked")
+
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrAccountLocked      = errors.New("too many failed login attempts, try again later")
+	ErrEmailNotVerified   = errors.New("email is not verified")
+	ErrAccountDisabled    = errors.New("account is disabled")
+
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	ErrEmailAlreadyVerified     = errors.New("email already verified")
+	ErrInvalidResetToken        = errors.New("invalid or expired password reset token")
+	ErrUserHasNoEmail           = errors.New("user has no email")
+
+	ErrInvalidMFAToken   = errors.New("invalid or expired mfa token")
+	ErrInvalidMFACode    = errors.New("invalid mfa code")
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication already enabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+
+	ErrInvalidPasskeyCeremony = errors.New("passkey ceremony not found or expired")
+	ErrInvalidPasskey         = errors.New("passkey verification failed")
+
+	ErrUnauthenticated  = errors.New("unauthenticated")
+	ErrWrongPassword    = errors.New("current password is incorrect")
+	ErrUserNotFound     = errors.New("user not found")
+	ErrSessionNotFound  = errors.New("session not found")
+	ErrEmailTaken       = errors.New("email already taken")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrRoleExists       = errors.New("role already exists")
+	ErrRoleNotFound     = errors.New("role not found")
+	ErrInvalidArgument  = errors.New("invalid argument")
+	ErrInvalidPageToken = errors.New("invalid page token")
+
+	ErrOAuthClientNotFound = errors.New("oauth client not found")
+	// ErrSigningKeyUnchanged — ключ подписи задан переменными окружения или не
+	// изменён в файле конфигурации
+	ErrSigningKeyUnchanged = errors.New("signing key did not change; update the key configuration first")
+
+	ErrIdentityProviderNotFound = errors.New("identity provider not found")
+	ErrInvalidFederatedState    = errors.New("invalid or expired federated login state")
+	ErrFederatedLoginFailed     = errors.New("federated login failed")
+	ErrFederatedEmailConflict   = errors.New("email is already registered; sign in and verify it to link the provider")
+	// ErrFederatedEmailNotVerified — провайдер не подтвердил email, а учётная
+	// запись провайдера ещё не привязана
+	ErrFederatedEmailNotVerified = errors.New("identity provider has not verified the email")
+)
+
+// Коды ошибок OAuth 2.0 (RFC 6749, 4.1.2.1 и 5.2)
+const (
+	OAuthInvalidRequest          = "invalid_request"
+	OAuthInvalidClient           = "invalid_client"
+	OAuthInvalidGrant            = "invalid_grant"
+	OAuthUnauthorizedClient      = "unauthorized_client"
+	OAuthUnsupportedGrantType    = "unsupported_grant_type"
+	OAuthUnsupportedResponseType = "unsupported_response_type"
+	OAuthInvalidScope            = "invalid_scope"
+	OAuthAccessDenied            = "access_denied"
+	OAuthServerError             = "server_error"
+	// Ошибки доступа по bearer-токену к /userinfo (RFC 6750, 3.1)
+	OAuthInvalidToken      = "invalid_token"
+	OAuthInsufficientScope = "insufficient_scope"
+)
+
+// OAuthError — ошибка протокола OAuth; Code уходит клиенту в параметре error
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string {
+	return e.Code + ": " + e.Description
+}
+
+func oauthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// AccountLockedError — вход временно заблокирован после неудачных попыток.
+// errors.Is(err, ErrAccountLocked) == true
+type AccountLockedError struct {
+	Until time.Time
+}
+
+func (e *AccountLockedError) Error() string {
+	return ErrAccountLocked.Error()
+}
+
+func (e *AccountLockedError) Is(target error) bool {
+	return target == ErrAccountLocked
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"auth-micro/internal/auth/clientinfo"
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/principal"
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// recordEvent пишет событие в журнал безопасности. Ошибка записи
+// не должна ломать основную операцию, поэтому только логируется
+func (s *userService) recordEvent(ctx context.Context, userID, eventType, outcome string) {
+	client := clientinfo.FromContext(ctx)
+	event := &entity.AuthEvent{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Type:      eventType,
+		Outcome:   outcome,
+		ClientIP:  client.IP,
+		UserAgent: client.UserAgent,
+		CreatedAt: time.Now(),
+	}
+	if p, ok := principal.FromContext(ctx); ok {
+		event.ActorID = p.UserID
+	}
+	if err := s.events.Record(ctx, event); err != nil {
+		log.Printf("failed to record %s event for user %s: %v", eventType, userID, err)
+	}
+}
+
+// ListAuthEvents возвращает записи журнала от новых к старым
+func (s *userService) ListAuthEvents(ctx context.Context, input ListAuthEventsInput) ([]*entity.AuthEvent, string, error) {
+	if _, err := requirePermission(ctx, entity.PermissionAuditRead); err != nil {
+		return nil, "", err
+	}
+
+	pageSize, err := normalizePageSize(input.PageSize)
+	if err != nil {
+		return nil, "", err
+	}
+
+	filter := entity.AuthEventFilter{
+		UserID: input.UserID,
+		Type:   input.Type,
+		Since:  input.Since,
+		Until:  input.Until,
+		Limit:  pageSize + 1,
+	}
+	if input.PageToken != "" {
+		createdAt, id, err := decodePageToken(input.PageToken)
+		if err != nil {
+			return nil, "", err
+		}
+		// id записи журнала — UUID, иначе Postgres вернёт ошибку приведения типа
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, "", ErrInvalidPageToken
+		}
+		filter.BeforeCreatedAt, filter.BeforeID = createdAt, id
+	}
+
+	events, err := s.auditLog.ListAuthEvents(ctx, filter)
+	if err != nil {
+		return nil, "", fmt.Errorf("database error: %w", err)
+	}
+
+	var next string
+	if len(events) > pageSize {
+		events = events[:pageSize]
+		last := events[len(events)-1]
+		next = encodePageToken(last.CreatedAt, last.ID)
+	}
+	return events, next, nil
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"auth-micro/internal/auth/config"
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/repository"
+	"auth-micro/internal/auth/repository/memory"
+	"auth-micro/internal/auth/utils"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestService собирает сервис на репозиториях в памяти. Остальные
+// зависимости тесты подставляют сами
+func newTestService(t *testing.T) (*userService, *fakeUsers) {
+	t.Helper()
+
+	cfg := &config.Config{
+		JWT: config.JWTConfig{
+			JWTKeyConfig:         config.JWTKeyConfig{Algorithm: utils.AlgHS256, SecretKey: "test-secret"},
+			AccessTokenDuration:  15 * time.Minute,
+			RefreshTokenDuration: time.Hour,
+		},
+	}
+	jwtManager, err := utils.NewJWTManager(cfg)
+	if err != nil {
+		t.Fatalf("jwt manager: %v", err)
+	}
+
+	users := &fakeUsers{users: map[string]*entity.User{}}
+	return &userService{
+		repo:       users,
+		denylist:   memory.NewTokenDenylist(),
+		roles:      fakeRoles{},
+		events:     discardEvents{},
+		jwtManager: jwtManager,
+	}, users
+}
+
+// fakeUsers хранит пользователей и refresh-токены в памяти; методы, которые
+// тестам не нужны, не реализованы
+type fakeUsers struct {
+	repository.UserRepository
+
+	mu     sync.Mutex
+	users  map[string]*entity.User
+	tokens []*entity.RefreshToken
+}
+
+func (r *fakeUsers) add(u *entity.User) *entity.User {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[u.ID] = u
+	return u
+}
+
+func (r *fakeUsers) Create(_ context.Context, u *entity.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[u.ID] = u
+	return nil
+}
+
+func (r *fakeUsers) GetByID(_ context.Context, id string) (*entity.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.users[id], nil
+}
+
+func (r *fakeUsers) GetByUsername(_ context.Context, username string) (*entity.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUsers) GetByEmail(_ context.Context, email string) (*entity.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if strings.EqualFold(u.Email, email) {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUsers) SaveRefreshToken(_ context.Context, rt *entity.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tokens = append(r.tokens, rt)
+	return nil
+}
+
+type fakeRoles struct {
+	repository.RoleRepository
+}
+
+func (fakeRoles) UserPermissions(context.Context, string) ([]string, error) {
+	return nil, nil
+}
+
+type discardEvents struct{}
+
+func (discardEvents) Record(context.Context, *entity.AuthEvent) error {
+	return nil
+}
+
+// fakeOAuth хранит OAuth-клиентов в памяти
+type fakeOAuth struct {
+	repository.OAuthRepository
+
+	mu      sync.Mutex
+	clients map[string]*entity.OAuthClient
+}
+
+func (r *fakeOAuth) CreateClient(_ context.Context, c *entity.OAuthClient) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clients[c.ID] = c
+	return nil
+}
+
+func (r *fakeOAuth) GetClient(_ context.Context, id string) (*entity.OAuthClient, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.clients[id], nil
+}
+
+func (r *fakeUsers) GetRefreshToken(_ context.Context, token string) (*entity.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rt := range r.tokens {
+		if rt.Token == token {
+			return rt, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeUsers) RevokeRefreshTokenFamily(_ context.Context, familyID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rt := range r.tokens {
+		if rt.FamilyID == familyID {
+			rt.Revoked = true
+		}
+	}
+	return nil
+}
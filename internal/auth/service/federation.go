@@ -0,0 +1,238 @@
+package service
+
+import (
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/federation"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxUsernameLength — users.username VARCHAR(50) с запасом под суффикс
+const maxUsernameLength = 40
+
+var usernameDisallowed = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// BeginFederatedLogin начинает вход через внешнего провайдера и возвращает
+// адрес его страницы входа и секрет binding, который остаётся у браузера.
+// State, nonce и PKCE-верификатор остаются на сервере
+func (s *userService) BeginFederatedLogin(ctx context.Context, providerName string) (string, string, error) {
+	providerName = strings.ToLower(providerName)
+	provider, ok := s.identityProviders[providerName]
+	if !ok {
+		return "", "", ErrIdentityProviderNotFound
+	}
+
+	state, err := newOneTimeToken()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := newOneTimeToken()
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := newOneTimeToken()
+	if err != nil {
+		return "", "", err
+	}
+	binding, err := newOneTimeToken()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, pkceChallenge(verifier))
+	if err != nil {
+		log.Printf("identity provider %s is unavailable: %v", providerName, err)
+		return "", "", fmt.Errorf("identity provider %s: %w", providerName, err)
+	}
+
+	now := time.Now()
+	err = s.federation.SaveLoginState(ctx, &entity.FederatedLoginState{
+		State:        state,
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		BindingHash:  hashBinding(binding),
+		ExpiresAt:    now.Add(s.federationConfig.StateTTL),
+		CreatedAt:    now,
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("database error: %w", err)
+	}
+	return authURL, binding, nil
+}
+
+// CompleteFederatedLogin проверяет ответ провайдера и выполняет вход как Login.
+// binding должен совпасть с выданным в BeginFederatedLogin: иначе злоумышленник
+// мог бы подсунуть жертве ссылку со своими state и code, и жертва вошла бы в его аккаунт.
+// Новая учётная запись провайдера привязывается к пользователю с тем же
+// подтверждённым email, а если такого нет — регистрируется новый пользователь
+func (s *userService) CompleteFederatedLogin(ctx context.Context, state, code, binding string) (*LoginResult, error) {
+	loginState, err := s.federation.ConsumeLoginState(ctx, state)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if loginState == nil {
+		return nil, ErrInvalidFederatedState
+	}
+	if subtle.ConstantTimeCompare([]byte(hashBinding(binding)), []byte(loginState.BindingHash)) != 1 {
+		log.Printf("federated login via %s: browser binding mismatch", loginState.Provider)
+		s.recordEvent(ctx, "", entity.EventFederatedLogin, entity.OutcomeFailure)
+		return nil, ErrInvalidFederatedState
+	}
+	// Провайдера могли убрать из конфигурации, пока пользователь входил
+	provider, ok := s.identityProviders[loginState.Provider]
+	if !ok {
+		return nil, ErrInvalidFederatedState
+	}
+
+	identity, err := provider.Exchange(ctx, code, loginState.CodeVerifier, loginState.Nonce)
+	if err != nil {
+		// Подробности ответа провайдера клиенту не нужны
+		log.Printf("federated login via %s failed: %v", loginState.Provider, err)
+		s.recordEvent(ctx, "", entity.EventFederatedLogin, entity.OutcomeFailure)
+		return nil, ErrFederatedLoginFailed
+	}
+
+	user, err := s.federatedUser(ctx, loginState.Provider, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Disabled() {
+		s.recordEvent(ctx, user.ID, entity.EventFederatedLogin, entity.OutcomeFailure)
+		return nil, ErrAccountDisabled
+	}
+	if s.verification.Required && !user.EmailVerified() {
+		s.recordEvent(ctx, user.ID, entity.EventFederatedLogin, entity.OutcomeFailure)
+		return nil, ErrEmailNotVerified
+	}
+
+	result, err := s.startSession(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if !result.MFARequired {
+		s.recordEvent(ctx, user.ID, entity.EventFederatedLogin, entity.OutcomeSuccess)
+	}
+	return result, nil
+}
+
+// hashBinding — на сервере хранится только SHA-256 секрета браузера
+func hashBinding(binding string) string {
+	sum := sha256.Sum256([]byte(binding))
+	return hex.EncodeToString(sum[:])
+}
+
+// federatedUser возвращает пользователя, к которому привязана учётная запись
+// провайдера, и привязывает её при первом входе
+func (s *userService) federatedUser(ctx context.Context, provider string, identity *federation.Identity) (*entity.User, error) {
+	linked, err := s.federation.GetIdentity(ctx, provider, identity.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if linked != nil {
+		user, err := s.repo.GetByID(ctx, linked.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		if user == nil {
+			return nil, ErrUserNotFound
+		}
+		return user, nil
+	}
+
+	if identity.Email == "" {
+		log.Printf("identity provider %s returned no email for subject %s", provider, identity.Subject)
+		return nil, ErrFederatedLoginFailed
+	}
+	// Неподтверждённый провайдером email мог указать кто угодно: по нему нельзя
+	// ни привязать учётную запись, ни занять адрес новым пользователем
+	if !identity.EmailVerified {
+		log.Printf("identity provider %s has not verified the email of subject %s", provider, identity.Subject)
+		return nil, ErrFederatedEmailNotVerified
+	}
+
+	user, err := s.repo.GetByEmail(ctx, identity.Email)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	switch {
+	case user == nil:
+		if user, err = s.registerFederatedUser(ctx, identity); err != nil {
+			return nil, err
+		}
+	// Email должен быть подтверждён и у нас: иначе аккаунт, заранее
+	// зарегистрированный кем-то на чужой адрес, получил бы вход владельца адреса
+	case !user.EmailVerified():
+		return nil, ErrFederatedEmailConflict
+	}
+
+	err = s.federation.LinkIdentity(ctx, &entity.FederatedIdentity{
+		Provider:  provider,
+		Subject:   identity.Subject,
+		UserID:    user.ID,
+		Email:     identity.Email,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	s.recordEvent(ctx, user.ID, entity.EventIdentityLinked, entity.OutcomeSuccess)
+	return user, nil
+}
+
+// registerFederatedUser регистрирует пользователя провайдера с подтверждённым
+// email. Пароль случайный: войти по паролю можно будет после сброса через email
+func (s *userService) registerFederatedUser(ctx context.Context, identity *federation.Identity) (*entity.User, error) {
+	password, err := newOneTimeToken()
+	if err != nil {
+		return nil, err
+	}
+	username, err := s.freeUsername(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	input := RegisterInput{Username: username, Email: identity.Email, Password: password}
+	if identity.Name != "" {
+		input.Name = &identity.Name
+	}
+	return s.register(ctx, input, identity.EmailVerified)
+}
+
+// freeUsername подбирает свободный username из preferred_username или email
+func (s *userService) freeUsername(ctx context.Context, identity *federation.Identity) (string, error) {
+	base := identity.Username
+	if base == "" {
+		base, _, _ = strings.Cut(identity.Email, "@")
+	}
+	base = usernameDisallowed.ReplaceAllString(strings.ToLower(base), "")
+	if len(base) > maxUsernameLength {
+		base = base[:maxUsernameLength]
+	}
+	if base == "" {
+		base = "user"
+	}
+
+	candidate := base
+	for range 5 {
+		existing, err := s.repo.GetByUsername(ctx, candidate)
+		if err != nil {
+			return "", fmt.Errorf("database error: %w", err)
+		}
+		if existing == nil {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s-%04d", base, rand.IntN(10000))
+	}
+	return "", fmt.Errorf("failed to find a free username for %q", base)
+}
@@ -2,15 +2,185 @@ package service
 
 import (
 	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/utils"
 	"context"
+	"time"
 )
 
+// TokenPair — пара токенов, выдаваемая при входе и ротации
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time // время истечения access-токена
+	Scopes       []string  // scope, выданные OAuth-клиенту; пусто при обычном входе
+	IDToken      string    // ID-токен OpenID Connect, если клиент запросил scope openid
+}
+
+// LoginResult — результат проверки пароля. Если у пользователя включён
+// второй фактор, вместо токенов выдаётся MFAToken для VerifyMFA
+type LoginResult struct {
+	Tokens       *TokenPair
+	MFARequired  bool
+	MFAToken     string
+	MFAExpiresAt time.Time
+}
+
+// TOTPEnrollment — данные для подключения приложения-аутентификатора
+type TOTPEnrollment struct {
+	Secret string // base32, для ручного ввода
+	URI    string // otpauth://, для QR-кода
+}
+
+// TokenInfo — содержимое проверенного токена
+type TokenInfo struct {
+	UserID    string
+	Type      string // access или refresh
+	TokenID   string // jti, может быть пустым
+	IssuedAt  time.Time
+	NotBefore time.Time
+	ExpiresAt time.Time
+}
+
+// ListUsersInput — фильтр и страница для ListUsers. PageToken — значение
+// nextPageToken из предыдущего ответа
+type ListUsersInput struct {
+	UsernamePrefix string
+	EmailPrefix    string
+	CreatedAfter   time.Time
+	CreatedBefore  time.Time
+	PageSize       int
+	PageToken      string
+}
+
+// ListAuthEventsInput — фильтр и страница для ListAuthEvents
+type ListAuthEventsInput struct {
+	UserID    string
+	Type      string
+	Since     time.Time
+	Until     time.Time
+	PageSize  int
+	PageToken string
+}
+
+// AuthorizeInput — запрос авторизации OAuth 2.0 (RFC 6749, 4.1.1) с PKCE (RFC 7636)
+type AuthorizeInput struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string // через пробел; пусто — все scope клиента
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string // OpenID Connect, возвращается в ID-токене
+	// Approve — решение пользователя на странице согласия; nil — решения ещё нет
+	Approve *bool
+}
+
+// AuthorizeResult — куда вернуть браузер или о чём спросить пользователя
+type AuthorizeResult struct {
+	// RedirectURI — адрес клиента с code или error; пуст, если нужно согласие
+	RedirectURI     string
+	ConsentRequired bool
+	Client          *entity.OAuthClient
+	Scopes          []string
+}
+
+// OAuthTokenInput — параметры запроса к /oauth/token
+type OAuthTokenInput struct {
+	GrantType    string
+	ClientID     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+}
+
+// OAuthClientInput — регистрация OAuth-клиента
+type OAuthClientInput struct {
+	Name         string
+	RedirectURIs []string
+	Scopes       []string
+	GrantTypes   []string // пусто — authorization_code и refresh_token
+	Public       bool
+	FirstParty   bool
+	// PostLogoutRedirectURIs — куда можно вернуть браузер после выхода (OIDC)
+	PostLogoutRedirectURIs []string
+}
+
+// UserInfo — ответ /userinfo: claims, открытые scope access-токена
+type UserInfo struct {
+	Subject string
+	utils.UserClaims
+}
+
+// EndSessionInput — запрос выхода от клиента (OpenID Connect RP-Initiated Logout)
+type EndSessionInput struct {
+	IDTokenHint           string
+	ClientID              string
+	PostLogoutRedirectURI string
+	State                 string
+}
+
+// OpenIDProvider — сведения для /.well-known/openid-configuration
+type OpenIDProvider struct {
+	Issuer            string
+	SigningAlgorithms []string
+	Scopes            []string
+}
+
 type UserService interface {
 	Register(ctx context.Context, input RegisterInput) (*entity.User, error)
 	GetByUsername(ctx context.Context, username string) (*entity.User, error)
-	Login(ctx context.Context, username, password string) (accessToken string, refreshToken string, err error)
-	RefreshAccessToken(ctx context.Context, refreshToken string) (string, error)
+	Login(ctx context.Context, username, password string) (*LoginResult, error)
+	VerifyMFA(ctx context.Context, mfaToken, code string) (*TokenPair, error)
+	RefreshAccessToken(ctx context.Context, refreshToken string) (*TokenPair, error)
 	Logout(ctx context.Context, refreshToken string) error
+	ValidateAccessToken(ctx context.Context, token string) (*TokenInfo, error)
+	IntrospectToken(ctx context.Context, token string) (*TokenInfo, error)
+	ListSessions(ctx context.Context, userID string) ([]*entity.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID string) error
+	RevokeAllSessions(ctx context.Context, userID string, keepCurrent bool) error
+	UnlockAccount(ctx context.Context, username, ip string) error
+	PublicKeys() utils.JWKS
+	RotateSigningKey(ctx context.Context) (instance, currentKeyID string, verificationKeyIDs []string, err error)
 	GetUserByID(ctx context.Context, userID string) (*entity.User, error)
-	ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) error
+	GetUserInfo(ctx context.Context, userID string) (*entity.User, error)
+	UpdateUser(ctx context.Context, userID string, upd entity.UserProfileUpdate) (*entity.User, error)
+	ChangePassword(ctx context.Context, oldPassword, newPassword string, keepCurrentSession bool) (*TokenPair, error)
+	SendVerificationEmail(ctx context.Context) error
+	ResendVerificationEmail(ctx context.Context, email string) error
+	VerifyEmail(ctx context.Context, token string) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	BeginTOTPEnrollment(ctx context.Context) (*TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, code string) (recoveryCodes []string, err error)
+	DisableTOTP(ctx context.Context, code string) error
+	BeginPasskeyRegistration(ctx context.Context) (ceremonyID string, options []byte, err error)
+	FinishPasskeyRegistration(ctx context.Context, ceremonyID string, credential []byte, name string) (*entity.WebAuthnCredential, error)
+	BeginPasskeyLogin(ctx context.Context) (ceremonyID string, options []byte, err error)
+	FinishPasskeyLogin(ctx context.Context, ceremonyID string, credential []byte) (*TokenPair, error)
+	BeginFederatedLogin(ctx context.Context, provider string) (authURL, binding string, err error)
+	CompleteFederatedLogin(ctx context.Context, state, code, binding string) (*LoginResult, error)
+	ListRoles(ctx context.Context) ([]*entity.Role, error)
+	CreateRole(ctx context.Context, role *entity.Role) (*entity.Role, error)
+	AssignRole(ctx context.Context, userID, role string) error
+	RevokeRole(ctx context.Context, userID, role string) error
+	ListUsers(ctx context.Context, input ListUsersInput) (users []*entity.User, nextPageToken string, err error)
+	DisableUser(ctx context.Context, userID string) error
+	EnableUser(ctx context.Context, userID string) error
+	ForceLogout(ctx context.Context, userID string) error
+	ForcePasswordReset(ctx context.Context, userID string) error
+	DeleteUser(ctx context.Context, userID string) error
+	ListAuthEvents(ctx context.Context, input ListAuthEventsInput) (events []*entity.AuthEvent, nextPageToken string, err error)
+	BeginAuthorization(ctx context.Context, input AuthorizeInput) (redirectURI string, err error)
+	Authorize(ctx context.Context, input AuthorizeInput) (*AuthorizeResult, error)
+	ExchangeOAuthToken(ctx context.Context, input OAuthTokenInput) (*TokenPair, error)
+	CreateOAuthClient(ctx context.Context, input OAuthClientInput) (client *entity.OAuthClient, secret string, err error)
+	ListOAuthClients(ctx context.Context) ([]*entity.OAuthClient, error)
+	DeleteOAuthClient(ctx context.Context, clientID string) error
+	OpenIDProvider() OpenIDProvider
+	UserInfo(ctx context.Context, accessToken string) (*UserInfo, error)
+	EndSession(ctx context.Context, input EndSessionInput) (redirectURI string, err error)
 }
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"auth-micro/internal/auth/clientinfo"
+	"auth-micro/internal/auth/entity"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// maxAttemptKeyLength — login_attempts.attempt_key VARCHAR(300)
+const maxAttemptKeyLength = 300
+
+// loginAttemptKeys возвращает ключи счётчиков неудачных входов. Аккаунт
+// считается по username, даже если такого пользователя нет, — так
+// блокировка не выдаёт, существует ли аккаунт
+func loginAttemptKeys(ctx context.Context, username string) (account, ip string) {
+	account = accountAttemptKey(username)
+	if addr := clientinfo.FromContext(ctx).IP; addr != "" {
+		ip = "ip:" + addr
+	}
+	return account, ip
+}
+
+// accountAttemptKey — ключ счётчика аккаунта. Слишком длинный username
+// обрезается до размера колонки: таких пользователей всё равно нет
+func accountAttemptKey(username string) string {
+	key := []rune("username:" + strings.ToLower(username))
+	if len(key) > maxAttemptKeyLength {
+		key = key[:maxAttemptKeyLength]
+	}
+	return string(key)
+}
+
+// checkLoginLock возвращает *AccountLockedError, если аккаунт или IP заблокированы
+func (s *userService) checkLoginLock(ctx context.Context, account, ip string) error {
+	keys := []string{account}
+	if ip != "" {
+		keys = append(keys, ip)
+	}
+
+	until, err := s.attempts.LockedUntil(ctx, keys...)
+	if err != nil {
+		return fmt.Errorf("failed to check login lock: %w", err)
+	}
+	if until.After(time.Now()) {
+		return &AccountLockedError{Until: until}
+	}
+	return nil
+}
+
+// registerLoginFailure учитывает неудачную попытку и при превышении порога
+// блокирует ключ. Ошибки хранилища только логируются: ответ на неверный
+// пароль от них не зависит
+func (s *userService) registerLoginFailure(ctx context.Context, account, ip string) {
+	s.registerFailure(ctx, account, s.lockout.AccountThreshold)
+	if ip != "" {
+		s.registerFailure(ctx, ip, s.lockout.IPThreshold)
+	}
+}
+
+func (s *userService) registerFailure(ctx context.Context, key string, threshold int) {
+	if threshold <= 0 {
+		return
+	}
+
+	attempts, err := s.attempts.RegisterFailure(ctx, key, s.lockout.Window, s.lockout.ResetAfter)
+	if err != nil {
+		log.Printf("failed to register login failure for %s: %v", key, err)
+		return
+	}
+	if attempts.Failures < threshold {
+		return
+	}
+
+	until := time.Now().Add(s.lockDuration(attempts))
+	if err := s.attempts.Lock(ctx, key, until); err != nil {
+		log.Printf("failed to lock %s: %v", key, err)
+	}
+}
+
+// lockDuration удваивает блокировку с каждой следующей, не больше MaxDuration
+func (s *userService) lockDuration(attempts *entity.LoginAttempts) time.Duration {
+	d := s.lockout.BaseDuration
+	for i := 0; i < attempts.Lockouts && d < s.lockout.MaxDuration; i++ {
+		d *= 2
+	}
+	if s.lockout.MaxDuration > 0 && d > s.lockout.MaxDuration {
+		d = s.lockout.MaxDuration
+	}
+	return d
+}
+
+// UnlockAccount снимает блокировку входа с username и, если задан, с IP.
+// Нужно разрешение accounts:unlock
+func (s *userService) UnlockAccount(ctx context.Context, username, ip string) error {
+	if _, err := requirePermission(ctx, entity.PermissionAccountsUnlock); err != nil {
+		return err
+	}
+
+	keys := []string{accountAttemptKey(username)}
+	if ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+	if err := s.attempts.Reset(ctx, keys...); err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+
+	user, err := s.repo.GetByUsername(ctx, username)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	// Блокировка по имени есть и у несуществующих аккаунтов
+	var userID string
+	if user != nil {
+		userID = user.ID
+	}
+	s.recordEvent(ctx, userID, entity.EventAccountUnlocked, entity.OutcomeSuccess)
+	return nil
+}
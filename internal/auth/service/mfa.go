@@ -0,0 +1,252 @@
+package service
+
+import (
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/utils"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// recoveryCodeCount — сколько кодов восстановления выдаётся при подключении TOTP
+const recoveryCodeCount = 10
+
+// BeginTOTPEnrollment создаёт новый TOTP-секрет для текущего пользователя.
+// Фактор включается только после ConfirmTOTP с кодом из приложения
+func (s *userService) BeginTOTPEnrollment(ctx context.Context) (*TOTPEnrollment, error) {
+	p, err := currentPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetByID(ctx, p.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	factor, err := s.mfa.GetTOTP(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if factor != nil && factor.Confirmed() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := s.secrets.Seal(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt totp secret: %w", err)
+	}
+	if err := s.mfa.SaveTOTP(ctx, user.ID, sealed); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	return &TOTPEnrollment{
+		Secret: utils.EncodeTOTPSecret(secret),
+		URI:    utils.TOTPURI(s.mfaConfig.Issuer, user.Username, secret),
+	}, nil
+}
+
+// ConfirmTOTP включает фактор, если код из приложения верный, и возвращает
+// коды восстановления. Они показываются один раз — в БД только их хеши
+func (s *userService) ConfirmTOTP(ctx context.Context, code string) ([]string, error) {
+	p, err := currentPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	factor, err := s.mfa.GetTOTP(ctx, p.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if factor == nil {
+		return nil, fmt.Errorf("%w: totp enrollment not started", ErrInvalidArgument)
+	}
+	if factor.Confirmed() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := s.secrets.Open(factor.SecretEncrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt totp secret: %w", err)
+	}
+	step, ok := utils.ValidateTOTP(secret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	// Хеши кодов считает хранилище, здесь коды только приводятся к виду для сравнения
+	normalized := make([]string, len(codes))
+	for i, c := range codes {
+		normalized[i] = normalizeRecoveryCode(c)
+	}
+	if err := s.mfa.ConfirmTOTP(ctx, p.UserID, step, normalized); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	s.recordEvent(ctx, p.UserID, entity.EventMFAEnabled, entity.OutcomeSuccess)
+	return codes, nil
+}
+
+// DisableTOTP отключает второй фактор. Нужен действующий код из приложения
+// или код восстановления, одного access-токена недостаточно
+func (s *userService) DisableTOTP(ctx context.Context, code string) error {
+	p, err := currentPrincipal(ctx)
+	if err != nil {
+		return err
+	}
+
+	factor, err := s.mfa.GetTOTP(ctx, p.UserID)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if factor == nil || !factor.Confirmed() {
+		return ErrMFANotEnabled
+	}
+
+	ok, err := s.verifyMFACode(ctx, factor, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		s.recordEvent(ctx, p.UserID, entity.EventMFADisabled, entity.OutcomeFailure)
+		return ErrInvalidMFACode
+	}
+
+	if err := s.mfa.DeleteTOTP(ctx, p.UserID); err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+
+	s.recordEvent(ctx, p.UserID, entity.EventMFADisabled, entity.OutcomeSuccess)
+	return nil
+}
+
+// VerifyMFA завершает вход: обменивает mfa-токен из Login и код второго
+// фактора на пару токенов. Неверные коды считаются неудачными попытками входа
+func (s *userService) VerifyMFA(ctx context.Context, mfaToken, code string) (*TokenPair, error) {
+	claims, err := s.jwtManager.ValidateToken(mfaToken)
+	if err != nil || claims.Type != "mfa" {
+		return nil, ErrInvalidMFAToken
+	}
+	if err := s.checkDenylist(ctx, claims); err != nil {
+		if errors.Is(err, ErrTokenRevoked) {
+			return nil, ErrInvalidMFAToken
+		}
+		return nil, err
+	}
+
+	user, err := s.repo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if user == nil {
+		return nil, ErrInvalidMFAToken
+	}
+	if user.Disabled() {
+		return nil, ErrAccountDisabled
+	}
+
+	account, ip := loginAttemptKeys(ctx, user.Username)
+	if err := s.checkLoginLock(ctx, account, ip); err != nil {
+		return nil, err
+	}
+
+	factor, err := s.mfa.GetTOTP(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	// Фактор отключили после Login — пароль нужно предъявить заново
+	if factor == nil || !factor.Confirmed() {
+		return nil, ErrInvalidMFAToken
+	}
+
+	ok, err := s.verifyMFACode(ctx, factor, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		s.registerLoginFailure(ctx, account, ip)
+		s.recordEvent(ctx, user.ID, entity.EventLogin, entity.OutcomeFailure)
+		return nil, ErrInvalidMFACode
+	}
+
+	// mfa-токен одноразовый
+	if err := s.denylist.Revoke(ctx, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return nil, fmt.Errorf("failed to revoke mfa token: %w", err)
+	}
+	if err := s.attempts.Reset(ctx, account); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	pair, err := s.issueTokens(ctx, user, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordEvent(ctx, user.ID, entity.EventLogin, entity.OutcomeSuccess)
+	return pair, nil
+}
+
+// verifyMFACode принимает TOTP-код или код восстановления. TOTP-код одного
+// интервала принимается только один раз, код восстановления гасится
+func (s *userService) verifyMFACode(ctx context.Context, factor *entity.TOTPFactor, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return false, nil
+	}
+
+	secret, err := s.secrets.Open(factor.SecretEncrypted)
+	if err != nil {
+		return false, fmt.Errorf("failed to decrypt totp secret: %w", err)
+	}
+	if step, ok := utils.ValidateTOTP(secret, code, time.Now()); ok {
+		fresh, err := s.mfa.UseTOTPStep(ctx, factor.UserID, step)
+		if err != nil {
+			return false, fmt.Errorf("database error: %w", err)
+		}
+		return fresh, nil
+	}
+
+	used, err := s.mfa.UseRecoveryCode(ctx, factor.UserID, normalizeRecoveryCode(code))
+	if err != nil {
+		return false, fmt.Errorf("database error: %w", err)
+	}
+	if used {
+		s.recordEvent(ctx, factor.UserID, entity.EventRecoveryCodeUsed, entity.OutcomeSuccess)
+	}
+	return used, nil
+}
+
+// newRecoveryCodes возвращает коды вида XXXX-XXXX-XXXX-XXXX (80 бит)
+func newRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		raw := base32.StdEncoding.EncodeToString(b)
+		codes[i] = raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode убирает разделители и регистр, чтобы код можно было вводить как удобно
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,587 @@
+package service
+
+import (
+	"auth-micro/internal/auth/clientinfo"
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/utils"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// pkceS256 — единственный поддерживаемый метод PKCE: plain не защищает от перехвата кода
+const pkceS256 = "S256"
+
+// authorizeRequest — проверенный запрос авторизации
+type authorizeRequest struct {
+	input       AuthorizeInput
+	client      *entity.OAuthClient
+	redirectURI string
+	scopes      []string
+}
+
+// BeginAuthorization проверяет запрос к /oauth/authorize и возвращает, куда
+// отправить браузер: на страницу входа и согласия или обратно клиенту с
+// ошибкой. Ошибка возвращается, только если вернуть браузер клиенту нельзя
+func (s *userService) BeginAuthorization(ctx context.Context, input AuthorizeInput) (string, error) {
+	req, err := s.checkAuthorizeRequest(ctx, input)
+	if err != nil {
+		if req == nil {
+			return "", err
+		}
+		return req.errorRedirect(err), nil
+	}
+
+	consentURL, err := url.Parse(s.oauthConfig.ConsentURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid consent url: %w", err)
+	}
+	query := consentURL.Query()
+	for key, value := range map[string]string{
+		"response_type":         input.ResponseType,
+		"client_id":             input.ClientID,
+		"redirect_uri":          input.RedirectURI,
+		"scope":                 input.Scope,
+		"state":                 input.State,
+		"code_challenge":        input.CodeChallenge,
+		"code_challenge_method": input.CodeChallengeMethod,
+		"nonce":                 input.Nonce,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	consentURL.RawQuery = query.Encode()
+	return consentURL.String(), nil
+}
+
+// Authorize выдаёт код авторизации от имени текущего пользователя. Вызывается
+// страницей согласия: без решения пользователя код выдаётся, только если он
+// уже согласился на эти scope или клиент собственный
+func (s *userService) Authorize(ctx context.Context, input AuthorizeInput) (*AuthorizeResult, error) {
+	p, err := currentPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Токен, выданный одному клиенту, не должен открывать доступ другим
+	if p.ClientID != "" {
+		return nil, ErrPermissionDenied
+	}
+
+	req, err := s.checkAuthorizeRequest(ctx, input)
+	if err != nil {
+		if req == nil {
+			return nil, err
+		}
+		return &AuthorizeResult{RedirectURI: req.errorRedirect(err)}, nil
+	}
+
+	user, err := s.repo.GetByID(ctx, p.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if user.Disabled() {
+		return nil, ErrAccountDisabled
+	}
+
+	switch {
+	case input.Approve != nil && !*input.Approve:
+		s.recordEvent(ctx, user.ID, entity.EventOAuthConsent, entity.OutcomeFailure)
+		return &AuthorizeResult{
+			RedirectURI: req.errorRedirect(oauthError(OAuthAccessDenied, "the user denied the request")),
+		}, nil
+	case input.Approve != nil:
+		if err := s.oauth.SaveConsent(ctx, user.ID, req.client.ID, req.scopes); err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		s.recordEvent(ctx, user.ID, entity.EventOAuthConsent, entity.OutcomeSuccess)
+	case !req.client.FirstParty:
+		consented, err := s.oauth.ConsentedScopes(ctx, user.ID, req.client.ID)
+		if err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		if !containsAll(consented, req.scopes) {
+			return &AuthorizeResult{ConsentRequired: true, Client: req.client, Scopes: req.scopes}, nil
+		}
+	}
+
+	code, err := newOneTimeToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	err = s.oauth.SaveAuthorizationCode(ctx, &entity.OAuthAuthorizationCode{
+		Code:                code,
+		ClientID:            req.client.ID,
+		UserID:              user.ID,
+		RedirectURI:         input.RedirectURI,
+		Scopes:              req.scopes,
+		CodeChallenge:       input.CodeChallenge,
+		CodeChallengeMethod: input.CodeChallengeMethod,
+		Nonce:               input.Nonce,
+		ExpiresAt:           now.Add(s.oauthConfig.CodeTTL),
+		CreatedAt:           now,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to save authorization code: %w", err)
+	}
+
+	return &AuthorizeResult{
+		RedirectURI: req.redirect(url.Values{"code": {code}}),
+		Client:      req.client,
+		Scopes:      req.scopes,
+	}, nil
+}
+
+// checkAuthorizeRequest проверяет запрос авторизации. Если клиент или
+// redirect_uri не подтверждены, возвращает только ошибку; иначе ошибка
+// сопровождается запросом, и её можно вернуть клиенту через redirect_uri
+func (s *userService) checkAuthorizeRequest(ctx context.Context, input AuthorizeInput) (*authorizeRequest, error) {
+	if input.ClientID == "" {
+		return nil, oauthError(OAuthInvalidRequest, "client_id is required")
+	}
+	client, err := s.oauth.GetClient(ctx, input.ClientID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if client == nil {
+		return nil, oauthError(OAuthInvalidClient, "unknown client")
+	}
+
+	req := &authorizeRequest{input: input, client: client, redirectURI: input.RedirectURI}
+	switch {
+	case req.redirectURI == "" && len(client.RedirectURIs) == 1:
+		req.redirectURI = client.RedirectURIs[0]
+	case req.redirectURI == "":
+		return nil, oauthError(OAuthInvalidRequest, "redirect_uri is required")
+	case !client.AllowsRedirectURI(req.redirectURI):
+		return nil, oauthError(OAuthInvalidRequest, "redirect_uri is not registered for the client")
+	}
+
+	if input.ResponseType != "code" {
+		return req, oauthError(OAuthUnsupportedResponseType, "only response_type=code is supported")
+	}
+	if !client.AllowsGrant(entity.GrantAuthorizationCode) {
+		return req, oauthError(OAuthUnauthorizedClient, "authorization code grant is not allowed for the client")
+	}
+
+	if req.scopes, err = resolveScopes(input.Scope, client.Scopes); err != nil {
+		return req, err
+	}
+	if slices.Contains(req.scopes, entity.ScopeOpenID) && !s.jwtManager.SignsIDTokens() {
+		return req, oauthError(OAuthInvalidScope, "openid requires an asymmetric signing key on the server")
+	}
+
+	switch {
+	case input.CodeChallenge == "" && client.Public():
+		return req, oauthError(OAuthInvalidRequest, "code_challenge is required for public clients")
+	case input.CodeChallenge == "":
+	case input.CodeChallengeMethod != pkceS256:
+		return req, oauthError(OAuthInvalidRequest, "code_challenge_method must be S256")
+	case len(input.CodeChallenge) != base64.RawURLEncoding.EncodedLen(sha256.Size):
+		return req, oauthError(OAuthInvalidRequest, "invalid code_challenge")
+	}
+
+	return req, nil
+}
+
+// redirect возвращает redirect_uri клиента с параметрами ответа и state
+func (r *authorizeRequest) redirect(params url.Values) string {
+	if r.input.State != "" {
+		params.Set("state", r.input.State)
+	}
+	return appendQuery(r.redirectURI, params)
+}
+
+// appendQuery дописывает параметры к зарегистрированному адресу клиента
+func appendQuery(uri string, params url.Values) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		// Адреса проверены при регистрации клиента
+		return uri
+	}
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func (r *authorizeRequest) errorRedirect(err error) string {
+	var oauthErr *OAuthError
+	if !errors.As(err, &oauthErr) {
+		oauthErr = oauthError(OAuthServerError, "internal error")
+	}
+	return r.redirect(url.Values{
+		"error":             {oauthErr.Code},
+		"error_description": {oauthErr.Description},
+	})
+}
+
+// ExchangeOAuthToken обрабатывает запрос к /oauth/token
+func (s *userService) ExchangeOAuthToken(ctx context.Context, input OAuthTokenInput) (*TokenPair, error) {
+	client, err := s.authenticateClient(ctx, input.ClientID, input.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch input.GrantType {
+	case entity.GrantAuthorizationCode, entity.GrantRefreshToken, entity.GrantClientCredentials:
+	case "":
+		return nil, oauthError(OAuthInvalidRequest, "grant_type is required")
+	default:
+		return nil, oauthError(OAuthUnsupportedGrantType, "unsupported grant_type")
+	}
+	if !client.AllowsGrant(input.GrantType) {
+		return nil, oauthError(OAuthUnauthorizedClient, "grant_type is not allowed for the client")
+	}
+
+	switch input.GrantType {
+	case entity.GrantAuthorizationCode:
+		return s.exchangeAuthorizationCode(ctx, client, input)
+	case entity.GrantRefreshToken:
+		return s.refreshClientTokens(ctx, client, input)
+	default:
+		return s.issueClientCredentials(ctx, client, input)
+	}
+}
+
+func (s *userService) exchangeAuthorizationCode(ctx context.Context, client *entity.OAuthClient, input OAuthTokenInput) (*TokenPair, error) {
+	if input.Code == "" {
+		return nil, oauthError(OAuthInvalidRequest, "code is required")
+	}
+
+	// Код удаляется при первом предъявлении, даже если дальше проверка не пройдёт
+	code, err := s.oauth.ConsumeAuthorizationCode(ctx, input.Code)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if code == nil || code.ClientID != client.ID {
+		return nil, oauthError(OAuthInvalidGrant, "invalid or expired code")
+	}
+	if code.RedirectURI != input.RedirectURI {
+		return nil, oauthError(OAuthInvalidGrant, "redirect_uri does not match the authorization request")
+	}
+	if code.CodeChallenge != "" && !verifyPKCE(code.CodeChallenge, input.CodeVerifier) {
+		return nil, oauthError(OAuthInvalidGrant, "invalid code_verifier")
+	}
+
+	user, err := s.repo.GetByID(ctx, code.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if user == nil || user.Disabled() {
+		return nil, oauthError(OAuthInvalidGrant, "user is not available")
+	}
+
+	pair, err := s.issueOAuthTokens(ctx, user, client, code.Scopes, code.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	// Сессия сохраняется, чтобы её можно было завершить, но обновлять токены
+	// клиент без grant refresh_token не может
+	if !client.AllowsGrant(entity.GrantRefreshToken) {
+		pair.RefreshToken = ""
+	}
+
+	s.recordEvent(ctx, user.ID, entity.EventOAuthToken, entity.OutcomeSuccess)
+	return pair, nil
+}
+
+// refreshClientTokens ротирует refresh-токен клиента. Параметр scope не
+// поддерживается: новые токены получают scope исходного согласия
+func (s *userService) refreshClientTokens(ctx context.Context, client *entity.OAuthClient, input OAuthTokenInput) (*TokenPair, error) {
+	if input.RefreshToken == "" {
+		return nil, oauthError(OAuthInvalidRequest, "refresh_token is required")
+	}
+
+	pair, err := s.refreshTokens(ctx, input.RefreshToken, client.ID)
+	switch {
+	case errors.Is(err, ErrInvalidRefreshToken), errors.Is(err, ErrRefreshTokenReused), errors.Is(err, ErrAccountDisabled):
+		return nil, oauthError(OAuthInvalidGrant, err.Error())
+	case err != nil:
+		return nil, err
+	}
+	return pair, nil
+}
+
+// issueClientCredentials выдаёт access-токен самому клиенту для вызовов между
+// сервисами; scope клиента становятся разрешениями токена, refresh-токена нет
+func (s *userService) issueClientCredentials(ctx context.Context, client *entity.OAuthClient, input OAuthTokenInput) (*TokenPair, error) {
+	if client.Public() {
+		return nil, oauthError(OAuthUnauthorizedClient, "public clients cannot use client credentials")
+	}
+
+	scopes, err := resolveScopes(input.Scope, client.Scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	accessToken, err := s.jwtManager.GenerateOAuthToken("", client.ID, scopes, scopes, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	s.recordEvent(ctx, "", entity.EventOAuthToken, entity.OutcomeSuccess)
+	return &TokenPair{
+		AccessToken: accessToken,
+		ExpiresAt:   now.Add(s.jwtManager.AccessTokenDuration()),
+		Scopes:      scopes,
+	}, nil
+}
+
+// issueOAuthTokens открывает сессию пользователя для клиента: refresh-токен
+// попадает в общее хранилище и виден в списке сессий под именем клиента.
+// Со scope openid к паре добавляется ID-токен
+func (s *userService) issueOAuthTokens(ctx context.Context, user *entity.User, client *entity.OAuthClient, scopes []string, nonce string) (*TokenPair, error) {
+	now := time.Now()
+	info := clientinfo.FromContext(ctx)
+	rt := &entity.RefreshToken{
+		ID:          uuid.NewString(),
+		UserID:      user.ID,
+		ClientIP:    info.IP,
+		UserAgent:   info.UserAgent,
+		DeviceLabel: client.Name,
+		ClientID:    client.ID,
+		Scopes:      scopes,
+		LastUsedAt:  now,
+		ExpiresAt:   now.Add(s.jwtManager.RefreshTokenDuration()),
+		CreatedAt:   now,
+	}
+	rt.FamilyID = rt.ID
+	pair, err := s.signTokens(ctx, user, rt, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if slices.Contains(scopes, entity.ScopeOpenID) {
+		pair.IDToken, err = s.jwtManager.GenerateIDToken(user.ID, client.ID, rt.FamilyID, nonce, userClaims(user, scopes))
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate id token: %w", err)
+		}
+	}
+	return pair, nil
+}
+
+// authenticateClient проверяет client_id и секрет. Публичный клиент
+// секрета не передаёт
+func (s *userService) authenticateClient(ctx context.Context, clientID, secret string) (*entity.OAuthClient, error) {
+	if clientID == "" {
+		return nil, oauthError(OAuthInvalidClient, "client authentication required")
+	}
+	client, err := s.oauth.GetClient(ctx, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if client == nil {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(secret))
+		return nil, oauthError(OAuthInvalidClient, "client authentication failed")
+	}
+
+	if client.Public() {
+		if secret != "" {
+			return nil, oauthError(OAuthInvalidClient, "public clients have no secret")
+		}
+		return client, nil
+	}
+	if secret == "" || utils.CheckPasswordHash(secret, client.SecretHash) != nil {
+		return nil, oauthError(OAuthInvalidClient, "client authentication failed")
+	}
+	return client, nil
+}
+
+// CreateOAuthClient регистрирует клиента. Секрет возвращается только здесь,
+// в БД хранится его хэш
+func (s *userService) CreateOAuthClient(ctx context.Context, input OAuthClientInput) (*entity.OAuthClient, string, error) {
+	p, err := requirePermission(ctx, entity.PermissionClientsManage)
+	if err != nil {
+		return nil, "", err
+	}
+
+	grantTypes := input.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = []string{entity.GrantAuthorizationCode, entity.GrantRefreshToken}
+	}
+	if err := validateOAuthClient(input, grantTypes); err != nil {
+		return nil, "", err
+	}
+	// Токен client credentials получает scope клиента как разрешения, поэтому
+	// выдать клиенту можно только разрешения, которые есть у самого вызывающего
+	if slices.Contains(grantTypes, entity.GrantClientCredentials) {
+		for _, scope := range input.Scopes {
+			if !isOpenIDScope(scope) && !p.HasScope(scope) {
+				return nil, "", fmt.Errorf("%w: cannot grant scope %q to a client", ErrPermissionDenied, scope)
+			}
+		}
+	}
+
+	client := &entity.OAuthClient{
+		ID:                     uuid.NewString(),
+		Name:                   input.Name,
+		RedirectURIs:           input.RedirectURIs,
+		Scopes:                 input.Scopes,
+		GrantTypes:             grantTypes,
+		FirstParty:             input.FirstParty,
+		PostLogoutRedirectURIs: input.PostLogoutRedirectURIs,
+		CreatedAt:              time.Now(),
+	}
+
+	var secret string
+	if !input.Public {
+		if secret, err = newOneTimeToken(); err != nil {
+			return nil, "", err
+		}
+		if client.SecretHash, err = utils.HashPassword(secret); err != nil {
+			return nil, "", fmt.Errorf("failed to hash client secret: %w", err)
+		}
+	}
+
+	if err := s.oauth.CreateClient(ctx, client); err != nil {
+		return nil, "", fmt.Errorf("database error: %w", err)
+	}
+
+	s.recordEvent(ctx, "", entity.EventOAuthClientCreated, entity.OutcomeSuccess)
+	return client, secret, nil
+}
+
+func validateOAuthClient(input OAuthClientInput, grantTypes []string) error {
+	if strings.TrimSpace(input.Name) == "" {
+		return fmt.Errorf("%w: client name is required", ErrInvalidArgument)
+	}
+	for _, grantType := range grantTypes {
+		switch grantType {
+		case entity.GrantAuthorizationCode, entity.GrantRefreshToken:
+		case entity.GrantClientCredentials:
+			if input.Public {
+				return fmt.Errorf("%w: public clients cannot use client credentials", ErrInvalidArgument)
+			}
+		default:
+			return fmt.Errorf("%w: unsupported grant type %q", ErrInvalidArgument, grantType)
+		}
+	}
+	if slices.Contains(grantTypes, entity.GrantAuthorizationCode) && len(input.RedirectURIs) == 0 {
+		return fmt.Errorf("%w: redirect uri is required for authorization code grant", ErrInvalidArgument)
+	}
+	// Адреса сравниваются целиком, поэтому должны быть абсолютными и без фрагмента (RFC 6749, 3.1.2)
+	for _, uri := range slices.Concat(input.RedirectURIs, input.PostLogoutRedirectURIs) {
+		u, err := url.Parse(uri)
+		if err != nil || u.Scheme == "" || u.Fragment != "" {
+			return fmt.Errorf("%w: invalid redirect uri %q", ErrInvalidArgument, uri)
+		}
+	}
+	for _, scope := range input.Scopes {
+		if scope == "" || strings.ContainsAny(scope, " \"\\") {
+			return fmt.Errorf("%w: invalid scope %q", ErrInvalidArgument, scope)
+		}
+	}
+	return nil
+}
+
+func (s *userService) ListOAuthClients(ctx context.Context) ([]*entity.OAuthClient, error) {
+	if _, err := requirePermission(ctx, entity.PermissionClientsManage); err != nil {
+		return nil, err
+	}
+
+	clients, err := s.oauth.ListClients(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return clients, nil
+}
+
+// DeleteOAuthClient удаляет клиента; его refresh-токены перестают действовать,
+// выданные access-токены — по истечении срока
+func (s *userService) DeleteOAuthClient(ctx context.Context, clientID string) error {
+	if _, err := requirePermission(ctx, entity.PermissionClientsManage); err != nil {
+		return err
+	}
+
+	deleted, err := s.oauth.DeleteClient(ctx, clientID)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if !deleted {
+		return ErrOAuthClientNotFound
+	}
+
+	s.recordEvent(ctx, "", entity.EventOAuthClientDeleted, entity.OutcomeSuccess)
+	return nil
+}
+
+// isOpenIDScope — scope OpenID Connect, а не разрешение RBAC
+func isOpenIDScope(scope string) bool {
+	switch scope {
+	case entity.ScopeOpenID, entity.ScopeProfile, entity.ScopeEmail:
+		return true
+	}
+	return false
+}
+
+// resolveScopes разбирает scope запроса; каждый должен быть разрешён клиенту.
+// Пустой запрос получает все scope клиента
+func resolveScopes(requested string, allowed []string) ([]string, error) {
+	fields := strings.Fields(requested)
+	if len(fields) == 0 {
+		return slices.Clone(allowed), nil
+	}
+
+	var scopes []string
+	for _, scope := range fields {
+		if !slices.Contains(allowed, scope) {
+			return nil, oauthError(OAuthInvalidScope, fmt.Sprintf("scope %q is not allowed for the client", scope))
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// verifyPKCE сверяет code_verifier с code_challenge методом S256
+func verifyPKCE(challenge, verifier string) bool {
+	if verifier == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(pkceChallenge(verifier)), []byte(challenge)) == 1
+}
+
+// pkceChallenge — code_challenge метода S256 (RFC 7636, 4.2)
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// intersect возвращает элементы values, которые есть в allowed
+func intersect(values, allowed []string) []string {
+	var out []string
+	for _, v := range values {
+		if slices.Contains(allowed, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func containsAll(have, want []string) bool {
+	for _, v := range want {
+		if !slices.Contains(have, v) {
+			return false
+		}
+	}
+	return true
+}
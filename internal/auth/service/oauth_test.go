@@ -0,0 +1,72 @@
+package service
+
+import (
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/principal"
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// Администратор клиентов не может выдать клиенту разрешения, которых нет у него
+func TestCreateOAuthClientCannotEscalatePermissions(t *testing.T) {
+	s, _ := newTestService(t)
+	oauth := &fakeOAuth{clients: map[string]*entity.OAuthClient{}}
+	s.oauth = oauth
+
+	ctx := principal.WithPrincipal(context.Background(), &principal.Principal{
+		UserID: "2f4e6a8c-0b1d-4f3e-9a5c-7e9b1d3f5a70",
+		Scopes: []string{entity.PermissionClientsManage, entity.PermissionUsersRead},
+	})
+
+	_, _, err := s.CreateOAuthClient(ctx, OAuthClientInput{
+		Name:       "escalation",
+		Scopes:     []string{entity.PermissionRolesManage},
+		GrantTypes: []string{entity.GrantClientCredentials},
+	})
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Fatalf("create client with roles:manage: err = %v, want ErrPermissionDenied", err)
+	}
+	if len(oauth.clients) != 0 {
+		t.Fatalf("client was created: %+v", oauth.clients)
+	}
+
+	// Разрешение, которое есть у вызывающего, выдать можно
+	client, secret, err := s.CreateOAuthClient(ctx, OAuthClientInput{
+		Name:       "reporting",
+		Scopes:     []string{entity.PermissionUsersRead},
+		GrantTypes: []string{entity.GrantClientCredentials},
+	})
+	if err != nil {
+		t.Fatalf("create client with users:read: %v", err)
+	}
+
+	// Клиент не может запросить scope сверх своих
+	_, err = s.ExchangeOAuthToken(context.Background(), OAuthTokenInput{
+		GrantType:    entity.GrantClientCredentials,
+		ClientID:     client.ID,
+		ClientSecret: secret,
+		Scope:        entity.PermissionRolesManage,
+	})
+	var oauthErr *OAuthError
+	if !errors.As(err, &oauthErr) || oauthErr.Code != OAuthInvalidScope {
+		t.Fatalf("token with roles:manage: err = %v, want invalid_scope", err)
+	}
+
+	pair, err := s.ExchangeOAuthToken(context.Background(), OAuthTokenInput{
+		GrantType:    entity.GrantClientCredentials,
+		ClientID:     client.ID,
+		ClientSecret: secret,
+	})
+	if err != nil {
+		t.Fatalf("client credentials: %v", err)
+	}
+	claims, err := s.jwtManager.ValidateToken(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("validate token: %v", err)
+	}
+	if !slices.Equal(claims.Scopes, []string{entity.PermissionUsersRead}) {
+		t.Fatalf("token permissions = %v, want [users:read]", claims.Scopes)
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/utils"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// OpenIDProvider возвращает сведения для discovery. ID-токены подписываются
+// только асимметричным ключом: с HS256 scope OpenID Connect не предлагаются
+func (s *userService) OpenIDProvider() OpenIDProvider {
+	provider := OpenIDProvider{
+		Issuer:            s.oauthConfig.Issuer,
+		SigningAlgorithms: []string{},
+		Scopes:            []string{},
+	}
+	if s.jwtManager.SignsIDTokens() {
+		provider.SigningAlgorithms = []string{s.jwtManager.SigningAlgorithm()}
+		provider.Scopes = []string{entity.ScopeOpenID, entity.ScopeProfile, entity.ScopeEmail}
+	}
+	return provider
+}
+
+// UserInfo возвращает claims пользователя по access-токену клиента со scope openid
+func (s *userService) UserInfo(ctx context.Context, accessToken string) (*UserInfo, error) {
+	claims, err := s.parseToken(accessToken)
+	if err != nil || claims.Type != "access" {
+		return nil, oauthError(OAuthInvalidToken, "invalid access token")
+	}
+	if err := s.checkDenylist(ctx, claims); err != nil {
+		if errors.Is(err, ErrTokenRevoked) {
+			return nil, oauthError(OAuthInvalidToken, "access token revoked")
+		}
+		return nil, err
+	}
+
+	scopes := strings.Fields(claims.Scope)
+	if claims.UserID == "" || !slices.Contains(scopes, entity.ScopeOpenID) {
+		return nil, oauthError(OAuthInsufficientScope, "openid scope required")
+	}
+
+	user, err := s.repo.GetByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if user == nil || user.Disabled() {
+		return nil, oauthError(OAuthInvalidToken, "user is not available")
+	}
+
+	return &UserInfo{Subject: user.ID, UserClaims: userClaims(user, scopes)}, nil
+}
+
+// EndSession завершает сессию, в которой выдан ID-токен из id_token_hint, и
+// возвращает, куда отправить браузер; пустой адрес — остаться на странице выхода.
+// Своей браузерной сессии у сервиса нет, поэтому без id_token_hint не понять,
+// какую сессию завершать
+func (s *userService) EndSession(ctx context.Context, input EndSessionInput) (string, error) {
+	if input.IDTokenHint == "" {
+		return "", oauthError(OAuthInvalidRequest, "id_token_hint is required")
+	}
+	claims, err := s.jwtManager.ParseIDTokenHint(input.IDTokenHint)
+	if err != nil {
+		return "", oauthError(OAuthInvalidRequest, "invalid id_token_hint")
+	}
+	clientID := claims.Audience[0]
+	if input.ClientID != "" && input.ClientID != clientID {
+		return "", oauthError(OAuthInvalidRequest, "client_id does not match id_token_hint")
+	}
+
+	var redirect string
+	if input.PostLogoutRedirectURI != "" {
+		client, err := s.oauth.GetClient(ctx, clientID)
+		if err != nil {
+			return "", fmt.Errorf("database error: %w", err)
+		}
+		if client == nil || !client.AllowsPostLogoutRedirectURI(input.PostLogoutRedirectURI) {
+			return "", oauthError(OAuthInvalidRequest, "post_logout_redirect_uri is not registered for the client")
+		}
+		params := url.Values{}
+		if input.State != "" {
+			params.Set("state", input.State)
+		}
+		redirect = appendQuery(input.PostLogoutRedirectURI, params)
+	}
+
+	// Повторный выход из уже завершённой сессии тоже успешен
+	if claims.SessionID != "" {
+		if _, err := s.repo.RevokeUserRefreshTokenFamily(ctx, claims.Subject, claims.SessionID); err != nil {
+			return "", fmt.Errorf("database error: %w", err)
+		}
+		if err := s.revokeSessionTokens(ctx, claims.SessionID); err != nil {
+			return "", err
+		}
+	}
+
+	s.recordEvent(ctx, claims.Subject, entity.EventLogout, entity.OutcomeSuccess)
+	return redirect, nil
+}
+
+// userClaims возвращает claims пользователя, открытые scope profile и email
+func userClaims(user *entity.User, scopes []string) utils.UserClaims {
+	var claims utils.UserClaims
+	if slices.Contains(scopes, entity.ScopeProfile) {
+		claims.Name = user.Name
+		claims.PreferredUsername = user.Username
+		if !user.UpdatedAt.IsZero() {
+			claims.UpdatedAt = user.UpdatedAt.Unix()
+		}
+	}
+	if slices.Contains(scopes, entity.ScopeEmail) {
+		verified := user.EmailVerified()
+		claims.Email = user.Email
+		claims.EmailVerified = &verified
+	}
+	return claims
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"auth-micro/internal/auth/entity"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// issueOneTimeToken создаёт токен для письма на текущий email пользователя.
+// Прежние неиспользованные токены с тем же назначением перестают действовать
+func (s *userService) issueOneTimeToken(ctx context.Context, user *entity.User, purpose string, ttl time.Duration) (string, time.Time, error) {
+	token, err := newOneTimeToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	t := &entity.OneTimeToken{
+		ID:        uuid.NewString(),
+		UserID:    user.ID,
+		Purpose:   purpose,
+		Token:     token,
+		Email:     user.Email,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if err := s.tokens.Save(ctx, t); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to save %s token: %w", purpose, err)
+	}
+	return token, t.ExpiresAt, nil
+}
+
+// newOneTimeToken возвращает случайный токен для ссылок из писем
+func newOneTimeToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
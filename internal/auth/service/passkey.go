@@ -0,0 +1,282 @@
+package service
+
+import (
+	"auth-micro/internal/auth/entity"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+)
+
+// passkeyUser связывает пользователя и его ключи с интерфейсом webauthn.User.
+// User handle — ID пользователя, по нему находится аккаунт при входе без username
+type passkeyUser struct {
+	user  *entity.User
+	creds []*entity.WebAuthnCredential
+}
+
+func (u *passkeyUser) WebAuthnID() []byte          { return []byte(u.user.ID) }
+func (u *passkeyUser) WebAuthnName() string        { return u.user.Username }
+func (u *passkeyUser) WebAuthnDisplayName() string { return u.user.Username }
+
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	creds := make([]webauthn.Credential, 0, len(u.creds))
+	for _, c := range u.creds {
+		transports := make([]protocol.AuthenticatorTransport, len(c.Transports))
+		for i, t := range c.Transports {
+			transports[i] = protocol.AuthenticatorTransport(t)
+		}
+		creds = append(creds, webauthn.Credential{
+			ID:              c.CredentialID,
+			PublicKey:       c.PublicKey,
+			AttestationType: c.AttestationType,
+			Transport:       transports,
+			Flags: webauthn.CredentialFlags{
+				BackupEligible: c.BackupEligible,
+				BackupState:    c.BackupState,
+			},
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    c.AAGUID,
+				SignCount: c.SignCount,
+			},
+		})
+	}
+	return creds
+}
+
+// BeginPasskeyRegistration начинает регистрацию ключа доступа для текущего
+// пользователя. Возвращает id церемонии и PublicKeyCredentialCreationOptions в JSON
+func (s *userService) BeginPasskeyRegistration(ctx context.Context) (string, []byte, error) {
+	p, err := currentPrincipal(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	u, err := s.loadPasskeyUser(ctx, p.UserID)
+	if err != nil {
+		return "", nil, err
+	}
+	if u == nil {
+		return "", nil, ErrUserNotFound
+	}
+
+	creation, session, err := s.webAuthn.BeginRegistration(u,
+		webauthn.WithExclusions(webauthn.Credentials(u.WebAuthnCredentials()).CredentialDescriptors()),
+		webauthn.WithResidentKeyRequirement(protocol.ResidentKeyRequirementRequired),
+	)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to begin passkey registration: %w", err)
+	}
+
+	return s.startPasskeyCeremony(ctx, u.user.ID, entity.WebAuthnRegistration, session, creation)
+}
+
+// FinishPasskeyRegistration проверяет ответ аутентификатора и сохраняет ключ
+func (s *userService) FinishPasskeyRegistration(ctx context.Context, ceremonyID string, credential []byte, name string) (*entity.WebAuthnCredential, error) {
+	p, err := currentPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ceremony, session, err := s.finishPasskeyCeremony(ctx, ceremonyID, entity.WebAuthnRegistration)
+	if err != nil {
+		return nil, err
+	}
+	// Церемонию начал другой пользователь
+	if ceremony.UserID != p.UserID {
+		return nil, ErrInvalidPasskeyCeremony
+	}
+
+	u, err := s.loadPasskeyUser(ctx, p.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrUserNotFound
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(credential)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPasskey, protocolErrorDetails(err))
+	}
+	cred, err := s.webAuthn.CreateCredential(u, *session, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPasskey, protocolErrorDetails(err))
+	}
+
+	transports := make([]string, len(cred.Transport))
+	for i, t := range cred.Transport {
+		transports[i] = string(t)
+	}
+	c := &entity.WebAuthnCredential{
+		ID:              uuid.NewString(),
+		UserID:          p.UserID,
+		CredentialID:    cred.ID,
+		PublicKey:       cred.PublicKey,
+		AttestationType: cred.AttestationType,
+		Transports:      transports,
+		AAGUID:          cred.Authenticator.AAGUID,
+		SignCount:       cred.Authenticator.SignCount,
+		BackupEligible:  cred.Flags.BackupEligible,
+		BackupState:     cred.Flags.BackupState,
+		Name:            strings.TrimSpace(name),
+		CreatedAt:       time.Now(),
+	}
+	if err := s.passkeys.SaveCredential(ctx, c); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	s.recordEvent(ctx, p.UserID, entity.EventPasskeyRegistered, entity.OutcomeSuccess)
+	return c, nil
+}
+
+// BeginPasskeyLogin начинает вход ключом доступа без username
+// (discoverable credential): пользователя определяет аутентификатор
+func (s *userService) BeginPasskeyLogin(ctx context.Context) (string, []byte, error) {
+	assertion, session, err := s.webAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to begin passkey login: %w", err)
+	}
+
+	return s.startPasskeyCeremony(ctx, "", entity.WebAuthnLogin, session, assertion)
+}
+
+// FinishPasskeyLogin проверяет подпись аутентификатора и выдаёт пару токенов,
+// как Login. Ключ доступа с проверкой пользователя сам по себе двухфакторный,
+// поэтому TOTP не запрашивается
+func (s *userService) FinishPasskeyLogin(ctx context.Context, ceremonyID string, credential []byte) (*TokenPair, error) {
+	_, session, err := s.finishPasskeyCeremony(ctx, ceremonyID, entity.WebAuthnLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(credential)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPasskey, protocolErrorDetails(err))
+	}
+
+	var owner *passkeyUser
+	handler := func(_, userHandle []byte) (webauthn.User, error) {
+		u, err := s.loadPasskeyUser(ctx, string(userHandle))
+		if err != nil {
+			return nil, err
+		}
+		if u == nil {
+			return nil, ErrUserNotFound
+		}
+		owner = u
+		return u, nil
+	}
+
+	_, cred, err := s.webAuthn.ValidatePasskeyLogin(handler, *session, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPasskey, protocolErrorDetails(err))
+	}
+
+	// Счётчик подписей не вырос — вероятно, ключ скопирован
+	if cred.Authenticator.CloneWarning {
+		s.recordEvent(ctx, owner.user.ID, entity.EventPasskeyLogin, entity.OutcomeFailure)
+		return nil, fmt.Errorf("%w: authenticator may be cloned", ErrInvalidPasskey)
+	}
+	if err := s.passkeys.UpdateCredentialUsage(ctx, cred.ID, cred.Authenticator.SignCount, cred.Flags.BackupState); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	if owner.user.Disabled() {
+		return nil, ErrAccountDisabled
+	}
+	if s.verification.Required && !owner.user.EmailVerified() {
+		return nil, ErrEmailNotVerified
+	}
+
+	pair, err := s.issueTokens(ctx, owner.user, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordEvent(ctx, owner.user.ID, entity.EventPasskeyLogin, entity.OutcomeSuccess)
+	return pair, nil
+}
+
+func (s *userService) loadPasskeyUser(ctx context.Context, userID string) (*passkeyUser, error) {
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if user == nil {
+		return nil, nil
+	}
+
+	creds, err := s.passkeys.ListCredentials(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return &passkeyUser{user: user, creds: creds}, nil
+}
+
+// startPasskeyCeremony сохраняет состояние церемонии и возвращает её id
+// вместе с options для navigator.credentials в JSON
+func (s *userService) startPasskeyCeremony(ctx context.Context, userID, purpose string, session *webauthn.SessionData, options interface{}) (string, []byte, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode webauthn session: %w", err)
+	}
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to encode webauthn options: %w", err)
+	}
+
+	ceremony := &entity.WebAuthnSession{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Purpose:   purpose,
+		Data:      data,
+		ExpiresAt: time.Now().Add(s.webAuthnConfig.Timeout),
+	}
+	if err := s.passkeys.SaveSession(ctx, ceremony); err != nil {
+		return "", nil, fmt.Errorf("database error: %w", err)
+	}
+	return ceremony.ID, optionsJSON, nil
+}
+
+// finishPasskeyCeremony забирает состояние церемонии; повторно её завершить нельзя
+func (s *userService) finishPasskeyCeremony(ctx context.Context, ceremonyID, purpose string) (*entity.WebAuthnSession, *webauthn.SessionData, error) {
+	if _, err := uuid.Parse(ceremonyID); err != nil {
+		return nil, nil, ErrInvalidPasskeyCeremony
+	}
+
+	ceremony, err := s.passkeys.ConsumeSession(ctx, ceremonyID, purpose)
+	if err != nil {
+		return nil, nil, fmt.Errorf("database error: %w", err)
+	}
+	if ceremony == nil {
+		return nil, nil, ErrInvalidPasskeyCeremony
+	}
+
+	var session webauthn.SessionData
+	if err := json.Unmarshal(ceremony.Data, &session); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode webauthn session: %w", err)
+	}
+	return ceremony, &session, nil
+}
+
+// protocolErrorDetails достаёт из ошибки go-webauthn понятное описание
+func protocolErrorDetails(err error) string {
+	var perr *protocol.Error
+	if errors.As(err, &perr) {
+		if perr.DevInfo != "" {
+			log.Printf("webauthn: %s: %s", perr.Details, perr.DevInfo)
+		}
+		return perr.Details
+	}
+	return err.Error()
+}
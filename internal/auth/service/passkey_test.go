@@ -0,0 +1,386 @@
+package service
+
+import (
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/principal"
+	"auth-micro/internal/auth/repository"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "https://localhost"
+)
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	for _, format := range []string{"none", "packed"} {
+		t.Run(format, func(t *testing.T) {
+			s, user, _ := newPasskeyService(t)
+			auth := newSoftAuthenticator(t, format)
+
+			cred := registerPasskey(t, s, user, auth)
+			if !bytes.Equal(cred.CredentialID, auth.credentialID) {
+				t.Fatalf("saved credential id %x, want %x", cred.CredentialID, auth.credentialID)
+			}
+			if cred.AttestationType != format {
+				t.Fatalf("attestation type %q, want %q", cred.AttestationType, format)
+			}
+
+			pair, err := loginWithPasskey(t, s, auth)
+			if err != nil {
+				t.Fatalf("login: %v", err)
+			}
+			claims, err := s.jwtManager.ValidateToken(pair.AccessToken)
+			if err != nil || claims.UserID != user.ID {
+				t.Fatalf("access token for %q (%v), want %q", claims.UserID, err, user.ID)
+			}
+		})
+	}
+}
+
+func TestPasskeyLoginDetectsClonedAuthenticator(t *testing.T) {
+	s, user, passkeys := newPasskeyService(t)
+	auth := newSoftAuthenticator(t, "none")
+	registerPasskey(t, s, user, auth)
+
+	if _, err := loginWithPasskey(t, s, auth); err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if got := passkeys.signCount(auth.credentialID); got != auth.signCount {
+		t.Fatalf("stored sign count %d, want %d", got, auth.signCount)
+	}
+
+	// Копия ключа продолжает с того же счётчика
+	auth.signCount--
+	if _, err := loginWithPasskey(t, s, auth); !errors.Is(err, ErrInvalidPasskey) {
+		t.Fatalf("login with repeated sign count: err = %v, want ErrInvalidPasskey", err)
+	}
+}
+
+func TestPasskeyCeremonyCannotBeReused(t *testing.T) {
+	s, user, _ := newPasskeyService(t)
+	auth := newSoftAuthenticator(t, "none")
+	registerPasskey(t, s, user, auth)
+
+	ceremonyID, options, err := s.BeginPasskeyLogin(context.Background())
+	if err != nil {
+		t.Fatalf("begin login: %v", err)
+	}
+	response := auth.assert(t, options, []byte(user.ID))
+	if _, err := s.FinishPasskeyLogin(context.Background(), ceremonyID, response); err != nil {
+		t.Fatalf("finish login: %v", err)
+	}
+
+	auth.signCount++
+	response = auth.assert(t, options, []byte(user.ID))
+	if _, err := s.FinishPasskeyLogin(context.Background(), ceremonyID, response); !errors.Is(err, ErrInvalidPasskeyCeremony) {
+		t.Fatalf("second finish: err = %v, want ErrInvalidPasskeyCeremony", err)
+	}
+}
+
+func TestPasskeyRegistrationCeremonyBelongsToUser(t *testing.T) {
+	s, user, _ := newPasskeyService(t)
+	other := s.repo.(*fakeUsers).add(&entity.User{ID: "9f6b1c9e-0a4e-4f0e-8d55-3c1f4e2b7a10", Username: "mallory"})
+	auth := newSoftAuthenticator(t, "none")
+
+	ceremonyID, options, err := s.BeginPasskeyRegistration(userContext(user))
+	if err != nil {
+		t.Fatalf("begin registration: %v", err)
+	}
+	response := auth.create(t, options)
+	if _, err := s.FinishPasskeyRegistration(userContext(other), ceremonyID, response, "stolen"); !errors.Is(err, ErrInvalidPasskeyCeremony) {
+		t.Fatalf("finish by another user: err = %v, want ErrInvalidPasskeyCeremony", err)
+	}
+
+	// Церемония регистрации не подходит для входа
+	if _, err := s.FinishPasskeyLogin(context.Background(), ceremonyID, response); !errors.Is(err, ErrInvalidPasskeyCeremony) {
+		t.Fatalf("login with registration ceremony: err = %v, want ErrInvalidPasskeyCeremony", err)
+	}
+}
+
+func newPasskeyService(t *testing.T) (*userService, *entity.User, *fakePasskeys) {
+	t.Helper()
+	s, users := newTestService(t)
+
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          testRPID,
+		RPDisplayName: "auth-micro",
+		RPOrigins:     []string{testOrigin},
+	})
+	if err != nil {
+		t.Fatalf("webauthn: %v", err)
+	}
+	passkeys := &fakePasskeys{sessions: map[string]*entity.WebAuthnSession{}}
+	s.webAuthn = w
+	s.passkeys = passkeys
+	s.webAuthnConfig.Timeout = time.Minute
+
+	user := users.add(&entity.User{ID: "3b7e2f0a-6c1d-4e8b-9a2f-5d4c3b2a1f00", Username: "alice"})
+	return s, user, passkeys
+}
+
+func userContext(u *entity.User) context.Context {
+	return principal.WithPrincipal(context.Background(), &principal.Principal{UserID: u.ID})
+}
+
+func registerPasskey(t *testing.T, s *userService, u *entity.User, auth *softAuthenticator) *entity.WebAuthnCredential {
+	t.Helper()
+	ceremonyID, options, err := s.BeginPasskeyRegistration(userContext(u))
+	if err != nil {
+		t.Fatalf("begin registration: %v", err)
+	}
+	cred, err := s.FinishPasskeyRegistration(userContext(u), ceremonyID, auth.create(t, options), "laptop")
+	if err != nil {
+		t.Fatalf("finish registration: %v", err)
+	}
+	return cred
+}
+
+func loginWithPasskey(t *testing.T, s *userService, auth *softAuthenticator) (*TokenPair, error) {
+	t.Helper()
+	ceremonyID, options, err := s.BeginPasskeyLogin(context.Background())
+	if err != nil {
+		t.Fatalf("begin login: %v", err)
+	}
+	auth.signCount++
+	return s.FinishPasskeyLogin(context.Background(), ceremonyID, auth.assert(t, options, auth.userHandle))
+}
+
+// softAuthenticator — программный аутентификатор с ключом P-256 и
+// аттестацией none или packed (self attestation)
+type softAuthenticator struct {
+	format       string
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T, format string) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &softAuthenticator{format: format, key: key, credentialID: id}
+}
+
+// create отвечает на PublicKeyCredentialCreationOptions, как navigator.credentials.create
+func (a *softAuthenticator) create(t *testing.T, optionsJSON []byte) []byte {
+	t.Helper()
+	var options protocol.CredentialCreation
+	if err := json.Unmarshal(optionsJSON, &options); err != nil {
+		t.Fatalf("creation options: %v", err)
+	}
+	userID, _ := options.Response.User.ID.(string)
+	handle, err := base64.RawURLEncoding.DecodeString(userID)
+	if err != nil {
+		t.Fatalf("user handle: %v", err)
+	}
+	a.userHandle = handle
+
+	clientData := a.clientData(t, "webauthn.create", options.Response.Challenge)
+	authData := a.authenticatorData(options.Response.RelyingParty.ID, true)
+
+	attStmt := map[string]any{}
+	if a.format == "packed" {
+		attStmt["alg"] = int64(webauthncose.AlgES256)
+		attStmt["sig"] = a.sign(t, authData, clientData)
+	}
+	attestation, err := webauthncbor.Marshal(struct {
+		Format   string         `cbor:"fmt"`
+		AttStmt  map[string]any `cbor:"attStmt"`
+		AuthData []byte         `cbor:"authData"`
+	}{a.format, attStmt, authData})
+	if err != nil {
+		t.Fatalf("attestation object: %v", err)
+	}
+
+	return a.credential(t, map[string]any{
+		"clientDataJSON":    b64(clientData),
+		"attestationObject": b64(attestation),
+		"transports":        []string{"internal"},
+	})
+}
+
+// assert отвечает на PublicKeyCredentialRequestOptions, как navigator.credentials.get
+func (a *softAuthenticator) assert(t *testing.T, optionsJSON []byte, userHandle []byte) []byte {
+	t.Helper()
+	var options protocol.CredentialAssertion
+	if err := json.Unmarshal(optionsJSON, &options); err != nil {
+		t.Fatalf("request options: %v", err)
+	}
+
+	clientData := a.clientData(t, "webauthn.get", options.Response.Challenge)
+	authData := a.authenticatorData(options.Response.RelyingPartyID, false)
+
+	return a.credential(t, map[string]any{
+		"clientDataJSON":    b64(clientData),
+		"authenticatorData": b64(authData),
+		"signature":         b64(a.sign(t, authData, clientData)),
+		"userHandle":        b64(userHandle),
+	})
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, typ string, challenge protocol.URLEncodedBase64) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]string{
+		"type":      typ,
+		"challenge": b64(challenge),
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// authenticatorData собирает authData (WebAuthn, 6.1): хэш RP ID, флаги UP и UV,
+// счётчик и при регистрации — attested credential data с ключом в COSE
+func (a *softAuthenticator) authenticatorData(rpID string, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	flags := byte(protocol.FlagUserPresent | protocol.FlagUserVerified)
+	if attested {
+		flags |= byte(protocol.FlagAttestedCredentialData)
+	}
+
+	var b bytes.Buffer
+	b.Write(rpIDHash[:])
+	b.WriteByte(flags)
+	binary.Write(&b, binary.BigEndian, a.signCount)
+	if attested {
+		b.Write(make([]byte, 16)) // AAGUID
+		binary.Write(&b, binary.BigEndian, uint16(len(a.credentialID)))
+		b.Write(a.credentialID)
+		publicKey, _ := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+			PublicKeyData: webauthncose.PublicKeyData{
+				KeyType:   int64(webauthncose.EllipticKey),
+				Algorithm: int64(webauthncose.AlgES256),
+			},
+			Curve:  int64(webauthncose.P256),
+			XCoord: a.key.X.FillBytes(make([]byte, 32)),
+			YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+		})
+		b.Write(publicKey)
+	}
+	return b.Bytes()
+}
+
+// sign подписывает authData || SHA-256(clientDataJSON)
+func (a *softAuthenticator) sign(t *testing.T, authData, clientData []byte) []byte {
+	t.Helper()
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func (a *softAuthenticator) credential(t *testing.T, response map[string]any) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{
+		"id":       b64(a.credentialID),
+		"rawId":    b64(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// fakePasskeys хранит ключи и церемонии в памяти
+type fakePasskeys struct {
+	repository.WebAuthnRepository
+
+	mu       sync.Mutex
+	creds    []*entity.WebAuthnCredential
+	sessions map[string]*entity.WebAuthnSession
+}
+
+func (r *fakePasskeys) SaveCredential(_ context.Context, c *entity.WebAuthnCredential) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.creds = append(r.creds, c)
+	return nil
+}
+
+func (r *fakePasskeys) ListCredentials(_ context.Context, userID string) ([]*entity.WebAuthnCredential, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var creds []*entity.WebAuthnCredential
+	for _, c := range r.creds {
+		if c.UserID == userID {
+			copied := *c
+			creds = append(creds, &copied)
+		}
+	}
+	return creds, nil
+}
+
+func (r *fakePasskeys) UpdateCredentialUsage(_ context.Context, credentialID []byte, signCount uint32, backupState bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.creds {
+		if bytes.Equal(c.CredentialID, credentialID) {
+			c.SignCount = signCount
+			c.BackupState = backupState
+		}
+	}
+	return nil
+}
+
+func (r *fakePasskeys) signCount(credentialID []byte) uint32 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.creds {
+		if bytes.Equal(c.CredentialID, credentialID) {
+			return c.SignCount
+		}
+	}
+	return 0
+}
+
+func (r *fakePasskeys) SaveSession(_ context.Context, session *entity.WebAuthnSession) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[session.ID] = session
+	return nil
+}
+
+func (r *fakePasskeys) ConsumeSession(_ context.Context, id, purpose string) (*entity.WebAuthnSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session, ok := r.sessions[id]
+	if !ok || session.Purpose != purpose || time.Now().After(session.ExpiresAt) {
+		return nil, nil
+	}
+	delete(r.sessions, id)
+	return session, nil
+}
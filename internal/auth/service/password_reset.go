@@ -0,0 +1,94 @@
+package service
+
+import (
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/mailer"
+	"auth-micro/internal/auth/utils"
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+)
+
+// RequestPasswordReset отправляет ссылку для сброса пароля, если email
+// принадлежит пользователю. Ответ не зависит от того, найден ли аккаунт
+func (s *userService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if user == nil {
+		return nil
+	}
+
+	// Письмо отправляется в фоне, чтобы время ответа тоже не выдавало аккаунт
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if err := s.sendPasswordResetEmail(ctx, user); err != nil {
+			log.Printf("failed to send password reset email to user %s: %v", user.ID, err)
+		}
+	}()
+	return nil
+}
+
+// ResetPassword задаёт новый пароль по токену из письма и завершает все сессии
+func (s *userService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	t, err := s.tokens.Consume(ctx, entity.TokenPurposePasswordReset, token)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if t == nil {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.repo.GetByID(ctx, t.UserID)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	// Письмо ушло на адрес, который уже не принадлежит аккаунту
+	if user == nil || user.Email != t.Email {
+		return ErrInvalidResetToken
+	}
+
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := s.repo.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+
+	if err := s.endSessions(ctx, user.ID, ""); err != nil {
+		return err
+	}
+
+	// Владелец восстановил доступ — блокировка после подбора пароля больше не нужна
+	account, _ := loginAttemptKeys(ctx, user.Username)
+	if err := s.attempts.Reset(ctx, account); err != nil {
+		log.Printf("failed to reset login attempts for user %s: %v", user.ID, err)
+	}
+
+	s.recordEvent(ctx, user.ID, entity.EventPasswordReset, entity.OutcomeSuccess)
+	return nil
+}
+
+func (s *userService) sendPasswordResetEmail(ctx context.Context, user *entity.User) error {
+	token, expiresAt, err := s.issueOneTimeToken(ctx, user, entity.TokenPurposePasswordReset, s.passwordReset.TokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := s.passwordReset.URL + "?token=" + url.QueryEscape(token)
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Сброс пароля",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\nЧтобы задать новый пароль, перейдите по ссылке:\n%s\n\nСсылка действует до %s. "+
+			"Если вы не запрашивали сброс пароля, просто проигнорируйте это письмо.\n",
+			user.Username, link, expiresAt.UTC().Format("02.01.2006 15:04 MST")),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send password reset email: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/repository"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// roleNamePattern — допустимые имена ролей: они попадают в токены и конфигурацию
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,49}$`)
+
+func (s *userService) ListRoles(ctx context.Context) ([]*entity.Role, error) {
+	if _, err := requirePermission(ctx, entity.PermissionRolesManage); err != nil {
+		return nil, err
+	}
+
+	roles, err := s.roles.ListRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	return roles, nil
+}
+
+func (s *userService) CreateRole(ctx context.Context, role *entity.Role) (*entity.Role, error) {
+	if _, err := requirePermission(ctx, entity.PermissionRolesManage); err != nil {
+		return nil, err
+	}
+	if !roleNamePattern.MatchString(role.Name) {
+		return nil, fmt.Errorf("%w: invalid role name", ErrInvalidArgument)
+	}
+
+	role.CreatedAt = time.Now()
+	if err := s.roles.CreateRole(ctx, role); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrRoleExists):
+			return nil, ErrRoleExists
+		case errors.Is(err, repository.ErrRoleNotFound):
+			return nil, fmt.Errorf("%w: unknown permission", ErrInvalidArgument)
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	s.recordEvent(ctx, "", entity.EventRoleCreated, entity.OutcomeSuccess)
+	return role, nil
+}
+
+// AssignRole назначает роль пользователю. Новые разрешения появятся в его
+// токенах при следующем выпуске access-токена
+func (s *userService) AssignRole(ctx context.Context, userID, role string) error {
+	if _, err := requirePermission(ctx, entity.PermissionRolesManage); err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	if err := s.roles.AssignRole(ctx, userID, role); err != nil {
+		if errors.Is(err, repository.ErrRoleNotFound) {
+			return ErrRoleNotFound
+		}
+		return fmt.Errorf("database error: %w", err)
+	}
+
+	s.recordEvent(ctx, userID, entity.EventRoleAssigned, entity.OutcomeSuccess)
+	return nil
+}
+
+func (s *userService) RevokeRole(ctx context.Context, userID, role string) error {
+	p, err := requirePermission(ctx, entity.PermissionRolesManage)
+	if err != nil {
+		return err
+	}
+	// Иначе можно случайно лишиться управления ролями
+	if p.UserID == userID && role == entity.RoleAdmin {
+		return fmt.Errorf("%w: cannot revoke own admin role", ErrInvalidArgument)
+	}
+
+	revoked, err := s.roles.RevokeRole(ctx, userID, role)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if !revoked {
+		return ErrRoleNotFound
+	}
+
+	s.recordEvent(ctx, userID, entity.EventRoleRevoked, entity.OutcomeSuccess)
+	return nil
+}
@@ -1,26 +1,100 @@
 package service
 
 import (
+	"auth-micro/internal/auth/clientinfo"
+	"auth-micro/internal/auth/config"
 	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/federation"
+	"auth-micro/internal/auth/mailer"
+	"auth-micro/internal/auth/principal"
 	"auth-micro/internal/auth/repository"
 	"auth-micro/internal/auth/utils"
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type userService struct {
 	repo       repository.UserRepository
+	denylist   repository.TokenDenylist
+	attempts   repository.LoginAttemptRepository
+	roles      repository.RoleRepository
+	events     repository.EventSink
+	auditLog   repository.AuthEventRepository
+	tokens     repository.OneTimeTokenRepository
+	mfa        repository.MFARepository
+	secrets    *utils.SecretBox
+	passkeys   repository.WebAuthnRepository
+	webAuthn   *webauthn.WebAuthn
+	oauth      repository.OAuthRepository
+	federation repository.FederationRepository
+	mailer     mailer.Mailer
 	jwtManager *utils.JWTManager
+	lockout    config.LockoutConfig
+
+	verification  config.EmailVerificationConfig
+	passwordReset config.PasswordResetConfig
+	mfaConfig     config.MFAConfig
+
+	webAuthnConfig config.WebAuthnConfig
+	oauthConfig    config.OAuthConfig
+
+	identityProviders federation.Providers
+	federationConfig  config.FederationConfig
 }
 
-func NewUserService(repo repository.UserRepository, jwtManager *utils.JWTManager) UserService {
+func NewUserService(
+	repo repository.UserRepository,
+	denylist repository.TokenDenylist,
+	attempts repository.LoginAttemptRepository,
+	roles repository.RoleRepository,
+	events repository.EventSink,
+	auditLog repository.AuthEventRepository,
+	tokens repository.OneTimeTokenRepository,
+	mfa repository.MFARepository,
+	secrets *utils.SecretBox,
+	passkeys repository.WebAuthnRepository,
+	webAuthn *webauthn.WebAuthn,
+	oauth repository.OAuthRepository,
+	federationRepo repository.FederationRepository,
+	identityProviders federation.Providers,
+	mailer mailer.Mailer,
+	jwtManager *utils.JWTManager,
+	cfg *config.Config,
+) UserService {
 	return &userService{
 		repo:       repo,
+		denylist:   denylist,
+		attempts:   attempts,
+		roles:      roles,
+		events:     events,
+		auditLog:   auditLog,
+		tokens:     tokens,
+		mfa:        mfa,
+		secrets:    secrets,
+		passkeys:   passkeys,
+		webAuthn:   webAuthn,
+		oauth:      oauth,
+		federation: federationRepo,
+		mailer:     mailer,
 		jwtManager: jwtManager,
+		lockout:    cfg.Lockout,
+
+		verification:  cfg.EmailVerification,
+		passwordReset: cfg.PasswordReset,
+		mfaConfig:     cfg.MFA,
+
+		webAuthnConfig: cfg.WebAuthn,
+		oauthConfig:    cfg.OAuth,
+
+		identityProviders: identityProviders,
+		federationConfig:  cfg.Federation,
 	}
 }
 
@@ -34,6 +108,12 @@ type RegisterInput struct {
 }
 
 func (s *userService) Register(ctx context.Context, input RegisterInput) (*entity.User, error) {
+	return s.register(ctx, input, false)
+}
+
+// register создаёт пользователя. Email, уже подтверждённый внешним
+// провайдером, сразу отмечается подтверждённым, иначе уходит письмо
+func (s *userService) register(ctx context.Context, input RegisterInput, emailVerified bool) (*entity.User, error) {
 	existing, _ := s.repo.GetByUsername(ctx, input.Username)
 	if existing != nil {
 		return nil, fmt.Errorf("username already taken")
@@ -52,6 +132,7 @@ func (s *userService) Register(ctx context.Context, input RegisterInput) (*entit
 		Age:       getInt32(input.Age),
 		Bio:       getString(input.Bio),
 		Password:  string(hashed),
+		Roles:     []string{entity.RoleUser},
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -59,6 +140,22 @@ func (s *userService) Register(ctx context.Context, input RegisterInput) (*entit
 	if err := s.repo.Create(ctx, user); err != nil {
 		return nil, err
 	}
+	s.recordEvent(ctx, user.ID, entity.EventRegister, entity.OutcomeSuccess)
+
+	if emailVerified {
+		if _, err := s.repo.MarkEmailVerified(ctx, user.ID, user.Email); err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		user.EmailVerifiedAt = time.Now()
+		return user, nil
+	}
+
+	// Письмо можно запросить повторно, поэтому ошибка отправки не отменяет регистрацию
+	if user.Email != "" {
+		if err := s.sendVerificationEmail(ctx, user); err != nil {
+			log.Printf("failed to send verification email to user %s: %v", user.ID, err)
+		}
+	}
 	return user, nil
 }
 
@@ -79,130 +176,404 @@ func getInt32(v *int32) int32 {
 	return *v
 }
 
-func (s *userService) Login(ctx context.Context, username, password string) (string, string, error) {
+// dummyPasswordHash сравнивается с паролем, если пользователя нет, чтобы
+// время ответа не выдавало существование аккаунта
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+func (s *userService) Login(ctx context.Context, username, password string) (*LoginResult, error) {
+	account, ip := loginAttemptKeys(ctx, username)
+	if err := s.checkLoginLock(ctx, account, ip); err != nil {
+		s.recordEvent(ctx, "", entity.EventLogin, entity.OutcomeFailure)
+		return nil, err
+	}
+
 	user, err := s.repo.GetByUsername(ctx, username)
 	if err != nil {
-		return "", "", fmt.Errorf("database error: %w", err)
+		return nil, fmt.Errorf("database error: %w", err)
 	}
 	if user == nil {
-		return "", "", fmt.Errorf("invalid credentials")
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		s.registerLoginFailure(ctx, account, ip)
+		s.recordEvent(ctx, "", entity.EventLogin, entity.OutcomeFailure)
+		return nil, ErrInvalidCredentials
 	}
 
 	if err := utils.CheckPasswordHash(password, user.Password); err != nil {
-		return "", "", fmt.Errorf("invalid credentials")
+		s.registerLoginFailure(ctx, account, ip)
+		s.recordEvent(ctx, user.ID, entity.EventLogin, entity.OutcomeFailure)
+		return nil, ErrInvalidCredentials
 	}
 
-	// Использование JWTManager вместо прямых вызовов utils
-	accessToken, err := s.jwtManager.GenerateToken(user.ID)
-	if err != nil {
-		return "", "", fmt.Errorf("failed to generate access token: %w", err)
+	// Успешный вход сбрасывает счётчик аккаунта; счётчик IP сбрасывается
+	// только по окну, иначе свой аккаунт позволил бы перебирать чужие
+	if err := s.attempts.Reset(ctx, account); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	if user.Disabled() {
+		s.recordEvent(ctx, user.ID, entity.EventLogin, entity.OutcomeFailure)
+		return nil, ErrAccountDisabled
+	}
+	if s.verification.Required && !user.EmailVerified() {
+		s.recordEvent(ctx, user.ID, entity.EventLogin, entity.OutcomeFailure)
+		return nil, ErrEmailNotVerified
 	}
 
-	refreshToken, err := s.jwtManager.GenerateRefreshToken(user.ID)
+	result, err := s.startSession(ctx, user)
 	if err != nil {
-		return "", "", fmt.Errorf("failed to generate refresh token: %w", err)
+		return nil, err
+	}
+	if !result.MFARequired {
+		s.recordEvent(ctx, user.ID, entity.EventLogin, entity.OutcomeSuccess)
 	}
+	return result, nil
+}
 
-	rt := &entity.RefreshToken{
-		ID:        uuid.NewString(),
-		UserID:    user.ID,
-		Token:     refreshToken,
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
-		CreatedAt: time.Now(),
-		Revoked:   false,
+// startSession завершает проверку первого фактора: выдаёт пару токенов или,
+// если у пользователя включён TOTP, mfa-токен для VerifyMFA
+func (s *userService) startSession(ctx context.Context, user *entity.User) (*LoginResult, error) {
+	factor, err := s.mfa.GetTOTP(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
 	}
-	if err := s.repo.SaveRefreshToken(ctx, rt); err != nil {
-		return "", "", fmt.Errorf("failed to save refresh token: %w", err)
+	if factor != nil && factor.Confirmed() {
+		token, expiresAt, err := s.jwtManager.GenerateMFAToken(user.ID, s.mfaConfig.ChallengeTTL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate mfa token: %w", err)
+		}
+		return &LoginResult{MFARequired: true, MFAToken: token, MFAExpiresAt: expiresAt}, nil
 	}
 
-	return accessToken, refreshToken, nil
+	pair, err := s.issueTokens(ctx, user, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{Tokens: pair}, nil
 }
 
-func (s *userService) RefreshAccessToken(ctx context.Context, refreshToken string) (string, error) {
-	// Использование JWTManager
+// RefreshAccessToken обменивает refresh-токен на новую пару токенов.
+// Предъявленный токен отзывается, новый попадает в то же семейство.
+// Повторное предъявление уже ротированного токена означает его утечку,
+// поэтому в этом случае отзывается всё семейство.
+func (s *userService) RefreshAccessToken(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	return s.refreshTokens(ctx, refreshToken, "")
+}
+
+// refreshTokens ротирует refresh-токен, выданный clientID (пусто — обычный вход).
+// Токен OAuth-клиента обновляется только через /oauth/token этим клиентом
+func (s *userService) refreshTokens(ctx context.Context, refreshToken, clientID string) (*TokenPair, error) {
 	claims, err := s.jwtManager.ValidateToken(refreshToken)
 	if err != nil {
-		return "", fmt.Errorf("invalid refresh token")
+		return nil, ErrInvalidRefreshToken
 	}
 
 	if claims.Type != "refresh" {
-		return "", fmt.Errorf("invalid token type")
+		return nil, ErrInvalidRefreshToken
 	}
 
 	rt, err := s.repo.GetRefreshToken(ctx, refreshToken)
 	if err != nil {
-		return "", fmt.Errorf("database error: %w", err)
+		return nil, fmt.Errorf("database error: %w", err)
 	}
-	if rt == nil || rt.Revoked {
-		return "", fmt.Errorf("refresh token revoked or not found")
+	if rt == nil || rt.ClientID != clientID {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if rt.Revoked {
+		if err := s.revokeFamily(ctx, rt.FamilyID); err != nil {
+			return nil, err
+		}
+		s.recordEvent(ctx, rt.UserID, entity.EventTokenRefresh, entity.OutcomeFailure)
+		return nil, ErrRefreshTokenReused
 	}
 
 	if time.Now().After(rt.ExpiresAt) {
-		return "", fmt.Errorf("refresh token expired")
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.repo.GetByID(ctx, rt.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if user == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if user.Disabled() {
+		s.recordEvent(ctx, user.ID, entity.EventTokenRefresh, entity.OutcomeFailure)
+		return nil, ErrAccountDisabled
 	}
 
-	newAccessToken, err := s.jwtManager.GenerateToken(claims.UserID)
+	pair, err := s.issueTokens(ctx, user, rt)
+	if errors.Is(err, repository.ErrRefreshTokenRevoked) {
+		// Токен отозвали между чтением и ротацией — тоже повторное использование
+		if err := s.revokeFamily(ctx, rt.FamilyID); err != nil {
+			return nil, err
+		}
+		s.recordEvent(ctx, user.ID, entity.EventTokenRefresh, entity.OutcomeFailure)
+		return nil, ErrRefreshTokenReused
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to generate new access token: %w", err)
+		return nil, err
+	}
+
+	s.recordEvent(ctx, user.ID, entity.EventTokenRefresh, entity.OutcomeSuccess)
+	return pair, nil
+}
+
+// issueTokens выпускает пару токенов и сохраняет refresh-токен.
+// Если parent не nil, новый токен заменяет его в том же семействе (сессии)
+// и наследует сведения о клиенте, иначе они берутся из контекста запроса.
+func (s *userService) issueTokens(ctx context.Context, user *entity.User, parent *entity.RefreshToken) (*TokenPair, error) {
+	now := time.Now()
+	rt := &entity.RefreshToken{
+		ID:         uuid.NewString(),
+		UserID:     user.ID,
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(s.jwtManager.RefreshTokenDuration()),
+		CreatedAt:  now,
+		Revoked:    false,
+	}
+	if parent == nil {
+		client := clientinfo.FromContext(ctx)
+		rt.FamilyID = rt.ID
+		rt.ClientIP = client.IP
+		rt.UserAgent = client.UserAgent
+		rt.DeviceLabel = client.DeviceLabel
+	} else {
+		rt.FamilyID = parent.FamilyID
+		rt.ParentID = parent.ID
+		rt.ClientIP = parent.ClientIP
+		rt.UserAgent = parent.UserAgent
+		rt.DeviceLabel = parent.DeviceLabel
+		rt.ClientID = parent.ClientID
+		rt.Scopes = parent.Scopes
 	}
 
-	return newAccessToken, nil
+	return s.signTokens(ctx, user, rt, parent)
 }
 
-func (s *userService) ChangePassword(ctx context.Context, token, oldPassword, newPassword string) error {
-	// Использование JWTManager
-	claims, err := s.jwtManager.ValidateToken(token)
+// signTokens выпускает access-токен и refresh-токен rt и сохраняет его.
+// Токен OAuth-клиента получает только разрешения из выданных ему scope
+func (s *userService) signTokens(ctx context.Context, user *entity.User, rt *entity.RefreshToken, parent *entity.RefreshToken) (*TokenPair, error) {
+	permissions, err := s.roles.UserPermissions(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load permissions: %w", err)
+	}
+
+	var accessToken string
+	if rt.ClientID != "" {
+		accessToken, err = s.jwtManager.GenerateOAuthToken(user.ID, rt.ClientID, rt.Scopes, intersect(permissions, rt.Scopes), rt.FamilyID)
+	} else {
+		accessToken, err = s.jwtManager.GenerateToken(user.ID, user.Roles, permissions, rt.FamilyID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	rt.Token, err = s.jwtManager.GenerateRefreshToken(user.ID)
 	if err != nil {
-		return fmt.Errorf("invalid token: %w", err)
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	if parent == nil {
+		if err := s.repo.SaveRefreshToken(ctx, rt); err != nil {
+			return nil, fmt.Errorf("failed to save refresh token: %w", err)
+		}
+	} else {
+		if err := s.repo.RotateRefreshToken(ctx, parent.ID, rt); err != nil {
+			return nil, fmt.Errorf("failed to rotate refresh token: %w", err)
+		}
 	}
 
-	user, err := s.repo.GetByID(ctx, claims.UserID)
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: rt.Token,
+		ExpiresAt:    rt.CreatedAt.Add(s.jwtManager.AccessTokenDuration()),
+		Scopes:       rt.Scopes,
+	}, nil
+}
+
+// ChangePassword меняет пароль пользователя из контекста запроса, завершает
+// остальные его сессии и выдаёт новую пару токенов. С keepCurrentSession
+// сессия, из которой сделан запрос, остаётся активной со своими токенами,
+// и новая пара не выдаётся (nil)
+func (s *userService) ChangePassword(ctx context.Context, oldPassword, newPassword string, keepCurrentSession bool) (*TokenPair, error) {
+	p, err := currentPrincipal(ctx)
 	if err != nil {
-		return fmt.Errorf("user not found: %w", err)
+		return nil, err
+	}
+
+	user, err := s.repo.GetByID(ctx, p.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
 	}
 
 	if err := utils.CheckPasswordHash(oldPassword, user.Password); err != nil {
-		return fmt.Errorf("current password is incorrect")
+		s.recordEvent(ctx, user.ID, entity.EventPasswordChanged, entity.OutcomeFailure)
+		return nil, ErrWrongPassword
 	}
 
 	hashedPassword, err := utils.HashPassword(newPassword)
 	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := s.repo.UpdatePassword(ctx, user.ID, hashedPassword); err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	// Сессии, открытые со старым паролем, больше не должны работать
+	var keep string
+	if keepCurrentSession {
+		keep = p.SessionID
+	}
+	if err := s.endSessions(ctx, user.ID, keep); err != nil {
+		return nil, err
+	}
+	if keep != "" {
+		s.recordEvent(ctx, user.ID, entity.EventPasswordChanged, entity.OutcomeSuccess)
+		return nil, nil
+	}
+
+	// Вместе с текущей сессией отзываем и access-токен, которым сделан запрос
+	if err := s.revokeAccessToken(ctx, p); err != nil {
+		return nil, err
+	}
+
+	pair, err := s.issueTokens(ctx, user, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	return s.repo.UpdatePassword(ctx, claims.UserID, hashedPassword)
+	s.recordEvent(ctx, user.ID, entity.EventPasswordChanged, entity.OutcomeSuccess)
+	return pair, nil
 }
 
+// Logout завершает сессию refresh-токена вместе с её access-токенами. Как в
+// RFC 7009, право на отзыв даёт сам refresh-токен: access-токен к выходу часто
+// уже истёк. Недействительный или неизвестный токен ничего не открывает,
+// поэтому выход с ним тоже успешен
 func (s *userService) Logout(ctx context.Context, refreshToken string) error {
-	return s.repo.RevokeRefreshToken(ctx, refreshToken)
+	claims, err := s.jwtManager.ValidateToken(refreshToken)
+	if err != nil || claims.Type != "refresh" {
+		return nil
+	}
+	rt, err := s.repo.GetRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if rt == nil {
+		return nil
+	}
+
+	if err := s.revokeFamily(ctx, rt.FamilyID); err != nil {
+		return err
+	}
+	// Access-токен запроса может быть выдан вне этой сессии
+	if p, ok := principal.FromContext(ctx); ok && p.UserID == rt.UserID {
+		if err := s.revokeAccessToken(ctx, p); err != nil {
+			return err
+		}
+	}
+
+	s.recordEvent(ctx, rt.UserID, entity.EventLogout, entity.OutcomeSuccess)
+	return nil
 }
 
-func (s *userService) GetUserInfo(ctx context.Context, username, token string) (*entity.User, error) {
-	claims, err := s.jwtManager.ValidateToken(token)
-	if err != nil {
-		return nil, fmt.Errorf("invalid token: %w", err)
+// revokeAccessToken заносит access-токен вызывающего в denylist до его истечения
+func (s *userService) revokeAccessToken(ctx context.Context, p *principal.Principal) error {
+	if p.TokenID == "" {
+		return nil
+	}
+	if err := s.denylist.Revoke(ctx, p.TokenID, p.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to revoke access token: %w", err)
 	}
+	return nil
+}
 
-	if claims.Type != "access" {
-		return nil, fmt.Errorf("invalid token type")
+// GetUserInfo возвращает профиль пользователя userID ему самому или администратору
+func (s *userService) GetUserInfo(ctx context.Context, userID string) (*entity.User, error) {
+	if err := authorizeUserAccess(ctx, userID, entity.PermissionUsersRead); err != nil {
+		return nil, err
 	}
 
-	user, err := s.repo.GetByUsername(ctx, username)
+	user, err := s.repo.GetByID(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("database error: %w", err)
 	}
 	if user == nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+// UpdateUser частично обновляет профиль пользователя userID.
+// Доступно самому пользователю или администратору
+func (s *userService) UpdateUser(ctx context.Context, userID string, upd entity.UserProfileUpdate) (*entity.User, error) {
+	if err := authorizeUserAccess(ctx, userID, entity.PermissionUsersWrite); err != nil {
+		return nil, err
+	}
+
+	if upd.Email != nil && *upd.Email == "" {
+		return nil, fmt.Errorf("%w: email cannot be empty", ErrInvalidArgument)
+	}
+	if upd.Age != nil && *upd.Age < 0 {
+		return nil, fmt.Errorf("%w: age cannot be negative", ErrInvalidArgument)
 	}
 
-	// Проверяем, что запрашиваемый пользователь совпадает с пользователем из токена
-	if user.ID != claims.UserID {
-		return nil, fmt.Errorf("unauthorized access")
+	user, err := s.repo.UpdateProfile(ctx, userID, upd)
+	if err != nil {
+		if errors.Is(err, repository.ErrEmailTaken) {
+			return nil, ErrEmailTaken
+		}
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
 	}
 
 	return user, nil
 }
 
+// authorizeUserAccess проверяет, что вызывающий — сам userID или администратор
+func authorizeUserAccess(ctx context.Context, userID, permission string) error {
+	p, err := currentPrincipal(ctx)
+	if err != nil {
+		return err
+	}
+
+	if p.UserID != userID && !p.HasScope(permission) {
+		return ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// requirePermission возвращает вызывающего, если у него есть разрешение
+func requirePermission(ctx context.Context, permission string) (*principal.Principal, error) {
+	p, err := currentPrincipal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !p.HasScope(permission) {
+		return nil, ErrPermissionDenied
+	}
+	return p, nil
+}
+
+// currentPrincipal возвращает вызывающего, которого AuthInterceptor положил в контекст
+func currentPrincipal(ctx context.Context) (*principal.Principal, error) {
+	p, ok := principal.FromContext(ctx)
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	return p, nil
+}
+
 func (s *userService) GetUserByID(ctx context.Context, userID string) (*entity.User, error) {
 	return s.repo.GetByID(ctx, userID)
 }
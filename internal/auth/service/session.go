@@ -0,0 +1,140 @@
+package service
+
+import (
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/principal"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ListSessions возвращает активные сессии пользователя userID.
+// Пустой userID — текущий пользователь; чужие сессии видит только администратор
+func (s *userService) ListSessions(ctx context.Context, userID string) ([]*entity.Session, error) {
+	p, userID, err := sessionOwner(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.repo.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("database error: %w", err)
+	}
+
+	for _, session := range sessions {
+		session.Current = session.UserID == p.UserID && session.ID == p.SessionID
+	}
+	return sessions, nil
+}
+
+// RevokeSession завершает одну сессию пользователя userID
+func (s *userService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	_, userID, err := sessionOwner(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	// ID сессии — UUID; иначе Postgres вернёт ошибку приведения типа
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return ErrSessionNotFound
+	}
+
+	revoked, err := s.repo.RevokeUserRefreshTokenFamily(ctx, userID, sessionID)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if !revoked {
+		return ErrSessionNotFound
+	}
+	if err := s.revokeSessionTokens(ctx, sessionID); err != nil {
+		return err
+	}
+
+	s.recordEvent(ctx, userID, entity.EventSessionRevoked, entity.OutcomeSuccess)
+	return nil
+}
+
+// RevokeAllSessions завершает все сессии пользователя userID.
+// С keepCurrent сессия, из которой сделан запрос, остаётся активной
+func (s *userService) RevokeAllSessions(ctx context.Context, userID string, keepCurrent bool) error {
+	p, userID, err := sessionOwner(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var keep string
+	if keepCurrent && p.UserID == userID {
+		keep = p.SessionID
+	}
+	if err := s.endSessions(ctx, userID, keep); err != nil {
+		return err
+	}
+
+	s.recordEvent(ctx, userID, entity.EventSessionsRevoked, entity.OutcomeSuccess)
+	return nil
+}
+
+// endSessions завершает сессии пользователя, кроме keepSessionID (пусто — все),
+// вместе с выданными в них access-токенами
+func (s *userService) endSessions(ctx context.Context, userID, keepSessionID string) error {
+	var sessions []string
+	var err error
+	if keepSessionID != "" {
+		sessions, err = s.repo.RevokeOtherUserRefreshTokens(ctx, userID, keepSessionID)
+	} else {
+		sessions, err = s.repo.RevokeUserRefreshTokens(ctx, userID)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return s.revokeSessionTokens(ctx, sessions...)
+}
+
+// revokeFamily завершает сессию целиком, например при повторном
+// использовании её refresh-токена
+func (s *userService) revokeFamily(ctx context.Context, familyID string) error {
+	if err := s.repo.RevokeRefreshTokenFamily(ctx, familyID); err != nil {
+		return fmt.Errorf("failed to revoke token family: %w", err)
+	}
+	return s.revokeSessionTokens(ctx, familyID)
+}
+
+// revokeSessionTokens заносит сессии в denylist: их refresh-токены уже отозваны,
+// а выданные в них access-токены иначе действовали бы до истечения
+func (s *userService) revokeSessionTokens(ctx context.Context, sessionIDs ...string) error {
+	if len(sessionIDs) == 0 {
+		return nil
+	}
+	expiresAt := time.Now().Add(s.jwtManager.AccessTokenDuration())
+	if err := s.denylist.RevokeSessions(ctx, sessionIDs, expiresAt); err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+	return nil
+}
+
+// revokeUserTokens отзывает все access-токены пользователя, выданные до этого момента,
+// в том числе вне сессий; новые токены после повторного входа действуют
+func (s *userService) revokeUserTokens(ctx context.Context, userID string) error {
+	now := time.Now()
+	if err := s.denylist.RevokeUser(ctx, userID, now, now.Add(s.jwtManager.AccessTokenDuration())); err != nil {
+		return fmt.Errorf("failed to revoke access tokens: %w", err)
+	}
+	return nil
+}
+
+// sessionOwner определяет, чьи сессии затрагивает запрос, и проверяет доступ
+func sessionOwner(ctx context.Context, userID string) (*principal.Principal, string, error) {
+	p, err := currentPrincipal(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	if userID == "" {
+		userID = p.UserID
+	}
+	if err := authorizeUserAccess(ctx, userID, entity.PermissionSessionsManage); err != nil {
+		return nil, "", err
+	}
+	return p, userID, nil
+}
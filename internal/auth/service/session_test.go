@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"auth-micro/internal/auth/entity"
+)
+
+// Выйти можно одним refresh-токеном: access-токен к этому моменту часто истёк
+func TestLogoutByRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	svc, users := newTestService(t)
+	alice := users.add(&entity.User{ID: "5a0e7c1d-2b3f-4e6a-8c9d-0f1e2d3c4b5a", Username: "alice"})
+
+	tokens, err := svc.issueTokens(ctx, alice, nil)
+	if err != nil {
+		t.Fatalf("issue tokens: %v", err)
+	}
+
+	// Запрос без principal — как после истечения access-токена
+	if err := svc.Logout(ctx, tokens.RefreshToken); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+
+	rt, _ := users.GetRefreshToken(ctx, tokens.RefreshToken)
+	if rt == nil || !rt.Revoked {
+		t.Fatalf("refresh token = %+v, want revoked", rt)
+	}
+	claims, err := svc.jwtManager.ValidateToken(tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("access token: %v", err)
+	}
+	if revoked, err := svc.denylist.IsRevoked(ctx, claims.AccessToken()); err != nil || !revoked {
+		t.Fatalf("access token of the session revoked = %v (%v), want true", revoked, err)
+	}
+}
+
+// Недействительный или неизвестный токен ничего не открывает, выход с ним успешен
+func TestLogoutIgnoresUnknownToken(t *testing.T) {
+	ctx := context.Background()
+	svc, users := newTestService(t)
+	alice := users.add(&entity.User{ID: "5a0e7c1d-2b3f-4e6a-8c9d-0f1e2d3c4b5a", Username: "alice"})
+
+	tokens, err := svc.issueTokens(ctx, alice, nil)
+	if err != nil {
+		t.Fatalf("issue tokens: %v", err)
+	}
+
+	for _, token := range []string{"not-a-jwt", tokens.AccessToken} {
+		if err := svc.Logout(ctx, token); err != nil {
+			t.Fatalf("logout with %q: %v", token, err)
+		}
+	}
+	if rt, _ := users.GetRefreshToken(ctx, tokens.RefreshToken); rt.Revoked {
+		t.Fatal("session revoked by a token that is not its refresh token")
+	}
+}
@@ -0,0 +1,152 @@
+package service
+
+import (
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/utils"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ValidateAccessToken проверяет access-токен для других сервисов.
+// Refresh-токены отклоняются с ErrTokenWrongType.
+func (s *userService) ValidateAccessToken(ctx context.Context, token string) (*TokenInfo, error) {
+	claims, err := s.parseToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Type != "access" {
+		return nil, ErrTokenWrongType
+	}
+
+	if err := s.checkDenylist(ctx, claims); err != nil {
+		return nil, err
+	}
+
+	return tokenInfo(claims), nil
+}
+
+// IntrospectToken проверяет токен любого типа. Для refresh-токена
+// дополнительно сверяется, что он не отозван в хранилище. Вызывающему
+// нужно разрешение tokens:introspect
+func (s *userService) IntrospectToken(ctx context.Context, token string) (*TokenInfo, error) {
+	if _, err := requirePermission(ctx, entity.PermissionTokensIntrospect); err != nil {
+		return nil, err
+	}
+
+	claims, err := s.parseToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	switch claims.Type {
+	case "access":
+		if err := s.checkDenylist(ctx, claims); err != nil {
+			return nil, err
+		}
+	case "refresh":
+		rt, err := s.repo.GetRefreshToken(ctx, token)
+		if err != nil {
+			return nil, fmt.Errorf("database error: %w", err)
+		}
+		if rt == nil || rt.Revoked {
+			return nil, ErrTokenRevoked
+		}
+	default:
+		return nil, ErrTokenWrongType
+	}
+
+	return tokenInfo(claims), nil
+}
+
+// PublicKeys возвращает JWKS для проверки токенов без доступа к ключу подписи
+func (s *userService) PublicKeys() utils.JWKS {
+	return s.jwtManager.JWKS()
+}
+
+// RotateSigningKey перечитывает ключи из конфигурации только на экземпляре,
+// принявшем запрос, и возвращает его имя; нужно разрешение keys:rotate.
+// Остальные реплики подписывают прежним ключом, пока ключи не перечитаны и на
+// них (этим же вызовом к каждой или SIGHUP). Если ключ подписи в конфигурации
+// прежний, возвращает ErrSigningKeyUnchanged
+func (s *userService) RotateSigningKey(ctx context.Context) (string, string, []string, error) {
+	if _, err := requirePermission(ctx, entity.PermissionKeysRotate); err != nil {
+		return "", "", nil, err
+	}
+
+	instance := instanceName()
+	if _, err := s.jwtManager.ReloadKeys(); err != nil {
+		if errors.Is(err, utils.ErrSigningKeyUnchanged) {
+			return instance, "", nil, ErrSigningKeyUnchanged
+		}
+		return instance, "", nil, fmt.Errorf("failed to reload signing keys on %s: %w", instance, err)
+	}
+
+	s.recordEvent(ctx, "", entity.EventSigningKeyRotated, entity.OutcomeSuccess)
+	current, verification := s.jwtManager.KeyIDs()
+	log.Printf("Signing key rotated on instance %s, current kid: %s", instance, current)
+	return instance, current, verification, nil
+}
+
+// instanceName — имя хоста экземпляра, в Kubernetes это имя пода
+func instanceName() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return "unknown"
+	}
+	return name
+}
+
+// checkDenylist возвращает ErrTokenRevoked, если токен отозван до истечения срока
+func (s *userService) checkDenylist(ctx context.Context, claims *utils.Claims) error {
+	revoked, err := s.denylist.IsRevoked(ctx, claims.AccessToken())
+	if err != nil {
+		return fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// parseToken разбирает токен и переводит ошибки jwt в ошибки сервиса
+func (s *userService) parseToken(token string) (*utils.Claims, error) {
+	claims, err := s.jwtManager.ValidateToken(token)
+	if err == nil {
+		return claims, nil
+	}
+
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return nil, ErrTokenExpired
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrTokenUnverifiable):
+		return nil, ErrTokenInvalidSignature
+	default:
+		return nil, ErrTokenMalformed
+	}
+}
+
+func tokenInfo(claims *utils.Claims) *TokenInfo {
+	return &TokenInfo{
+		UserID:    claims.UserID,
+		Type:      claims.Type,
+		TokenID:   claims.ID,
+		IssuedAt:  numericDate(claims.IssuedAt),
+		NotBefore: numericDate(claims.NotBefore),
+		ExpiresAt: numericDate(claims.ExpiresAt),
+	}
+}
+
+// numericDate возвращает нулевое время для отсутствующего claim
+func numericDate(d *jwt.NumericDate) time.Time {
+	if d == nil {
+		return time.Time{}
+	}
+	return d.Time
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"auth-micro/internal/auth/entity"
+	"auth-micro/internal/auth/mailer"
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+)
+
+// SendVerificationEmail повторно отправляет письмо с подтверждением
+// текущему пользователю. Прежняя ссылка перестаёт работать
+func (s *userService) SendVerificationEmail(ctx context.Context) error {
+	p, err := currentPrincipal(ctx)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetByID(ctx, p.UserID)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if user.EmailVerified() {
+		return ErrEmailAlreadyVerified
+	}
+	if user.Email == "" {
+		return fmt.Errorf("%w: user has no email", ErrInvalidArgument)
+	}
+
+	return s.sendVerificationEmail(ctx, user)
+}
+
+// ResendVerificationEmail повторно отправляет письмо с подтверждением на email
+// без входа в аккаунт. Ответ не зависит от того, найден ли неподтверждённый аккаунт
+func (s *userService) ResendVerificationEmail(ctx context.Context, email string) error {
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if user == nil || user.EmailVerified() {
+		return nil
+	}
+
+	// Как и при сбросе пароля, письмо уходит в фоне
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if err := s.sendVerificationEmail(ctx, user); err != nil {
+			log.Printf("failed to send verification email to user %s: %v", user.ID, err)
+		}
+	}()
+	return nil
+}
+
+// VerifyEmail подтверждает email по токену из письма. Токен одноразовый
+func (s *userService) VerifyEmail(ctx context.Context, token string) error {
+	t, err := s.tokens.Consume(ctx, entity.TokenPurposeEmailVerification, token)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if t == nil {
+		return ErrInvalidVerificationToken
+	}
+
+	// Если email сменили после отправки письма, ссылка подтверждает уже не тот адрес
+	ok, err := s.repo.MarkEmailVerified(ctx, t.UserID, t.Email)
+	if err != nil {
+		return fmt.Errorf("database error: %w", err)
+	}
+	if !ok {
+		return ErrInvalidVerificationToken
+	}
+
+	s.recordEvent(ctx, t.UserID, entity.EventEmailVerified, entity.OutcomeSuccess)
+	return nil
+}
+
+func (s *userService) sendVerificationEmail(ctx context.Context, user *entity.User) error {
+	token, expiresAt, err := s.issueOneTimeToken(ctx, user, entity.TokenPurposeEmailVerification, s.verification.TokenTTL)
+	if err != nil {
+		return err
+	}
+
+	link := s.verification.URL + "?token=" + url.QueryEscape(token)
+	err = s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Подтверждение email",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\nЧтобы подтвердить email, перейдите по ссылке:\n%s\n\nСсылка действует до %s.\n",
+			user.Username, link, expiresAt.UTC().Format("02.01.2006 15:04 MST")),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send verification email: %w", err)
+	}
+	return nil
+}
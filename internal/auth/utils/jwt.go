@@ -1,86 +1,326 @@
 package utils
 
 import (
-    "auth-micro/internal/auth/config"
-    "time"
+	"auth-micro/internal/auth/config"
+	"auth-micro/internal/auth/entity"
+	"errors"
+	"strings"
+	"sync"
+	"time"
 
-    "github.com/golang-jwt/jwt/v5"
-    "golang.org/x/crypto/bcrypt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type Claims struct {
-    UserID string `json:"user_id"`
-    Type   string `json:"type"`
-    jwt.RegisteredClaims
+	UserID    string   `json:"user_id"`
+	Type      string   `json:"type"`
+	Roles     []string `json:"roles,omitempty"`  // только в access-токенах
+	Scopes    []string `json:"scopes,omitempty"` // разрешения ролей, только в access-токенах
+	SessionID string   `json:"sid,omitempty"`    // семейство refresh-токенов, в рамках которого выдан токен
+	// ClientID и Scope — у токенов, выданных OAuth-клиенту (RFC 9068); scope через пробел
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// AccessToken возвращает поля токена, по которым проверяется его отзыв
+func (c *Claims) AccessToken() entity.AccessToken {
+	token := entity.AccessToken{ID: c.ID, SessionID: c.SessionID, UserID: c.UserID}
+	if c.IssuedAt != nil {
+		token.IssuedAt = c.IssuedAt.Time
+	}
+	return token
+}
+
+// UserClaims — claims о пользователе из OpenID Connect (OIDC Core, 5.1).
+// Заполняются только те, что открыты выданными scope
+type UserClaims struct {
+	Name              string `json:"name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	UpdatedAt         int64  `json:"updated_at,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+}
+
+// IDTokenClaims — ID-токен OpenID Connect: sub — пользователь, aud — client_id,
+// sid — сессия (семейство refresh-токенов), которую завершает RP-initiated logout
+type IDTokenClaims struct {
+	UserClaims
+	Nonce     string `json:"nonce,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
 }
 
 // JWTManager управляет JWT токенами
 type JWTManager struct {
-    cfg *config.Config
+	cfg  *config.Config
+	keys *keyRing
+
+	// Сроки жизни токенов меняются при ReloadKeys
+	mu         sync.RWMutex
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewJWTManager(cfg *config.Config) (*JWTManager, error) {
+	keys, err := newKeyRing(cfg.JWT)
+	if err != nil {
+		return nil, err
+	}
+	return &JWTManager{
+		cfg:        cfg,
+		keys:       keys,
+		accessTTL:  cfg.JWT.AccessTokenDuration,
+		refreshTTL: cfg.JWT.RefreshTokenDuration,
+	}, nil
+}
+
+// ReloadKeys перечитывает конфигурацию: предыдущие ключи и сроки жизни токенов
+// применяются сразу, новый ключ подписи становится текущим без рестарта.
+// Возвращает kid текущего ключа и ErrSigningKeyUnchanged, если ключ подписи
+// тот же. Переменные окружения перекрывают файл, поэтому ключ из SECRET_KEY
+// меняется только рестартом, а ключ из JWT_PRIVATE_KEY_PATH — заменой файла
+func (j *JWTManager) ReloadKeys() (string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return "", err
+	}
+	changed, err := j.keys.reload(cfg.JWT)
+	if err != nil {
+		return "", err
+	}
+
+	j.mu.Lock()
+	j.accessTTL, j.refreshTTL = cfg.JWT.AccessTokenDuration, cfg.JWT.RefreshTokenDuration
+	j.mu.Unlock()
+
+	kid := j.keys.signing().id
+	if !changed {
+		return kid, ErrSigningKeyUnchanged
+	}
+	return kid, nil
+}
+
+// KeyIDs возвращает kid текущего ключа и всех ключей, принимаемых при проверке
+func (j *JWTManager) KeyIDs() (current string, verification []string) {
+	for _, key := range j.keys.active() {
+		verification = append(verification, key.id)
+	}
+	return j.keys.signing().id, verification
+}
+
+// AccessTokenDuration возвращает время жизни access-токена
+func (j *JWTManager) AccessTokenDuration() time.Duration {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.accessTTL
 }
 
-func NewJWTManager(cfg *config.Config) *JWTManager {
-    return &JWTManager{cfg: cfg}
+// RefreshTokenDuration возвращает время жизни refresh-токена
+func (j *JWTManager) RefreshTokenDuration() time.Duration {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	return j.refreshTTL
 }
 
-func (j *JWTManager) GenerateToken(userID string) (string, error) {
-    claims := Claims{
-        UserID: userID,
-        Type:   "access",
-        RegisteredClaims: jwt.RegisteredClaims{
-            ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.cfg.JWT.AccessTokenDuration)),
-            IssuedAt:  jwt.NewNumericDate(time.Now()),
-            NotBefore: jwt.NewNumericDate(time.Now()),
-        },
-    }
+// GenerateToken выпускает access-токен; roles и scopes (разрешения ролей)
+// позволяют другим сервисам авторизовать запрос без обращения к auth
+func (j *JWTManager) GenerateToken(userID string, roles, scopes []string, sessionID string) (string, error) {
+	claims := Claims{
+		UserID:    userID,
+		Type:      "access",
+		Roles:     roles,
+		Scopes:    scopes,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			// jti нужен, чтобы отозвать токен до истечения срока
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.AccessTokenDuration())),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
+	}
 
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    return token.SignedString([]byte(j.cfg.JWT.SecretKey))
+	return j.sign(claims)
+}
+
+// GenerateOAuthToken выпускает access-токен OAuth-клиенту: scope — выданные
+// клиенту scope, scopes — разрешения из их числа. Роли в токен не попадают,
+// чтобы клиент получил не больше, чем разрешил пользователь. Для client
+// credentials userID и sessionID пусты
+func (j *JWTManager) GenerateOAuthToken(userID, clientID string, scope, scopes []string, sessionID string) (string, error) {
+	claims := Claims{
+		UserID:    userID,
+		Type:      "access",
+		Scopes:    scopes,
+		SessionID: sessionID,
+		ClientID:  clientID,
+		Scope:     strings.Join(scope, " "),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.AccessTokenDuration())),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	return j.sign(claims)
+}
+
+// ErrIDTokenUnsupported — текущий ключ подписи симметричный. ID-токен с такой
+// подписью клиент мог бы проверить только общим секретом, поэтому он не выпускается
+var ErrIDTokenUnsupported = errors.New("id tokens require an asymmetric signing key")
+
+// SignsIDTokens сообщает, можно ли выпускать ID-токены текущим ключом подписи
+func (j *JWTManager) SignsIDTokens() bool {
+	_, ok := j.keys.signing().jwk()
+	return ok
+}
+
+// GenerateIDToken выпускает ID-токен для клиента clientID. Живёт столько же,
+// сколько access-токен. С симметричным ключом возвращает ErrIDTokenUnsupported
+func (j *JWTManager) GenerateIDToken(userID, clientID, sessionID, nonce string, user UserClaims) (string, error) {
+	key := j.keys.signing()
+	if _, ok := key.jwk(); !ok {
+		return "", ErrIDTokenUnsupported
+	}
+
+	now := time.Now()
+	claims := IDTokenClaims{
+		UserClaims: user,
+		Nonce:      nonce,
+		SessionID:  sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    j.cfg.OAuth.Issuer,
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{clientID},
+			ExpiresAt: jwt.NewNumericDate(now.Add(j.AccessTokenDuration())),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	return signWith(key, claims)
 }
 
 func (j *JWTManager) GenerateRefreshToken(userID string) (string, error) {
-    claims := Claims{
-        UserID: userID,
-        Type:   "refresh",
-        RegisteredClaims: jwt.RegisteredClaims{
-            ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.cfg.JWT.RefreshTokenDuration)),
-            IssuedAt:  jwt.NewNumericDate(time.Now()),
-            NotBefore: jwt.NewNumericDate(time.Now()),
-        },
-    }
+	claims := Claims{
+		UserID: userID,
+		Type:   "refresh",
+		RegisteredClaims: jwt.RegisteredClaims{
+			// jti делает каждый refresh-токен уникальным, даже если два токена
+			// выпущены одному пользователю в одну и ту же секунду
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.RefreshTokenDuration())),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
+	}
 
-    token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-    return token.SignedString([]byte(j.cfg.JWT.SecretKey))
+	return j.sign(claims)
+}
+
+// GenerateMFAToken выпускает короткоживущий токен, подтверждающий пароль,
+// который обменивается на пару токенов в VerifyMFA вместе с кодом второго фактора
+func (j *JWTManager) GenerateMFAToken(userID string, ttl time.Duration) (string, time.Time, error) {
+	expiresAt := time.Now().Add(ttl)
+	claims := Claims{
+		UserID: userID,
+		Type:   "mfa",
+		RegisteredClaims: jwt.RegisteredClaims{
+			// по jti токен отзывается после успешной проверки, чтобы его нельзя было использовать повторно
+			ID:        uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token, err := j.sign(claims)
+	return token, expiresAt, err
+}
+
+// sign подписывает claims текущим ключом и проставляет kid в заголовок
+func (j *JWTManager) sign(claims jwt.Claims) (string, error) {
+	return signWith(j.keys.signing(), claims)
+}
+
+func signWith(key *signingKey, claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+	return token.SignedString(key.signKey)
+}
+
+// keyFunc выбирает ключ проверки по kid токена
+func (j *JWTManager) keyFunc(token *jwt.Token) (interface{}, error) {
+	// Токены без kid выпущены до его появления — проверяем их текущим ключом
+	key := j.keys.signing()
+	if kid, ok := token.Header["kid"].(string); ok {
+		if key, ok = j.keys.verification(kid); !ok {
+			return nil, jwt.ErrTokenUnverifiable
+		}
+	}
+	// Алгоритм должен совпадать с алгоритмом ключа, иначе возможна подмена (alg confusion)
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+	return key.verifyKey, nil
 }
 
 func (j *JWTManager) ValidateToken(tokenString string) (*Claims, error) {
-    token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-        if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-            return nil, jwt.ErrSignatureInvalid
-        }
-        return []byte(j.cfg.JWT.SecretKey), nil
-    })
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, j.keyFunc)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+		return claims, nil
+	}
 
-    if err != nil {
-        return nil, err
-    }
+	return nil, jwt.ErrTokenInvalidClaims
+}
 
-    if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-        return claims, nil
-    }
+// ParseIDTokenHint проверяет подпись и издателя ID-токена, выданного нами.
+// Срок не проверяется: id_token_hint при выходе может быть уже просрочен
+func (j *JWTManager) ParseIDTokenHint(tokenString string) (*IDTokenClaims, error) {
+	claims := &IDTokenClaims{}
+	if _, err := jwt.ParseWithClaims(tokenString, claims, j.keyFunc, jwt.WithoutClaimsValidation()); err != nil {
+		return nil, err
+	}
+	if claims.Issuer != j.cfg.OAuth.Issuer || claims.Subject == "" || len(claims.Audience) == 0 {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
 
-    return nil, jwt.ErrTokenInvalidClaims
+// SigningAlgorithm возвращает алгоритм текущего ключа подписи
+func (j *JWTManager) SigningAlgorithm() string {
+	return j.keys.signing().method.Alg()
+}
+
+// JWKS возвращает публичные ключи проверки подписи: текущий и ещё действующие предыдущие.
+// HMAC-ключи не публикуются
+func (j *JWTManager) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range j.keys.active() {
+		if jwk, ok := key.jwk(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
 }
 
 // Утилиты для паролей (не зависят от конфига)
 func CheckPasswordHash(password, hash string) error {
-    return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
 func HashPassword(password string) (string, error) {
-    hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-    if err != nil {
-        return "", err
-    }
-    return string(hashedPassword), nil
-}
\ No newline at end of file
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashedPassword), nil
+}
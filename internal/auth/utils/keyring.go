@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"auth-micro/internal/auth/config"
+)
+
+// ErrSigningKeyUnchanged — в перечитанной конфигурации тот же ключ подписи
+var ErrSigningKeyUnchanged = errors.New("signing key did not change")
+
+// keyRing хранит текущий ключ подписи и предыдущие ключи, которые ещё
+// принимаются при проверке. Предыдущий ключ живёт RefreshTokenDuration после
+// вывода из оборота — к этому моменту истекают все подписанные им токены.
+type keyRing struct {
+	mu       sync.RWMutex
+	ttl      time.Duration
+	current  *signingKey
+	previous map[string]retiredKey
+}
+
+type retiredKey struct {
+	key       *signingKey
+	expiresAt time.Time
+}
+
+func newKeyRing(cfg config.JWTConfig) (*keyRing, error) {
+	current, err := loadSigningKey(cfg.JWTKeyConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	ring := &keyRing{
+		ttl:      cfg.RefreshTokenDuration,
+		current:  current,
+		previous: make(map[string]retiredKey),
+	}
+	if err := ring.addPrevious(cfg.PreviousKeys, time.Now()); err != nil {
+		return nil, err
+	}
+	return ring, nil
+}
+
+// addPrevious загружает предыдущие ключи из конфига. RetiredAt обязателен:
+// иначе срок ключа отсчитывался бы заново при каждом запуске
+func (r *keyRing) addPrevious(keys []config.JWTKeyConfig, now time.Time) error {
+	for _, kc := range keys {
+		if kc.RetiredAt.IsZero() {
+			return fmt.Errorf("retired_at is required for previous jwt key %q", kc.KeyID)
+		}
+		key, err := loadSigningKey(kc)
+		if err != nil {
+			return fmt.Errorf("failed to load previous jwt key %q: %w", kc.KeyID, err)
+		}
+		if key.id == r.current.id {
+			continue
+		}
+
+		expiresAt := kc.RetiredAt.Add(r.ttl)
+		if !expiresAt.After(now) {
+			continue
+		}
+		// Если ключ уже есть в кольце, оставляем более поздний срок
+		if prev, ok := r.previous[key.id]; ok && prev.expiresAt.After(expiresAt) {
+			continue
+		}
+		r.previous[key.id] = retiredKey{key: key, expiresAt: expiresAt}
+	}
+	return nil
+}
+
+// signing возвращает текущий ключ подписи
+func (r *keyRing) signing() *signingKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.current
+}
+
+// verification ищет ключ проверки по kid среди текущего и непросроченных предыдущих
+func (r *keyRing) verification(kid string) (*signingKey, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if kid == r.current.id {
+		return r.current, true
+	}
+	prev, ok := r.previous[kid]
+	if !ok || time.Now().After(prev.expiresAt) {
+		return nil, false
+	}
+	return prev.key, true
+}
+
+// active возвращает текущий ключ и все непросроченные предыдущие
+func (r *keyRing) active() []*signingKey {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	keys := []*signingKey{r.current}
+	for kid, prev := range r.previous {
+		if now.After(prev.expiresAt) {
+			delete(r.previous, kid)
+			continue
+		}
+		keys = append(keys, prev.key)
+	}
+	return keys
+}
+
+// reload применяет новую конфигурацию ключей и возвращает, сменился ли текущий
+// ключ. Старый ключ переходит в предыдущие и принимается ещё ttl; чтобы он
+// пережил рестарт, его нужно перенести в previous_keys с retired_at
+func (r *keyRing) reload(cfg config.JWTConfig) (bool, error) {
+	next, err := loadSigningKey(cfg.JWTKeyConfig)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	changed := next.id != r.current.id
+	if !changed && !next.sameKey(r.current) {
+		// Например, kid задан в JWT_KEY_ID, а ключ в файле заменили
+		return false, fmt.Errorf("jwt key id %q is already used by the current key", next.id)
+	}
+
+	r.ttl = cfg.RefreshTokenDuration
+	if changed {
+		r.previous[r.current.id] = retiredKey{key: r.current, expiresAt: now.Add(r.ttl)}
+		delete(r.previous, next.id)
+		r.current = next
+	}
+	return changed, r.addPrevious(cfg.PreviousKeys, now)
+}
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"auth-micro/internal/auth/config"
+)
+
+// Поддерживаемые алгоритмы подписи
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+)
+
+// JWK — публичный ключ в формате RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS — набор публичных ключей для /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// signingKey — ключ подписи вместе с алгоритмом и идентификатором (kid)
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// loadSigningKey создаёт ключ по конфигурации: секрет для HS256
+// или приватный ключ из PEM-файла для асимметричных алгоритмов
+func loadSigningKey(cfg config.JWTKeyConfig) (*signingKey, error) {
+	if cfg.Algorithm == "" || cfg.Algorithm == AlgHS256 {
+		if cfg.SecretKey == "" {
+			return nil, fmt.Errorf("jwt secret key is required for %s", AlgHS256)
+		}
+		id := cfg.KeyID
+		if id == "" {
+			// kid не должен раскрывать секрет, поэтому берём префикс его хэша
+			sum := sha256.Sum256([]byte(cfg.SecretKey))
+			id = base64.RawURLEncoding.EncodeToString(sum[:8])
+		}
+		secret := []byte(cfg.SecretKey)
+		return &signingKey{id: id, method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}, nil
+	}
+
+	pemBytes, err := os.ReadFile(cfg.PrivateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwt private key: %w", err)
+	}
+
+	key := &signingKey{id: cfg.KeyID}
+	switch cfg.Algorithm {
+	case AlgRS256:
+		priv, err := jwt.ParseRSAPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RSA private key: %w", err)
+		}
+		key.method, key.signKey, key.verifyKey = jwt.SigningMethodRS256, priv, &priv.PublicKey
+	case AlgES256:
+		priv, err := jwt.ParseECPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse EC private key: %w", err)
+		}
+		if priv.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%s requires a P-256 key", AlgES256)
+		}
+		key.method, key.signKey, key.verifyKey = jwt.SigningMethodES256, priv, &priv.PublicKey
+	case AlgEdDSA:
+		priv, err := jwt.ParseEdPrivateKeyFromPEM(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Ed25519 private key: %w", err)
+		}
+		edPriv, ok := priv.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s requires an Ed25519 key", AlgEdDSA)
+		}
+		key.method, key.signKey, key.verifyKey = jwt.SigningMethodEdDSA, edPriv, edPriv.Public()
+	default:
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", cfg.Algorithm)
+	}
+
+	if key.id == "" {
+		jwk, _ := key.jwk()
+		key.id = jwk.thumbprint()
+	}
+	return key, nil
+}
+
+// sameKey сообщает, совпадает ли ключ other с k
+func (k *signingKey) sameKey(other *signingKey) bool {
+	if k.method != other.method {
+		return false
+	}
+	if secret, ok := k.verifyKey.([]byte); ok {
+		otherSecret, _ := other.verifyKey.([]byte)
+		return subtle.ConstantTimeCompare(secret, otherSecret) == 1
+	}
+	pub, ok := k.verifyKey.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && pub.Equal(other.verifyKey)
+}
+
+// jwk возвращает публичную часть ключа. Для HMAC публиковать нечего — ok == false
+func (k *signingKey) jwk() (JWK, bool) {
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwk := JWK{Use: "sig", Alg: k.method.Alg(), Kid: k.id}
+
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64(pub)
+	}
+
+	return jwk, jwk.Kty != ""
+}
+
+// thumbprint вычисляет отпечаток ключа по RFC 7638 — используется как kid по умолчанию
+func (k JWK) thumbprint() string {
+	var members interface{}
+	switch k.Kty {
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{k.E, k.Kty, k.N}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{k.Crv, k.Kty, k.X, k.Y}
+	default:
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{k.Crv, k.Kty, k.X}
+	}
+
+	data, _ := json.Marshal(members)
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"auth-micro/internal/auth/config"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// SecretBox шифрует небольшие секреты (например, TOTP) перед записью в БД.
+// AES-256-GCM, ключ — SHA-256 от mfa.encryption_key
+type SecretBox struct {
+	aead cipher.AEAD
+}
+
+func NewSecretBox(cfg *config.Config) (*SecretBox, error) {
+	if cfg.MFA.EncryptionKey == "" {
+		return nil, errors.New("mfa encryption key is not configured")
+	}
+	key := sha256.Sum256([]byte(cfg.MFA.EncryptionKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &SecretBox{aead: aead}, nil
+}
+
+// Seal возвращает nonce || ciphertext
+func (b *SecretBox) Seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return b.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (b *SecretBox) Open(sealed []byte) ([]byte, error) {
+	if len(sealed) < b.aead.NonceSize() {
+		return nil, errors.New("sealed secret is too short")
+	}
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	return b.aead.Open(nil, nonce, ciphertext, nil)
+}
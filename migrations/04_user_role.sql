@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Роль пользователя; admin может читать и изменять чужие профили
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS role;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Храним только SHA-256 от refresh-токена, чтобы утечка БД не давала готовых сессий
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS token_hash CHAR(64);
+
+-- Хэшируем существующие токены на месте
+UPDATE refresh_tokens
+SET token_hash = encode(sha256(convert_to(token, 'UTF8')), 'hex')
+WHERE token_hash IS NULL;
+
+ALTER TABLE refresh_tokens ALTER COLUMN token_hash SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens(token_hash);
+
+DROP INDEX IF EXISTS idx_refresh_tokens_token;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS token;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- Исходные токены из хэшей не восстановить: после отката все сессии нужно отозвать
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS token TEXT;
+UPDATE refresh_tokens SET token = token_hash, revoked = true WHERE token IS NULL;
+ALTER TABLE refresh_tokens ALTER COLUMN token SET NOT NULL;
+ALTER TABLE refresh_tokens ADD CONSTRAINT refresh_tokens_token_key UNIQUE (token);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_token ON refresh_tokens(token);
+
+DROP INDEX IF EXISTS idx_refresh_tokens_token_hash;
+ALTER TABLE refresh_tokens DROP COLUMN IF EXISTS token_hash;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Сведения о клиенте для списка активных сессий (сессия = семейство refresh-токенов)
+ALTER TABLE refresh_tokens
+    ADD COLUMN IF NOT EXISTS client_ip VARCHAR(45) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS user_agent TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS device_label VARCHAR(100) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+UPDATE refresh_tokens SET last_used_at = created_at;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE refresh_tokens
+    DROP COLUMN IF EXISTS last_used_at,
+    DROP COLUMN IF EXISTS device_label,
+    DROP COLUMN IF EXISTS user_agent,
+    DROP COLUMN IF EXISTS client_ip;
+-- +goose StatementEnd
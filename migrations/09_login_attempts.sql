@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Счётчики неудачных входов по аккаунту (username) и по IP, общие для всех реплик
+CREATE TABLE IF NOT EXISTS login_attempts (
+    attempt_key VARCHAR(300) PRIMARY KEY, -- "username:<name>" или "ip:<addr>"
+    failures INTEGER NOT NULL DEFAULT 0,
+    lockouts INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMP,
+    last_failure_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_last_failure_at ON login_attempts(last_failure_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS login_attempts;
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+-- TOTP второго фактора; секрет зашифрован ключом сервиса (mfa.encryption_key)
+CREATE TABLE IF NOT EXISTS totp_factors (
+    user_id VARCHAR(36) PRIMARY KEY,
+    secret_encrypted BYTEA NOT NULL,
+    confirmed_at TIMESTAMP, -- NULL — подключение начато, но не подтверждено кодом
+    last_used_step BIGINT, -- номер последнего принятого 30-секундного интервала, защита от повтора кода
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Одноразовые коды восстановления; хранится только SHA-256
+CREATE TABLE IF NOT EXISTS recovery_codes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id VARCHAR(36) NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT fk_user FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user_id ON recovery_codes(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS totp_factors;
+-- +goose StatementEnd
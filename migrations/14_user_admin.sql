@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Отключённый пользователь не может войти и обновить токены
+ALTER TABLE users ADD COLUMN IF NOT EXISTS disabled_at TIMESTAMP;
+
+-- Для постраничного ListUsers
+CREATE INDEX IF NOT EXISTS idx_users_created_at_id ON users(created_at, id);
+
+INSERT INTO permissions (name, description) VALUES
+    ('users:delete', 'Удаление пользователей')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'users:delete')
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permissions WHERE name = 'users:delete';
+DROP INDEX IF EXISTS idx_users_created_at_id;
+ALTER TABLE users DROP COLUMN IF EXISTS disabled_at;
+-- +goose StatementEnd
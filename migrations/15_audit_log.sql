@@ -0,0 +1,38 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Кто выполнил действие: для действий администратора отличается от user_id
+ALTER TABLE auth_events ADD COLUMN IF NOT EXISTS actor_id VARCHAR(36);
+
+CREATE INDEX IF NOT EXISTS idx_auth_events_created_at_id ON auth_events(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_auth_events_type ON auth_events(event_type, created_at);
+
+-- Журнал только дополняется: изменение и удаление записей запрещены
+CREATE OR REPLACE FUNCTION auth_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'auth_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS auth_events_append_only ON auth_events;
+CREATE TRIGGER auth_events_append_only
+    BEFORE UPDATE OR DELETE OR TRUNCATE ON auth_events
+    FOR EACH STATEMENT EXECUTE FUNCTION auth_events_append_only();
+
+INSERT INTO permissions (name, description) VALUES
+    ('audit:read', 'Просмотр журнала событий безопасности')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'audit:read')
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permissions WHERE name = 'audit:read';
+DROP TRIGGER IF EXISTS auth_events_append_only ON auth_events;
+DROP FUNCTION IF EXISTS auth_events_append_only();
+DROP INDEX IF EXISTS idx_auth_events_type;
+DROP INDEX IF EXISTS idx_auth_events_created_at_id;
+ALTER TABLE auth_events DROP COLUMN IF EXISTS actor_id;
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- События для других сервисов. Пишутся в одной транзакции с изменением
+-- пользователя, доставляются фоновым релеем
+CREATE TABLE IF NOT EXISTS outbox (
+    id UUID PRIMARY KEY,
+    -- Без внешнего ключа: событие об удалении переживает пользователя
+    user_id VARCHAR(36) NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    payload BYTEA NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    -- До этого момента событие занято релеем или ждёт повтора
+    locked_until TIMESTAMP,
+    published_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(created_at) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_published_at ON outbox(published_at) WHERE published_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS outbox;
+-- +goose StatementEnd
@@ -0,0 +1,63 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Приложения, которые получают токены через OAuth 2.0
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    id VARCHAR(36) PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    secret_hash TEXT, -- bcrypt; NULL у публичных клиентов (SPA, мобильные), им обязателен PKCE
+    redirect_uris TEXT[] NOT NULL DEFAULT '{}',
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    grant_types TEXT[] NOT NULL DEFAULT '{}',
+    first_party BOOLEAN NOT NULL DEFAULT FALSE, -- собственные приложения не спрашивают согласия
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Одноразовые коды авторизации, хранится только SHA-256
+CREATE TABLE IF NOT EXISTS oauth_authorization_codes (
+    code_hash CHAR(64) PRIMARY KEY,
+    client_id VARCHAR(36) NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL DEFAULT '',
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    code_challenge TEXT NOT NULL DEFAULT '',
+    code_challenge_method VARCHAR(10) NOT NULL DEFAULT '',
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_oauth_authorization_codes_expires_at ON oauth_authorization_codes(expires_at);
+
+-- Согласия пользователей: какие scope уже разрешены клиенту
+CREATE TABLE IF NOT EXISTS oauth_consents (
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_id VARCHAR(36) NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    granted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, client_id)
+);
+
+-- Refresh-токены, выданные клиенту, привязаны к нему и к выданным scope
+ALTER TABLE refresh_tokens
+    ADD COLUMN IF NOT EXISTS client_id VARCHAR(36) REFERENCES oauth_clients(id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{}';
+
+INSERT INTO permissions (name, description) VALUES
+    ('clients:manage', 'Регистрация OAuth-клиентов')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('admin', 'clients:manage')
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permissions WHERE name = 'clients:manage';
+DELETE FROM refresh_tokens WHERE client_id IS NOT NULL;
+ALTER TABLE refresh_tokens
+    DROP COLUMN IF EXISTS scopes,
+    DROP COLUMN IF EXISTS client_id;
+DROP TABLE IF EXISTS oauth_consents;
+DROP TABLE IF EXISTS oauth_authorization_codes;
+DROP TABLE IF EXISTS oauth_clients;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- OpenID Connect: nonce из запроса авторизации возвращается в ID-токене
+ALTER TABLE oauth_authorization_codes
+    ADD COLUMN IF NOT EXISTS nonce TEXT NOT NULL DEFAULT '';
+
+-- Адреса, куда клиент может вернуть браузер после выхода (RP-initiated logout)
+ALTER TABLE oauth_clients
+    ADD COLUMN IF NOT EXISTS post_logout_redirect_uris TEXT[] NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE oauth_clients DROP COLUMN IF EXISTS post_logout_redirect_uris;
+ALTER TABLE oauth_authorization_codes DROP COLUMN IF EXISTS nonce;
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Учётные записи внешних провайдеров (Google и т.п.), привязанные к пользователям
+CREATE TABLE IF NOT EXISTS federated_identities (
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL, -- sub из ID-токена провайдера
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255) NOT NULL DEFAULT '', -- email у провайдера на момент привязки
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (provider, subject)
+);
+
+CREATE INDEX IF NOT EXISTS idx_federated_identities_user_id ON federated_identities(user_id);
+
+-- Незавершённые входы через провайдера: state хранится как SHA-256
+CREATE TABLE IF NOT EXISTS federated_login_states (
+    state_hash CHAR(64) PRIMARY KEY,
+    provider VARCHAR(50) NOT NULL,
+    nonce TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_federated_login_states_expires_at ON federated_login_states(expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS federated_login_states;
+DROP TABLE IF EXISTS federated_identities;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Завершённые сессии, access-токены которых (по sid) ещё не истекли
+CREATE TABLE IF NOT EXISTS revoked_sessions (
+    session_id VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMP NOT NULL,
+    revoked_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_sessions_expires_at ON revoked_sessions(expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS revoked_sessions;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Отсечка по пользователю: его access-токены с iat раньше revoked_before
+-- недействительны (отключение, удаление, принудительный выход)
+CREATE TABLE IF NOT EXISTS revoked_user_tokens (
+    user_id VARCHAR(36) PRIMARY KEY,
+    revoked_before TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_user_tokens_expires_at ON revoked_user_tokens(expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS revoked_user_tokens;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- SHA-256 секрета браузера, начавшего вход через провайдера. Входы, начатые
+-- до миграции, завершить нельзя: пустой хэш не совпадёт ни с одним секретом
+ALTER TABLE federated_login_states ADD COLUMN IF NOT EXISTS binding_hash CHAR(64) NOT NULL DEFAULT '';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE federated_login_states DROP COLUMN IF EXISTS binding_hash;
+-- +goose StatementEnd
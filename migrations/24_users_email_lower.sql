@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Поиск по email без учёта регистра. Индекс не уникальный: старые записи
+-- могут различаться только регистром
+CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users(LOWER(email));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_users_email_lower;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- ClaimPending ищет более раннее недоставленное событие того же пользователя
+CREATE INDEX IF NOT EXISTS idx_outbox_user_pending ON outbox(user_id, created_at) WHERE published_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_outbox_user_pending;
+-- +goose StatementEnd